@@ -0,0 +1,56 @@
+package command
+
+import (
+    "fmt"
+    "log"
+    "os"
+    
+    "github.com/jarlex/gommander/compare"
+    "github.com/jarlex/gommander/report"
+    "github.com/spf13/cobra"
+)
+
+var tolerances = compare.DefaultTolerances
+
+var compareCmd = &cobra.Command{
+    Use:   "compare baseline.json current.json",
+    Short: "compare the results of two runs and detect regressions",
+    Long: `Compare two results written by run --out json=path task by task: p50, p95,
+p99, throughput and error rate. A latency regresses when it grew beyond its
+tolerance and a Mann-Whitney U test finds the current latencies significantly
+greater, the throughput when it dropped beyond its tolerance and the same test
+finds the rates of the time series intervals significantly lower, the error
+rate when it grew beyond its tolerance and a two-proportion z test finds it
+significant. A task of the baseline that did not run regresses too. Exits with
+status 1 on regression.`,
+    Args: cobra.ExactArgs(2),
+    Run: func(cmd *cobra.Command, args []string) {
+        baseline, err := report.Load(args[0])
+        if err != nil {
+            log.Fatal(err)
+        }
+        current, err := report.Load(args[1])
+        if err != nil {
+            log.Fatal(err)
+        }
+        diff := compare.Results(baseline, current, tolerances)
+        if err := diff.Write(os.Stdout); err != nil {
+            log.Fatal(err)
+        }
+        if diff.Regressed() {
+            fmt.Println("REGRESSION")
+            os.Exit(1)
+        }
+        fmt.Println("OK")
+    },
+}
+
+func init() {
+    compareCmd.Flags().Float64Var(&tolerances.P50, "p50-tolerance", tolerances.P50, "p50 growth accepted, percent of the baseline")
+    compareCmd.Flags().Float64Var(&tolerances.P95, "p95-tolerance", tolerances.P95, "p95 growth accepted, percent of the baseline")
+    compareCmd.Flags().Float64Var(&tolerances.P99, "p99-tolerance", tolerances.P99, "p99 growth accepted, percent of the baseline")
+    compareCmd.Flags().Float64Var(&tolerances.Throughput, "throughput-tolerance", tolerances.Throughput, "throughput drop accepted, percent of the baseline")
+    compareCmd.Flags().Float64Var(&tolerances.ErrorRate, "error-rate-tolerance", tolerances.ErrorRate, "error rate growth accepted, percentage points")
+    compareCmd.Flags().Float64Var(&tolerances.Alpha, "alpha", tolerances.Alpha, "significance level of the statistical tests")
+    RootCmd.AddCommand(compareCmd)
+}
@@ -1,86 +1,53 @@
 package command
 
 import (
-    "io/ioutil"
     "log"
     "os"
     "strings"
     
-    "github.com/jarlex/gommander/plan"
-    "github.com/jarlex/gommander/request"
-    "github.com/jarlex/gommander/step"
-    "github.com/jarlex/gommander/task"
+    "github.com/jarlex/gommander/config"
 )
 
-type Config struct {
-    Plan     *plan.Plan
-    Steps    map[string]*step.Step
-    Tasks    map[string]*task.Task
-    Requests map[string]*request.Request
-}
+type Config = config.Config
+
+var (
+    envName  string
+    varFlags []string
+)
 
+// Read loads a plan folder, see config.Load, exiting on error.
 func Read(planFolder string, planFilename ...string) *Config {
-    conf := &Config{}
-    conf.Requests = make(map[string]*request.Request)
-    conf.Steps = make(map[string]*step.Step)
-    conf.Tasks = make(map[string]*task.Task)
-    
-    // Read all Requests
-    dir := planFolder + "/requests/"
-    files, err := ioutil.ReadDir(dir)
+    conf, err := config.Read(planFolder, planFilename...)
     if err != nil {
         log.Fatal(err)
     }
-    
-    for _, f := range files {
-        aRes, err := request.Read(dir + f.Name())
-        if err != nil {
-            log.Fatal(err)
-        }
-        conf.Requests[aRes.Name] = aRes
-    }
-    
-    // Read all Tasks
-    dir = planFolder + "/tasks/"
-    files, err = ioutil.ReadDir(dir)
-    if err != nil {
-        log.Fatal(err)
-    }
-    
-    for _, f := range files {
-        aTask, err := task.Read(dir+f.Name(), conf.Requests)
-        if err != nil {
-            log.Fatal(err)
+    return conf
+}
+
+// load loads the plan of the --config flag with the --env and --var
+// overrides and the GOMMANDER_* environment variables, exiting on error.
+// The plan lint warnings go to stderr.
+func load() *Config {
+    l := config.Loader{Env: envName, Environ: os.Environ(), Vars: make(map[string]string)}
+    for _, kv := range varFlags {
+        eq := strings.Index(kv, "=")
+        if eq <= 0 {
+            log.Fatalf("--var %s must be key=value", kv)
         }
-        conf.Tasks[aTask.Name] = aTask
+        l.Vars[kv[:eq]] = kv[eq+1:]
     }
-    
-    // Read all Steps
-    dir = planFolder + "/steps/"
-    files, err = ioutil.ReadDir(dir)
+    conf, err := l.Read(cfgFile)
     if err != nil {
         log.Fatal(err)
     }
-    
-    for _, f := range files {
-        aStep, err := step.Read(dir+f.Name(), conf.Tasks)
-        if err != nil {
-            log.Fatal(err)
-        }
-        conf.Steps[aStep.Name] = aStep
-    }
-    
-    // Read Plan
-    planFile := planFolder
-    if len(planFilename) > 0 {
-        planFile = strings.Join([]string{planFile, planFilename[0]}, string(os.PathSeparator))
-    } else {
-        planFile = strings.Join([]string{planFile, "plan.json"}, string(os.PathSeparator))
+    logger := newLogger()
+    for _, w := range conf.Warnings {
+        logger.Warn(w)
     }
-    conf.Plan, err = plan.Read(planFile, conf.Steps)
-    if err != nil {
-        log.Fatal(err)
-    }
-    
     return conf
 }
+
+func init() {
+    RootCmd.PersistentFlags().StringVar(&envName, "env", "", "environment profile applied to the plan")
+    RootCmd.PersistentFlags().StringArrayVar(&varFlags, "var", nil, "plan variable override as key=value, can be repeated")
+}
@@ -2,14 +2,20 @@ package command
 
 import (
     "fmt"
+    "log"
     "os"
     
+    "github.com/jarlex/gommander/logging"
+    "github.com/jarlex/gommander/secret"
+    
     "github.com/spf13/cobra"
 )
 
-var cfgFile string
-
-var cnf *Config
+var (
+    cfgFile   string
+    logLevel  string
+    logFormat string
+)
 
 var RootCmd = &cobra.Command{
     Use:   "gommander",
@@ -18,17 +24,30 @@ var RootCmd = &cobra.Command{
 }
 
 func Execute() {
+    log.SetFlags(0)
+    log.SetOutput(secret.Writer(os.Stderr))
     if err := RootCmd.Execute(); err != nil {
         fmt.Println(err)
         os.Exit(1)
     }
 }
 
-func init() {
-    cobra.OnInitialize(initConfig)
-    RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file wich contain a full plan of test")
+// newLogger returns the stderr logger of the --log-level and --log-format
+// flags, exiting when they are wrong.
+func newLogger() *logging.Logger {
+    level, err := logging.ParseLevel(logLevel)
+    if err != nil {
+        log.Fatal(err)
+    }
+    logger, err := logging.New(os.Stderr, level, logFormat)
+    if err != nil {
+        log.Fatal(err)
+    }
+    return logger
 }
 
-func initConfig() {
-    cnf = Read(cfgFile)
+func init() {
+    RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "plan", "config file wich contain a full plan of test")
+    RootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "minimum level of the messages logged: debug, info, warn or error")
+    RootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logging.FormatText, "format of the messages logged: text or json")
 }
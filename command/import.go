@@ -0,0 +1,116 @@
+package command
+
+import (
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+    
+    "github.com/jarlex/gommander/config"
+    "github.com/jarlex/gommander/importer"
+    "github.com/spf13/cobra"
+)
+
+var (
+    importOut   string
+    importURL   string
+    importName  string
+    importChain bool
+    importForce bool
+)
+
+var importCmd = &cobra.Command{
+    Use:   "import curl|har|postman|openapi <file>",
+    Short: "create a plan from captured requests",
+    Long: `Create a plan folder from curl command lines, a HAR file saved by a browser, a
+Postman v2.1 collection or an OpenAPI 3 spec, - reading the standard input.
+Every request becomes a request and a task, in order, run once by the default
+step, keeping its headers and body. Values returned by a request and reused in
+the path of a later one are printed as extraction suggestions, --chain applies
+them.
+
+An OpenAPI spec gives a request per operation, with example parameters and
+bodies, and tasks checking the status and the body of the response against the
+spec, so the plan works as a contract test. --url replaces the server of the
+spec.`,
+    Args: cobra.ExactArgs(2),
+    Run: func(cmd *cobra.Command, args []string) {
+        format, file := args[0], args[1]
+        var in io.Reader = os.Stdin
+        if file != "-" {
+            f, err := os.Open(file)
+            if err != nil {
+                log.Fatal(err)
+            }
+            defer f.Close()
+            in = f
+        }
+        if entries, err := os.ReadDir(importOut); err == nil && len(entries) > 0 && !importForce {
+            log.Fatalf("%s is not empty, use --force to write into it", importOut)
+        }
+        
+        var entries []importer.Entry
+        var vars map[string]interface{}
+        var err error
+        switch format {
+        case "curl":
+            entries, err = importer.Curl(in)
+        case "har":
+            entries, err = importer.HAR(in)
+        case "postman":
+            entries, vars, err = importer.Postman(in)
+        case "openapi":
+            entries, vars, err = importer.OpenAPI(in, importURL)
+        default:
+            log.Fatalf("unknown format %s, use curl, har, postman or openapi", format)
+        }
+        if err != nil {
+            log.Fatalf("%s: %s", file, err.Error())
+        }
+        
+        name := importName
+        if name == "" && file == "-" {
+            name = format
+        } else if name == "" {
+            name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+        }
+        p, suggestions, err := importer.Build(name, entries, importChain)
+        if err != nil {
+            log.Fatal(err)
+        }
+        if len(vars) > 0 {
+            p.Vars = vars
+        }
+        if err := config.FromPlan(p).Save(importOut); err != nil {
+            log.Fatal(err)
+        }
+        fmt.Printf("Imported %d requests in %s\n", len(entries), importOut)
+        printSuggestions(suggestions, importChain)
+    },
+}
+
+// printSuggestions prints the extraction suggestions of an import, applied
+// when chained is set.
+func printSuggestions(suggestions []importer.Suggestion, chained bool) {
+    for _, s := range suggestions {
+        if chained {
+            fmt.Printf("  chained: %s\n", s)
+        } else {
+            fmt.Printf("  suggestion: %s\n", s)
+        }
+    }
+    if !chained && len(suggestions) > 0 {
+        fmt.Println("Import again with --chain to extract them")
+    }
+}
+
+func init() {
+    importCmd.Flags().StringVar(&importOut, "out", "plan", "folder the plan is written to")
+    importCmd.Flags().StringVar(&importURL, "url", "", "server of an OpenAPI spec, its first one by default")
+    importCmd.Flags().StringVar(&importName, "name", "", "name of the plan, the file name by default")
+    importCmd.Flags().BoolVar(&importChain, "chain", false, "extract the values reused in later paths")
+    importCmd.Flags().BoolVar(&importForce, "force", false, "write into a non empty folder")
+    RootCmd.AddCommand(importCmd)
+}
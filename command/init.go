@@ -0,0 +1,151 @@
+package command
+
+import (
+    "fmt"
+    "log"
+    "net/url"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    
+    "github.com/jarlex/gommander/config"
+    "github.com/jarlex/gommander/feeder"
+    "github.com/jarlex/gommander/plan"
+    "github.com/spf13/cobra"
+)
+
+var (
+    initURL      string
+    initTemplate string
+    initEnvs     []string
+    initFeeder   bool
+    initForce    bool
+)
+
+// The data file of gommander init --feeder, each petition sends the user of
+// the next row.
+const (
+    sampleFeederFile = "data/users.csv"
+    sampleFeeder     = "user\nuser1\nuser2\nuser3\n"
+)
+
+// templates build the sample plans of gommander init, for base and path.
+var templates = map[string]func(name, base, path string) *plan.Builder{
+    "smoke": func(name, base, path string) *plan.Builder {
+        return plan.New(name).URL(base).
+            Step("smoke", 1, 1).
+            Task("get", 200).Request("get", "GET", path)
+    },
+    "load": func(name, base, path string) *plan.Builder {
+        return plan.New(name).URL(base).
+            Step("warmup", 2, 20).
+            Task("get", 200).Request("get", "GET", path).
+            Step("load", 20, 2000).UseTask("get")
+    },
+    "soak": func(name, base, path string) *plan.Builder {
+        return plan.New(name).URL(base).
+            Step("soak", 10, 100000).
+            Task("get", 200).Request("get", "GET", path)
+    },
+}
+
+var initCmd = &cobra.Command{
+    Use:   "init <dir>",
+    Short: "create a sample plan",
+    Long: `Create in dir a working plan folder targeting --url from a template: smoke
+(one petition), load (a warm up then 20 users) or soak (10 users for a long
+run). --envs adds an environment profile per name in envs/, to be filled with
+the URL of each environment. --feeder adds a data/users.csv feeder, each
+petition sending the user of the next row as the user query parameter.`,
+    Args: cobra.ExactArgs(1),
+    Run: func(cmd *cobra.Command, args []string) {
+        dir := args[0]
+        if _, ok := templates[initTemplate]; !ok {
+            log.Fatalf("unknown template %s, use one of %s", initTemplate, strings.Join(templateNames(), ", "))
+        }
+        target, err := url.Parse(initURL)
+        if err != nil || target.Scheme == "" || target.Host == "" {
+            log.Fatalf("--url %q must be an absolute URL", initURL)
+        }
+        if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 && !initForce {
+            log.Fatalf("%s is not empty, use --force to write into it", dir)
+        }
+        if err := scaffold(dir, initTemplate, target, initEnvs, initFeeder); err != nil {
+            log.Fatal(err)
+        }
+        fmt.Printf("Created the %s plan in %s, run it with:\n  gommander run --config %s\n", initTemplate, dir, dir)
+    },
+}
+
+// scaffold writes in dir the plan of template targeting target, the
+// environment profiles envs and, when withFeeder is set, the sample feeder.
+func scaffold(dir, template string, target *url.URL, envs []string, withFeeder bool) error {
+    path := target.EscapedPath()
+    if path == "" {
+        path = "/"
+    }
+    query := target.RawQuery
+    if withFeeder {
+        if query != "" {
+            query += "&"
+        }
+        query += "user={{user}}"
+    }
+    if query != "" {
+        path += "?" + query
+    }
+    base := target.Scheme + "://" + target.Host
+    b := templates[template](filepath.Base(dir), base, path)
+    if withFeeder {
+        f, err := feeder.Parse(sampleFeederFile, []byte(sampleFeeder))
+        if err != nil {
+            return err
+        }
+        b.Feeder(sampleFeederFile, f)
+    }
+    p, err := b.Build()
+    if err != nil {
+        return err
+    }
+    if err := config.FromPlan(p).Save(dir); err != nil {
+        return err
+    }
+    
+    if withFeeder {
+        if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(sampleFeederFile)), 0755); err != nil {
+            return err
+        }
+        if err := os.WriteFile(filepath.Join(dir, sampleFeederFile), []byte(sampleFeeder), 0644); err != nil {
+            return err
+        }
+    }
+    for _, env := range envs {
+        if err := os.MkdirAll(filepath.Join(dir, "envs"), 0755); err != nil {
+            return err
+        }
+        profile := fmt.Sprintf("{\n  \"url\": %q\n}\n", base)
+        if err := os.WriteFile(filepath.Join(dir, "envs", env+".json"), []byte(profile), 0644); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func templateNames() []string {
+    var names []string
+    for name := range templates {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+func init() {
+    initCmd.Flags().StringVar(&initURL, "url", "http://localhost:8080/", "URL the sample plan targets")
+    initCmd.Flags().StringVar(&initTemplate, "template", "smoke", "sample plan: "+strings.Join(templateNames(), ", "))
+    initCmd.Flags().StringSliceVar(&initEnvs, "envs", nil, "environment profiles to create, dev,staging for instance")
+    initCmd.Flags().BoolVar(&initFeeder, "feeder", false, "add a CSV feeder of users to the plan")
+    initCmd.Flags().BoolVar(&initForce, "force", false, "write into a non empty folder")
+    RootCmd.AddCommand(initCmd)
+}
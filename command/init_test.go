@@ -0,0 +1,76 @@
+package command
+
+import (
+    "net/url"
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+    
+    "github.com/jarlex/gommander/config"
+)
+
+func TestTemplates(t *testing.T) {
+    tests := []struct {
+        template string
+        steps    []string
+        users    []int
+    }{
+        {"load", []string{"warmup", "load"}, []int{2, 20}},
+        {"smoke", []string{"smoke"}, []int{1}},
+        {"soak", []string{"soak"}, []int{10}},
+    }
+    if names := templateNames(); len(names) != len(tests) {
+        t.Fatalf("templates = %q", names)
+    }
+    for _, tt := range tests {
+        t.Run(tt.template, func(t *testing.T) {
+            p, err := templates[tt.template]("sample", "http://localhost:8080", "/health?full=1").Build()
+            if err != nil {
+                t.Fatal(err)
+            }
+            dir := t.TempDir()
+            if err := config.FromPlan(p).Save(dir); err != nil {
+                t.Fatal(err)
+            }
+            conf, err := config.Read(dir)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(conf.Plan.StepsNames, tt.steps) {
+                t.Errorf("steps = %q, want %q", conf.Plan.StepsNames, tt.steps)
+            }
+            for i, s := range conf.Plan.Steps {
+                if s.ConcurrentUsers != tt.users[i] {
+                    t.Errorf("step %s users = %d, want %d", s.Name, s.ConcurrentUsers, tt.users[i])
+                }
+                r := s.Tasks[0].Request
+                if conf.Plan.URL != "http://localhost:8080" || r.Method != "GET" || r.Path != "/health?full=1" {
+                    t.Errorf("step %s requests %s %s%s", s.Name, r.Method, conf.Plan.URL, r.Path)
+                }
+            }
+        })
+    }
+}
+
+func TestScaffoldFeeder(t *testing.T) {
+    dir := t.TempDir()
+    target, _ := url.Parse("http://localhost:8080/items?full=1")
+    if err := scaffold(dir, "load", target, []string{"dev"}, true); err != nil {
+        t.Fatal(err)
+    }
+    conf, err := config.Read(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if conf.Plan.FeederFile != "data/users.csv" || conf.Plan.Feeder.Len() != 3 || conf.Plan.Feeder.Row(0)["user"] != "user1" {
+        t.Errorf("feeder %s with rows %v", conf.Plan.FeederFile, conf.Plan.Feeder.Row(0))
+    }
+    r := conf.Requests["get"]
+    if r.Path != "/items?full=1&user={{user}}" || !reflect.DeepEqual(r.ParamsURL, []string{"user"}) {
+        t.Errorf("request %s with params %q", r.Path, r.ParamsURL)
+    }
+    if _, err := os.Stat(filepath.Join(dir, "envs", "dev.json")); err != nil {
+        t.Error(err)
+    }
+}
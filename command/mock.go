@@ -0,0 +1,61 @@
+package command
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "net/http"
+    "os"
+    "os/signal"
+    "syscall"
+    
+    "github.com/jarlex/gommander/mock"
+    "github.com/jarlex/gommander/secret"
+    "github.com/spf13/cobra"
+)
+
+var mockListen string
+
+var mockCmd = &cobra.Command{
+    Use:   "mock [planDir]",
+    Short: "serve the requests of a plan with canned responses",
+    Long: `Listen on --listen and answer every request of the plan, matched by method
+and path, with the response of its mock: status, headers, a templated body,
+a latency distribution and an error rate. Requests without mock answer the
+status expected by their tasks and a JSON object with a generated value for
+every field the tasks extract. Point the plan url at the mock to develop it
+offline.`,
+    Args: cobra.MaximumNArgs(1),
+    Run: func(cmd *cobra.Command, args []string) {
+        if len(args) == 1 {
+            cfgFile = args[0]
+        }
+        conf := load()
+        server, err := mock.New(conf)
+        if err != nil {
+            log.Fatal(err)
+        }
+        server.Logger = log.New(secret.Writer(os.Stdout), "", 0)
+        httpServer := &http.Server{Addr: mockListen, Handler: server}
+        
+        sigs := make(chan os.Signal, 1)
+        signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+        defer signal.Stop(sigs)
+        go func() {
+            <-sigs
+            httpServer.Shutdown(context.Background())
+        }()
+        fmt.Printf("Mocking %s on %s, Ctrl-C to stop\n", conf.Plan.Name, mockListen)
+        for _, route := range server.Routes() {
+            fmt.Printf("  %s\n", route)
+        }
+        if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+            log.Fatal(err)
+        }
+    },
+}
+
+func init() {
+    mockCmd.Flags().StringVar(&mockListen, "listen", ":8081", "address the mock listens on")
+    RootCmd.AddCommand(mockCmd)
+}
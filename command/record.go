@@ -0,0 +1,99 @@
+package command
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "net/http"
+    "os"
+    "os/signal"
+    "path/filepath"
+    "syscall"
+    
+    "github.com/jarlex/gommander/config"
+    "github.com/jarlex/gommander/importer"
+    "github.com/jarlex/gommander/secret"
+    "github.com/spf13/cobra"
+)
+
+var (
+    recordListen string
+    recordTarget string
+    recordOut    string
+    recordName   string
+    recordChain  bool
+    recordForce  bool
+)
+
+var recordCmd = &cobra.Command{
+    Use:   "record",
+    Short: "record a plan through a proxy",
+    Long: `Listen on --listen as a reverse proxy to --target, or as a forward HTTP proxy
+without target, and record the petitions going through while a client is
+used. Ctrl-C writes the plan folder: a request and a task per petition,
+expecting the recorded status, run once by the default step. The pauses
+between petitions become think times of the tasks. Authorization and Cookie
+headers are written as ${env:AUTHORIZATION} and ${env:COOKIE}. Values returned by a
+petition and reused in the path of a later one are printed as extraction
+suggestions, --chain applies them.`,
+    Args: cobra.NoArgs,
+    Run: func(cmd *cobra.Command, args []string) {
+        if entries, err := os.ReadDir(recordOut); err == nil && len(entries) > 0 && !recordForce {
+            log.Fatalf("%s is not empty, use --force to write into it", recordOut)
+        }
+        rec, err := importer.NewRecorder(recordTarget)
+        if err != nil {
+            log.Fatal(err)
+        }
+        rec.Logger = log.New(secret.Writer(os.Stdout), "", 0)
+        server := &http.Server{Addr: recordListen, Handler: rec}
+        
+        sigs := make(chan os.Signal, 1)
+        signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+        defer signal.Stop(sigs)
+        go func() {
+            <-sigs
+            server.Shutdown(context.Background())
+        }()
+        if recordTarget != "" {
+            fmt.Printf("Recording on %s for %s, Ctrl-C to write the plan\n", recordListen, recordTarget)
+        } else {
+            fmt.Printf("Recording on %s as a HTTP proxy, Ctrl-C to write the plan\n", recordListen)
+        }
+        if err := server.ListenAndServe(); err != http.ErrServerClosed {
+            log.Fatal(err)
+        }
+        
+        entries := rec.Entries()
+        if len(entries) == 0 {
+            log.Fatal("nothing recorded")
+        }
+        name := recordName
+        if name == "" {
+            name = filepath.Base(recordOut)
+        }
+        p, suggestions, err := importer.Build(name, entries, recordChain)
+        if err != nil {
+            log.Fatal(err)
+        }
+        if err := config.FromPlan(p).Save(recordOut); err != nil {
+            log.Fatal(err)
+        }
+        fmt.Printf("Recorded %d requests in %s\n", len(entries), recordOut)
+        logger := newLogger()
+        for _, w := range rec.Warnings() {
+            logger.Warn(w)
+        }
+        printSuggestions(suggestions, recordChain)
+    },
+}
+
+func init() {
+    recordCmd.Flags().StringVar(&recordListen, "listen", ":8080", "address the proxy listens on")
+    recordCmd.Flags().StringVar(&recordTarget, "target", "", "URL the petitions are sent to, none for a forward proxy")
+    recordCmd.Flags().StringVar(&recordOut, "out", "plan", "folder the plan is written to")
+    recordCmd.Flags().StringVar(&recordName, "name", "", "name of the plan, the folder name by default")
+    recordCmd.Flags().BoolVar(&recordChain, "chain", false, "extract the values reused in later paths")
+    recordCmd.Flags().BoolVar(&recordForce, "force", false, "write into a non empty folder")
+    RootCmd.AddCommand(recordCmd)
+}
@@ -0,0 +1,215 @@
+package command
+
+import (
+    "fmt"
+    "log"
+    "net"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+    
+    "github.com/jarlex/gommander/debug"
+    "github.com/jarlex/gommander/metrics"
+    "github.com/jarlex/gommander/report"
+    "github.com/jarlex/gommander/runner"
+    "github.com/jarlex/gommander/tracing"
+    
+    "github.com/spf13/cobra"
+)
+
+var (
+    gracePeriod  time.Duration
+    dryRun       bool
+    dryRunFormat string
+    debugFailing bool
+    traceUser    int
+    debugLimit   int
+    dashboard    bool
+    dashInterval time.Duration
+    runOuts      []string
+    metricsAddr  string
+    streams      []string
+    streamTags   []string
+    streamEvery  time.Duration
+    streamBuffer int
+    traceOut     string
+)
+
+var runCmd = &cobra.Command{
+    Use:   "run",
+    Short: "run a plan",
+    Long: `Run the plan of the config folder. The first SIGINT/SIGTERM stops starting
+new petitions and waits for the ones in flight up to the grace period, then
+runs the teardown tasks and prints the summary. A second signal aborts.
+--dry-run prints the petitions one user would send instead of sending them.
+--debug dumps the petitions of the failing samples and --trace-user every
+petition of a user, with its variables, to stderr. --dashboard replaces the
+sample lines with a live view of the steps, redrawn in place on a terminal.
+--out html=report.html or json=result.json writes the result once finished,
+with the outcome of the plan thresholds, printed as well.
+--metrics-listen serves the live metrics to Prometheus on /metrics and
+--stream statsd=udp://host:8125 or influx=http://host:8086/write?db=load
+pushes them to StatsD or InfluxDB. --trace otlp=http://localhost:4318 traces
+each petition, its tasks and requests, with a traceparent header sent.`,
+    Run: func(cmd *cobra.Command, args []string) {
+        cnf := load()
+        var outs []report.Output
+        for _, o := range runOuts {
+            out, err := report.ParseOutput(o)
+            if err != nil {
+                log.Fatal(err)
+            }
+            outs = append(outs, out)
+        }
+        var streamOpts []metrics.StreamOptions
+        for _, s := range streams {
+            o, err := metrics.ParseStream(s)
+            if err != nil {
+                log.Fatal(err)
+            }
+            o.Tags = map[string]string{"plan": cnf.Plan.Name}
+            for _, t := range streamTags {
+                eq := strings.Index(t, "=")
+                if eq <= 0 {
+                    log.Fatalf("--stream-tag %s must be name=value", t)
+                }
+                o.Tags[t[:eq]] = t[eq+1:]
+            }
+            o.Interval = streamEvery
+            o.Buffer = streamBuffer
+            streamOpts = append(streamOpts, o)
+        }
+        var traceOpts tracing.Options
+        if traceOut != "" {
+            var err error
+            if traceOpts, err = tracing.ParseOptions(traceOut); err != nil {
+                log.Fatal(err)
+            }
+            traceOpts.Resource = map[string]string{"gommander.plan": cnf.Plan.Name}
+        }
+        if dryRun {
+            if err := runner.DryRun(cnf.Plan, os.Stdout, dryRunFormat, terminal(os.Stdout)); err != nil {
+                log.Fatal(err)
+            }
+            return
+        }
+        ctx, stop, release := interruptible(gracePeriod)
+        defer release()
+        opts := []runner.Option{
+            runner.WithLogging(newLogger()),
+            runner.WithStop(stop, gracePeriod),
+        }
+        var dash *metrics.Dashboard
+        if dashboard {
+            tty := terminal(os.Stdout)
+            interval := dashInterval
+            if interval <= 0 && tty {
+                interval = time.Second
+            } else if interval <= 0 {
+                interval = 10 * time.Second
+            }
+            dash = metrics.NewDashboard(os.Stdout, tty, interval)
+            opts = append(opts, runner.WithReporter(dash))
+        } else {
+            opts = append(opts, runner.WithReporter(metrics.NewLineReporter(os.Stdout)))
+        }
+        if metricsAddr != "" {
+            prom := metrics.NewPrometheus(cnf.Plan.Name)
+            mux := http.NewServeMux()
+            mux.Handle("/metrics", prom)
+            server := &http.Server{Addr: metricsAddr, Handler: mux}
+            ln, err := net.Listen("tcp", metricsAddr)
+            if err != nil {
+                log.Fatal(err)
+            }
+            go server.Serve(ln)
+            defer server.Close()
+            opts = append(opts, runner.WithReporter(prom))
+        }
+        var sinks []*metrics.Stream
+        for _, o := range streamOpts {
+            sink, err := metrics.NewStream(o)
+            if err != nil {
+                log.Fatal(err)
+            }
+            sinks = append(sinks, sink)
+            opts = append(opts, runner.WithReporter(sink))
+        }
+        var tracer *tracing.Tracer
+        if traceOut != "" {
+            var err error
+            if tracer, err = tracing.New(traceOpts); err != nil {
+                log.Fatal(err)
+            }
+            opts = append(opts, runner.WithTracer(tracer))
+        }
+        if debugFailing || traceUser >= 0 {
+            opts = append(opts, runner.WithDebug(os.Stderr, debug.Options{Failing: debugFailing, User: traceUser, Limit: debugLimit}))
+        }
+        res, err := runner.Run(ctx, cnf.Plan, opts...)
+        if dash != nil {
+            dash.Close()
+        }
+        for i, sink := range sinks {
+            if serr := sink.Close(); serr != nil {
+                log.Printf("%s stream: %s", streamOpts[i].Format, serr.Error())
+            }
+            if n := sink.Dropped(); n > 0 {
+                log.Printf("%s stream: %d samples dropped, the target could not keep up", streamOpts[i].Format, n)
+            }
+        }
+        if tracer != nil {
+            if terr := tracer.Close(); terr != nil {
+                log.Printf("%s traces: %s", traceOpts.Exporter, terr.Error())
+            }
+            if n := tracer.Dropped(); n > 0 {
+                log.Printf("%s traces: %d spans dropped, the exporter could not keep up", traceOpts.Exporter, n)
+            }
+        }
+        for _, o := range outs {
+            if werr := o.Write(res); werr != nil {
+                log.Printf("%s report: %s", o.Format, werr.Error())
+            }
+        }
+        if err != nil {
+            log.Fatal(err)
+        }
+        for _, th := range res.Thresholds {
+            status, value := "PASSED", th.Value
+            if !th.Passed {
+                status = "FAILED"
+            }
+            if value == "" {
+                value = "not run"
+            }
+            fmt.Printf("Threshold %s on %s: %s %s\n", th.Threshold, strings.Trim(th.Step+"/"+th.Task, "/"), value, status)
+        }
+        fmt.Printf("Full Plan: %d\n", res.Duration)
+    },
+}
+
+func init() {
+    runCmd.Flags().DurationVar(&gracePeriod, "grace", 30*time.Second, "time to wait for petitions in flight when interrupted")
+    runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the petitions rendered instead of sending them")
+    runCmd.Flags().StringVar(&dryRunFormat, "dry-run-format", runner.FormatCurl, "format of the dry run petitions, curl or http")
+    runCmd.Flags().BoolVar(&debugFailing, "debug", false, "dump the request and response of the failing samples")
+    runCmd.Flags().IntVar(&traceUser, "trace-user", -1, "dump every request and response of this user in each step")
+    runCmd.Flags().IntVar(&debugLimit, "debug-body-limit", debug.DefaultLimit, "bytes of each body dumped")
+    runCmd.Flags().BoolVar(&dashboard, "dashboard", false, "show a live view of the running steps instead of a line per sample")
+    runCmd.Flags().DurationVar(&dashInterval, "dashboard-interval", 0, "refresh interval of the dashboard, 1s on a terminal and 10s otherwise by default")
+    runCmd.Flags().StringArrayVar(&runOuts, "out", nil, "report written once finished as format=path, html or json, can be repeated")
+    runCmd.Flags().StringVar(&metricsAddr, "metrics-listen", "", "address serving the Prometheus metrics on /metrics while running")
+    runCmd.Flags().StringArrayVar(&streams, "stream", nil, "push the metrics while running as format=target, statsd or influx to udp://, http(s):// or a file, can be repeated")
+    runCmd.Flags().StringArrayVar(&streamTags, "stream-tag", nil, "tag added to the streamed metrics as name=value, can be repeated")
+    runCmd.Flags().DurationVar(&streamEvery, "stream-interval", 10*time.Second, "flush and aggregate interval of the streamed metrics")
+    runCmd.Flags().IntVar(&streamBuffer, "stream-buffer", 10000, "samples buffered for the streams before dropping them")
+    runCmd.Flags().StringVar(&traceOut, "trace", "", "export a trace of each petition as exporter=target, otlp to a collector URL or file to a JSON file")
+    RootCmd.AddCommand(runCmd)
+}
+
+// terminal reports whether f is a terminal.
+func terminal(f *os.File) bool {
+    info, err := f.Stat()
+    return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,43 @@
+package command
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "log"
+    "os"
+    "path/filepath"
+    
+    "github.com/jarlex/gommander/config"
+    "github.com/spf13/cobra"
+)
+
+var schemaOut string
+
+var schemaCmd = &cobra.Command{
+    Use:   "schema",
+    Short: "write the JSON Schemas of the plan files",
+    Long: `Write the JSON Schemas of the plan, step, task and request files, generated
+from the Go types, as <kind>.schema.json in the output folder. Point the
+$schema key of a JSON file, or a yaml-language-server comment in a YAML one, to
+them for editor completion and validation.`,
+    Run: func(cmd *cobra.Command, args []string) {
+        if err := os.MkdirAll(schemaOut, 0755); err != nil {
+            log.Fatal(err)
+        }
+        for _, kind := range config.SchemaKinds {
+            raw, err := json.MarshalIndent(config.Schema(kind), "", "  ")
+            if err != nil {
+                log.Fatal(err)
+            }
+            path := filepath.Join(schemaOut, kind+".schema.json")
+            if err := ioutil.WriteFile(path, append(raw, '\n'), 0644); err != nil {
+                log.Fatal(err)
+            }
+        }
+    },
+}
+
+func init() {
+    schemaCmd.Flags().StringVar(&schemaOut, "out", "schema", "folder the schemas are written to")
+    RootCmd.AddCommand(schemaCmd)
+}
@@ -0,0 +1,41 @@
+package command
+
+import (
+    "context"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+)
+
+// interruptible returns a context cancelled on the second SIGINT/SIGTERM and
+// a channel closed on the first one. The returned func stops listening for
+// signals and releases the context.
+func interruptible(grace time.Duration) (context.Context, <-chan struct{}, func()) {
+    logger := newLogger()
+    ctx, abort := context.WithCancel(context.Background())
+    stop := make(chan struct{})
+    sigs := make(chan os.Signal, 2)
+    signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+    
+    go func() {
+        select {
+        case sig := <-sigs:
+            logger.Warn("stopping, waiting for the petitions in flight. Send it again to abort", "signal", sig, "grace", grace)
+            close(stop)
+        case <-ctx.Done():
+            return
+        }
+        select {
+        case sig := <-sigs:
+            logger.Warn("aborting", "signal", sig)
+            abort()
+        case <-ctx.Done():
+        }
+    }()
+    
+    return ctx, stop, func() {
+        signal.Stop(sigs)
+        abort()
+    }
+}
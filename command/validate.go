@@ -0,0 +1,67 @@
+package command
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "text/tabwriter"
+    
+    "github.com/jarlex/gommander/secret"
+    "github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+    Use:   "validate",
+    Short: "validate a plan and show its resolved values",
+    Long: `Load the plan of the config folder with the --env profile and the overrides
+and print the resolved plan fields and variables with where they come from,
+secret values masked. Literal credentials found in the plan are reported on
+stderr.
+From lowest to highest precedence: the plan, the environment profile, the
+GOMMANDER_* environment variables and the --var flags.`,
+    Run: func(cmd *cobra.Command, args []string) {
+        cnf := load()
+        p := cnf.Plan
+        fmt.Printf("Plan %s is valid: %d steps, %d tasks, %d requests\n\n", p.Name, len(cnf.Steps), len(cnf.Tasks), len(cnf.Requests))
+        
+        w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        fields := []struct {
+            name  string
+            value string
+        }{
+            {"type", p.Type},
+            {"name", p.Name},
+            {"url", p.URL},
+            {"path", p.Path},
+            {"authType", p.AuthType},
+            {"authUser", p.AuthUser},
+            {"authPass", p.AuthPass},
+            {"authEndpoint", p.AuthEndpoint},
+        }
+        for _, f := range fields {
+            fmt.Fprintf(w, "%s\t%s\t%s\n", f.name, secret.Mask(f.value), source(cnf.Sources[f.name]))
+        }
+        
+        var vars []string
+        for name := range p.Vars {
+            vars = append(vars, name)
+        }
+        sort.Strings(vars)
+        for _, name := range vars {
+            fmt.Fprintf(w, "vars.%s\t%s\t%s\n", name, secret.Mask(fmt.Sprint(p.Vars[name])), source(cnf.Sources["vars."+name]))
+        }
+        w.Flush()
+    },
+}
+
+func source(s string) string {
+    if s == "" {
+        return "(unset)"
+    }
+    return "(" + strings.TrimSpace(s) + ")"
+}
+
+func init() {
+    RootCmd.AddCommand(validateCmd)
+}
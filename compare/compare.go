@@ -0,0 +1,307 @@
+// Package compare diffs the results of two runs of a plan task by task and
+// tells whether the current one regressed from the baseline. A metric
+// regresses when it is worse beyond its tolerance and the difference is
+// significant: a Mann-Whitney U test on the latencies and on the rates of
+// the time series intervals, a two-proportion z test on the error rates.
+package compare
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "sort"
+    "text/tabwriter"
+    "time"
+    
+    "github.com/jarlex/gommander/metrics"
+)
+
+// Tolerances are the changes accepted before a metric regresses, latencies
+// and throughput in percent of the baseline, error rate in percentage points.
+type Tolerances struct {
+    P50        float64
+    P95        float64
+    P99        float64
+    Throughput float64
+    ErrorRate  float64
+    Alpha      float64 // Significance level of the tests
+}
+
+// DefaultTolerances are the tolerances of the compare command.
+var DefaultTolerances = Tolerances{P50: 10, P95: 10, P99: 20, Throughput: 10, ErrorRate: 1, Alpha: 0.05}
+
+// Diff is the comparison of two results.
+type Diff struct {
+    Tasks []TaskDiff
+}
+
+// TaskDiff compares a task of a step, Baseline or Current is nil when the
+// task only ran in the other result.
+type TaskDiff struct {
+    Step     string
+    Task     string
+    Baseline *metrics.TaskResult
+    Current  *metrics.TaskResult
+    Metrics  []MetricDiff
+}
+
+// MetricDiff compares a metric of a task.
+type MetricDiff struct {
+    Name      string
+    Baseline  float64
+    Current   float64
+    Change    float64 // Percent of the baseline, percentage points for the error rate
+    PValue    float64 // Of the test that current is worse, -1 when not tested
+    Regressed bool
+}
+
+// Regressed reports whether any metric regressed or any task of the
+// baseline did not run.
+func (d *Diff) Regressed() bool {
+    for _, t := range d.Tasks {
+        if t.Regressed() {
+            return true
+        }
+    }
+    return false
+}
+
+// Regressed reports whether a metric of the task regressed or the task did
+// not run.
+func (t *TaskDiff) Regressed() bool {
+    if t.Current == nil && t.Baseline != nil {
+        return true
+    }
+    for _, m := range t.Metrics {
+        if m.Regressed {
+            return true
+        }
+    }
+    return false
+}
+
+// Results compares current with baseline task by task.
+func Results(baseline, current *metrics.Result, tol Tolerances) *Diff {
+    d := &Diff{}
+    seen := make(map[[2]string]bool)
+    for _, bs := range baseline.Steps {
+        for _, bt := range bs.Tasks {
+            seen[[2]string{bs.Name, bt.Name}] = true
+            td := TaskDiff{Step: bs.Name, Task: bt.Name, Baseline: bt}
+            if cs := current.Step(bs.Name); cs != nil {
+                td.Current = cs.Task(bt.Name)
+            }
+            if td.Current != nil {
+                td.Metrics = metricDiffs(bt, td.Current, tol)
+            }
+            d.Tasks = append(d.Tasks, td)
+        }
+    }
+    for _, cs := range current.Steps {
+        for _, ct := range cs.Tasks {
+            if !seen[[2]string{cs.Name, ct.Name}] {
+                d.Tasks = append(d.Tasks, TaskDiff{Step: cs.Name, Task: ct.Name, Current: ct})
+            }
+        }
+    }
+    return d
+}
+
+func metricDiffs(b, c *metrics.TaskResult, tol Tolerances) []MetricDiff {
+    // The latencies are tested once, the percentiles only tell how much
+    pLatency := -1.0
+    if len(b.Latencies) > 0 && len(c.Latencies) > 0 {
+        pLatency = MannWhitney(millis(b.Latencies), millis(c.Latencies))
+    }
+    latency := func(name string, bv, cv time.Duration, tolerance float64) MetricDiff {
+        m := MetricDiff{Name: name, Baseline: ms(bv), Current: ms(cv), Change: change(float64(bv), float64(cv)), PValue: pLatency}
+        // Without latencies, from older results, the tolerance decides alone
+        m.Regressed = bv > 0 && cv > 0 && m.Change > tolerance && (pLatency < 0 || pLatency < tol.Alpha)
+        return m
+    }
+    diffs := []MetricDiff{
+        latency("p50", b.P50, c.P50, tol.P50),
+        latency("p95", b.P95, c.P95, tol.P95),
+        latency("p99", b.P99, c.P99, tol.P99),
+    }
+    
+    throughput := MetricDiff{Name: "throughput", Baseline: b.Throughput, Current: c.Throughput, Change: change(b.Throughput, c.Throughput), PValue: -1}
+    if len(b.Series) > 0 && len(c.Series) > 0 {
+        // Lower rates are worse, test that the baseline ones are greater
+        throughput.PValue = MannWhitney(rates(c.Series), rates(b.Series))
+    }
+    // Without series, from older results, the tolerance decides alone
+    throughput.Regressed = -throughput.Change > tol.Throughput && (throughput.PValue < 0 || throughput.PValue < tol.Alpha)
+    
+    bRate, cRate := errorRate(b), errorRate(c)
+    errors := MetricDiff{Name: "error rate", Baseline: bRate, Current: cRate, Change: cRate - bRate}
+    errors.PValue = TwoProportions(b.Failures, b.Count, c.Failures, c.Count)
+    errors.Regressed = errors.Change > tol.ErrorRate && errors.PValue < tol.Alpha
+    return append(diffs, throughput, errors)
+}
+
+func ms(d time.Duration) float64 {
+    return float64(d) / float64(time.Millisecond)
+}
+
+func millis(durations []time.Duration) []float64 {
+    out := make([]float64, len(durations))
+    for i, d := range durations {
+        out[i] = ms(d)
+    }
+    return out
+}
+
+// rates returns the rate of each interval of series.
+func rates(series []metrics.Point) []float64 {
+    out := make([]float64, len(series))
+    for i, p := range series {
+        out[i] = p.Rate
+    }
+    return out
+}
+
+// change returns the change from b to c in percent of b.
+func change(b, c float64) float64 {
+    if b == 0 {
+        if c == 0 {
+            return 0
+        }
+        return math.Inf(1)
+    }
+    return (c - b) / b * 100
+}
+
+// errorRate returns the failures of t in percent.
+func errorRate(t *metrics.TaskResult) float64 {
+    if t.Count == 0 {
+        return 0
+    }
+    return float64(t.Failures) / float64(t.Count) * 100
+}
+
+// MannWhitney returns the one-sided p-value of the Mann-Whitney U test that
+// the current values tend to be greater than the baseline ones, with the
+// normal approximation corrected for ties and continuity.
+func MannWhitney(baseline, current []float64) float64 {
+    type value struct {
+        d       float64
+        current bool
+    }
+    if len(baseline) == 0 || len(current) == 0 {
+        return 1
+    }
+    all := make([]value, 0, len(baseline)+len(current))
+    for _, d := range baseline {
+        all = append(all, value{d: d})
+    }
+    for _, d := range current {
+        all = append(all, value{d: d, current: true})
+    }
+    sort.Slice(all, func(i, j int) bool { return all[i].d < all[j].d })
+    
+    n1, n2 := float64(len(baseline)), float64(len(current))
+    n := n1 + n2
+    var rankSum, ties float64
+    for i := 0; i < len(all); {
+        j := i
+        for j < len(all) && all[j].d == all[i].d {
+            j++
+        }
+        // Tied values share the mean of their ranks
+        rank := float64(i+j+1) / 2
+        for k := i; k < j; k++ {
+            if all[k].current {
+                rankSum += rank
+            }
+        }
+        t := float64(j - i)
+        ties += t*t*t - t
+        i = j
+    }
+    u := rankSum - n2*(n2+1)/2
+    mean := n1 * n2 / 2
+    variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+    if variance <= 0 {
+        // Every value is the same
+        return 1
+    }
+    z := (u - mean - 0.5) / math.Sqrt(variance)
+    return upperTail(z)
+}
+
+// TwoProportions returns the one-sided p-value of the z test that the
+// failure rate of current is greater than the baseline one.
+func TwoProportions(bFailures, bCount, cFailures, cCount int) float64 {
+    if bCount == 0 || cCount == 0 {
+        return 1
+    }
+    p1 := float64(bFailures) / float64(bCount)
+    p2 := float64(cFailures) / float64(cCount)
+    pooled := float64(bFailures+cFailures) / float64(bCount+cCount)
+    se := math.Sqrt(pooled * (1 - pooled) * (1/float64(bCount) + 1/float64(cCount)))
+    if se == 0 {
+        return 1
+    }
+    return upperTail((p2 - p1) / se)
+}
+
+// upperTail returns P(Z > z) of the standard normal distribution.
+func upperTail(z float64) float64 {
+    return math.Erfc(z/math.Sqrt2) / 2
+}
+
+// Write writes d as a table, a row by metric, the regressions marked.
+func (d *Diff) Write(w io.Writer) error {
+    tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(tw, "STEP/TASK\tMETRIC\tBASELINE\tCURRENT\tCHANGE\tP-VALUE\t")
+    for _, t := range d.Tasks {
+        name := t.Step + "/" + t.Task
+        switch {
+        case t.Current == nil:
+            fmt.Fprintf(tw, "%s\t\t\t\t\t\tREGRESSION: not run\n", name)
+            continue
+        case t.Baseline == nil:
+            fmt.Fprintf(tw, "%s\t\t\t\t\t\tnew\n", name)
+            continue
+        }
+        for _, m := range t.Metrics {
+            mark := ""
+            if m.Regressed {
+                mark = "REGRESSION"
+            }
+            fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name, m.Name, value(m.Name, m.Baseline), value(m.Name, m.Current), fmtChange(m), fmtP(m.PValue), mark)
+        }
+    }
+    return tw.Flush()
+}
+
+func value(metric string, v float64) string {
+    switch metric {
+    case "throughput":
+        return fmt.Sprintf("%.1f/s", v)
+    case "error rate":
+        return fmt.Sprintf("%.2f%%", v)
+    }
+    return fmt.Sprintf("%.2fms", v)
+}
+
+func fmtChange(m MetricDiff) string {
+    if m.Name == "error rate" {
+        return fmt.Sprintf("%+.2fpp", m.Change)
+    }
+    if math.IsInf(m.Change, 1) {
+        return "+inf"
+    }
+    return fmt.Sprintf("%+.1f%%", m.Change)
+}
+
+func fmtP(p float64) string {
+    if p < 0 {
+        return "-"
+    }
+    if p < 0.001 {
+        return "<0.001"
+    }
+    return fmt.Sprintf("%.3f", p)
+}
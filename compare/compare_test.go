@@ -0,0 +1,163 @@
+package compare
+
+import (
+    "math"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+    
+    "github.com/jarlex/gommander/metrics"
+)
+
+func TestMannWhitney(t *testing.T) {
+    tests := []struct {
+        name              string
+        baseline, current []float64
+        want              float64
+    }{
+        {"greater", []float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 0.00609},
+        {"lower", []float64{6, 7, 8, 9, 10}, []float64{1, 2, 3, 4, 5}, 0.99669},
+        {"ties", []float64{1, 1, 2, 2}, []float64{2, 2, 3, 3}, 0.04318},
+        {"all equal", []float64{3, 3, 3}, []float64{3, 3}, 1},
+        {"no baseline", nil, []float64{1, 2}, 1},
+        {"no current", []float64{1, 2}, nil, 1},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := MannWhitney(tt.baseline, tt.current); math.Abs(got-tt.want) > 1e-5 {
+                t.Errorf("MannWhitney() = %.5f, want %.5f", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestTwoProportions(t *testing.T) {
+    tests := []struct {
+        name                                 string
+        bFailures, bCount, cFailures, cCount int
+        want                                 float64
+    }{
+        {"more failures", 0, 100, 10, 100, 0.00059},
+        {"same rate", 5, 100, 5, 100, 0.5},
+        {"no failures", 0, 100, 0, 100, 1},
+        {"all failures", 100, 100, 100, 100, 1},
+        {"empty baseline", 0, 0, 5, 100, 1},
+        {"empty current", 5, 100, 0, 0, 1},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := TwoProportions(tt.bFailures, tt.bCount, tt.cFailures, tt.cCount); math.Abs(got-tt.want) > 1e-5 {
+                t.Errorf("TwoProportions() = %.5f, want %.5f", got, tt.want)
+            }
+        })
+    }
+}
+
+// task returns the result of a task whose latencies are spread around
+// latency, count samples of which failures failed, rate samples per second
+// in 10 intervals.
+func task(latency time.Duration, count, failures int, rate float64) *metrics.TaskResult {
+    tr := &metrics.TaskResult{Name: "t", Count: count, Failures: failures, Throughput: rate}
+    for i := 0; i < 200; i++ {
+        tr.Latencies = append(tr.Latencies, latency+time.Duration(i%20)*latency/100)
+    }
+    tr.P50, tr.P95, tr.P99 = latency, latency*6/5, latency*5/4
+    for i := 0; i < 10; i++ {
+        tr.Series = append(tr.Series, metrics.Point{Rate: rate + float64(i%3)})
+    }
+    return tr
+}
+
+func result(tasks ...*metrics.TaskResult) *metrics.Result {
+    return &metrics.Result{Steps: []*metrics.StepResult{{Name: "s", Tasks: tasks}}}
+}
+
+func TestResults(t *testing.T) {
+    base := task(100*time.Millisecond, 1000, 10, 50)
+    tests := []struct {
+        name      string
+        current   *metrics.TaskResult
+        regressed []string
+    }{
+        {"same", task(100*time.Millisecond, 1000, 10, 50), nil},
+        {"within tolerance", task(105*time.Millisecond, 1000, 15, 47), nil},
+        {"slower", task(150*time.Millisecond, 1000, 10, 50), []string{"p50", "p95", "p99"}},
+        {"faster", task(50*time.Millisecond, 1000, 10, 50), nil},
+        {"more errors", task(100*time.Millisecond, 1000, 50, 50), []string{"error rate"}},
+        {"lower throughput", task(100*time.Millisecond, 1000, 10, 30), []string{"throughput"}},
+        {"higher throughput", task(100*time.Millisecond, 1000, 10, 80), nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            d := Results(result(base), result(tt.current), DefaultTolerances)
+            if len(d.Tasks) != 1 {
+                t.Fatalf("%d tasks compared", len(d.Tasks))
+            }
+            var regressed []string
+            for _, m := range d.Tasks[0].Metrics {
+                if m.Regressed {
+                    regressed = append(regressed, m.Name)
+                }
+            }
+            if !reflect.DeepEqual(regressed, tt.regressed) {
+                t.Errorf("regressed %q, want %q", regressed, tt.regressed)
+            }
+            if d.Regressed() != (tt.regressed != nil) {
+                t.Errorf("Regressed() = %v", d.Regressed())
+            }
+        })
+    }
+}
+
+func TestResultsSignificance(t *testing.T) {
+    // Beyond the tolerances, but too few samples to be significant
+    b := &metrics.TaskResult{Name: "t", Count: 20, Failures: 0, P50: 100, P95: 100, P99: 100,
+        Latencies: []time.Duration{90, 110}, Series: []metrics.Point{{Rate: 10}, {Rate: 12}}, Throughput: 11}
+    c := &metrics.TaskResult{Name: "t", Count: 20, Failures: 1, P50: 130, P95: 130, P99: 130,
+        Latencies: []time.Duration{100, 160}, Series: []metrics.Point{{Rate: 8}, {Rate: 9}}, Throughput: 8.5}
+    if d := Results(result(b), result(c), DefaultTolerances); d.Regressed() {
+        t.Errorf("non significant changes regressed: %+v", d.Tasks[0].Metrics)
+    }
+    
+    // Older results without latencies nor series, the tolerances decide
+    b.Latencies, b.Series, c.Latencies, c.Series = nil, nil, nil, nil
+    d := Results(result(b), result(c), DefaultTolerances)
+    var regressed []string
+    for _, m := range d.Tasks[0].Metrics {
+        if m.Regressed {
+            regressed = append(regressed, m.Name)
+        }
+        if m.Name != "error rate" && m.PValue != -1 {
+            t.Errorf("%s tested without samples", m.Name)
+        }
+    }
+    if want := []string{"p50", "p95", "p99", "throughput"}; !reflect.DeepEqual(regressed, want) {
+        t.Errorf("regressed %q, want %q", regressed, want)
+    }
+}
+
+func TestResultsTasks(t *testing.T) {
+    a, b := task(time.Millisecond, 10, 0, 5), task(time.Millisecond, 10, 0, 5)
+    b.Name = "other"
+    d := Results(result(a), result(b), DefaultTolerances)
+    if len(d.Tasks) != 2 {
+        t.Fatalf("%d tasks compared, want 2", len(d.Tasks))
+    }
+    if d.Tasks[0].Task != "t" || d.Tasks[0].Current != nil || !d.Tasks[0].Regressed() {
+        t.Errorf("a task not run does not regress: %+v", d.Tasks[0])
+    }
+    if d.Tasks[1].Task != "other" || d.Tasks[1].Baseline != nil || d.Tasks[1].Regressed() {
+        t.Errorf("a new task regresses: %+v", d.Tasks[1])
+    }
+    
+    var out strings.Builder
+    if err := d.Write(&out); err != nil {
+        t.Fatal(err)
+    }
+    for _, want := range []string{"s/t", "REGRESSION: not run", "s/other", "new"} {
+        if !strings.Contains(out.String(), want) {
+            t.Errorf("table misses %q:\n%s", want, out.String())
+        }
+    }
+}
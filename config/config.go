@@ -0,0 +1,221 @@
+package config
+
+import (
+    "errors"
+    "fmt"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    
+    "github.com/jarlex/gommander/feeder"
+    "github.com/jarlex/gommander/plan"
+    "github.com/jarlex/gommander/request"
+    "github.com/jarlex/gommander/secret"
+    "github.com/jarlex/gommander/step"
+    "github.com/jarlex/gommander/task"
+)
+
+type Config struct {
+    Plan     *plan.Plan
+    Steps    map[string]*step.Step
+    Tasks    map[string]*task.Task
+    Requests map[string]*request.Request
+    Sources  map[string]string // Where each plan field and variable got its value
+    Warnings []string          // Literal credentials found in the plan documents
+    secrets  map[string]string // Secret references by the value they resolved to, restored by Save
+}
+
+// Loader loads plan folders, see Load. Its zero value loads them as they are.
+type Loader struct {
+    PlanFile string            // Plan file name, found by Load when empty
+    Env      string            // Environment profile applied to the plan
+    Vars     map[string]string // Variables overriding the plan ones
+    Environ  []string          // Environment, as os.Environ, read for GOMMANDER_* overrides
+    dir      string            // Plan folder on disk, set by Read to resolve the imports outside of it
+}
+
+// planFiles are the plan files looked for when none is given.
+var planFiles = []string{"plan.json", "plan.yaml", "plan.yml"}
+
+// Read loads the plan folder planFolder, see Load. planFolder can also be the
+// path of the plan file itself.
+func Read(planFolder string, planFilename ...string) (*Config, error) {
+    var l Loader
+    if len(planFilename) > 0 {
+        l.PlanFile = planFilename[0]
+    }
+    return l.Read(planFolder)
+}
+
+// Read loads the plan folder planFolder, which can also be the path of the
+// plan file itself.
+func (l Loader) Read(planFolder string) (*Config, error) {
+    if info, err := os.Stat(planFolder); err == nil && !info.IsDir() && l.PlanFile == "" {
+        l.PlanFile = filepath.Base(planFolder)
+        planFolder = filepath.Dir(planFolder)
+    }
+    l.dir = planFolder
+    return l.Load(os.DirFS(planFolder))
+}
+
+// Load loads a plan folder from fsys: every file of the requests/, tasks/ and
+// steps/ folders, when present, then the plan file, the first of plan.json,
+// plan.yaml and plan.yml unless planFilename is given.
+//
+// Files ending in .yaml or .yml are YAML documents, any other one is JSON.
+// Wherever a request, task or step is referenced by name its definition can
+// be written inline instead, so a whole plan fits in a single file:
+//
+//	name: orders
+//	url: http://localhost:8080
+//	requests:
+//	  - {name: listOrders, method: GET, path: /orders}
+//	steps:
+//	  - name: browse
+//	    numPetitions: 100
+//	    concurrentUsers: 10
+//	    tasks:
+//	      - {name: list, expectedStatus: 200, request: listOrders}
+//	      - name: get
+//	        expectedStatus: 200
+//	        request: {name: getOrder, method: GET, path: /orders/1}
+//
+// Inline definitions and the ones of the folders share their names, so an
+// inline task can use a request of the requests/ folder. Shared requests and
+// tasks are imported from other folders with the imports key, see
+// Loader.imports:
+//
+//	imports:
+//	  auth: ../shared/auth
+//	setup: [auth.login]
+func Load(fsys fs.FS, planFilename ...string) (*Config, error) {
+    var l Loader
+    if len(planFilename) > 0 {
+        l.PlanFile = planFilename[0]
+    }
+    return l.Load(fsys)
+}
+
+// Load loads a plan folder from fsys, see the Load function, then applies the
+// environment profile and the overrides of l, see Loader.resolve. Secret
+// references, ${env:NAME} and ${file:path}, are replaced by their values,
+// which are registered with the secret package along with the plan authPass.
+func (l Loader) Load(fsys fs.FS) (*Config, error) {
+    defs := newDefinitions(fsys)
+    var warnings []string
+    
+    // Read all Requests, Tasks and Steps
+    folders := []struct {
+        dir    string
+        inline func(v interface{}) (interface{}, error)
+    }{
+        {"requests", defs.request},
+        {"tasks", defs.task},
+        {"steps", defs.step},
+    }
+    for _, folder := range folders {
+        err := readDir(fsys, folder.dir, func(name string, raw []byte) error {
+            doc, err := decode(name, raw)
+            if err != nil {
+                return err
+            }
+            warnings = append(warnings, lint(name, doc)...)
+            doc, err = expand(fsys, doc, defs.secrets)
+            if err != nil {
+                return err
+            }
+            _, err = folder.inline(doc)
+            return err
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+    
+    // Read Plan
+    planFile := l.PlanFile
+    if planFile == "" {
+        for _, name := range planFiles {
+            if _, err := fs.Stat(fsys, name); err == nil {
+                planFile = name
+                break
+            }
+        }
+        if planFile == "" {
+            return nil, fmt.Errorf("no plan file found, looked for %v", planFiles)
+        }
+    }
+    raw, err := fs.ReadFile(fsys, planFile)
+    if err != nil {
+        return nil, err
+    }
+    doc, err := decode(planFile, raw)
+    if err != nil {
+        return nil, err
+    }
+    warnings = append(warnings, lint(planFile, doc)...)
+    sources, err := l.resolve(fsys, doc, defs.params(), &warnings)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %s", planFile, err.Error())
+    }
+    doc, err = expand(fsys, doc, defs.secrets)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %s", planFile, err.Error())
+    }
+    if err := l.imports(fsys, planFile, doc.(map[string]interface{}), defs, &warnings); err != nil {
+        return nil, fmt.Errorf("%s: %s", planFile, err.Error())
+    }
+    planRaw, err := defs.plan(doc)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %s", planFile, err.Error())
+    }
+    
+    conf, err := defs.parse()
+    if err != nil {
+        return nil, err
+    }
+    conf.Sources = sources
+    conf.Warnings = warnings
+    conf.secrets = defs.secrets
+    conf.Plan, err = plan.Parse(planRaw, conf.Steps, conf.Tasks)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %s", planFile, err.Error())
+    }
+    if conf.Plan.FeederFile != "" {
+        file := path.Clean(filepath.ToSlash(conf.Plan.FeederFile))
+        if conf.Plan.Feeder, err = feeder.Load(fsys, file); err != nil {
+            return nil, fmt.Errorf("%s: feeder %s: %s", planFile, conf.Plan.FeederFile, err.Error())
+        }
+    }
+    secret.Register(conf.Plan.AuthPass)
+    
+    return conf, nil
+}
+
+// readDir calls parse with the name and content of every file of dir, if
+// dir exists.
+func readDir(fsys fs.FS, dir string, parse func(name string, raw []byte) error) error {
+    files, err := fs.ReadDir(fsys, dir)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    
+    for _, f := range files {
+        if f.IsDir() {
+            continue
+        }
+        name := dir + "/" + f.Name()
+        raw, err := fs.ReadFile(fsys, name)
+        if err != nil {
+            return err
+        }
+        if err := parse(name, raw); err != nil {
+            return fmt.Errorf("%s: %s", name, err.Error())
+        }
+    }
+    return nil
+}
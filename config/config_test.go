@@ -0,0 +1,217 @@
+package config
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+    "testing/fstest"
+)
+
+var orders = fstest.MapFS{
+    "requests/list.json": {Data: []byte(`{"name": "listOrders", "method": "GET", "path": "/orders"}`)},
+    "tasks/list.yaml":    {Data: []byte("name: list\nexpectedStatus: 200\nrequest: listOrders\n")},
+}
+
+func TestLoad(t *testing.T) {
+    tests := []struct {
+        name  string
+        fsys  fstest.MapFS
+        plan  string
+        steps map[string][]string
+        paths map[string]string
+    }{
+        {
+            name: "json folder",
+            fsys: fstest.MapFS{
+                "requests/list.json": orders["requests/list.json"],
+                "tasks/list.json":    {Data: []byte(`{"name": "list", "expectedStatus": 200, "request": "listOrders"}`)},
+                "steps/browse.json":  {Data: []byte(`{"name": "browse", "numPetitions": 1, "concurrentUsers": 1, "tasks": ["list"]}`)},
+                "plan.json":          {Data: []byte(`{"name": "orders", "steps": ["browse"]}`)},
+            },
+            steps: map[string][]string{"browse": {"list"}},
+            paths: map[string]string{"list": "/orders"},
+        },
+        {
+            name: "yaml single file",
+            fsys: fstest.MapFS{
+                "plan.yml": {Data: []byte(`
+name: orders
+requests:
+  - {name: listOrders, method: GET, path: /orders}
+steps:
+  - name: browse
+    numPetitions: 1
+    concurrentUsers: 1
+    tasks:
+      - {name: list, expectedStatus: 200, request: listOrders}
+      - name: get
+        expectedStatus: 200
+        request: {name: getOrder, method: GET, path: /orders/1}
+`)},
+            },
+            steps: map[string][]string{"browse": {"list", "get"}},
+            paths: map[string]string{"list": "/orders", "get": "/orders/1"},
+        },
+        {
+            name: "inline using folders",
+            fsys: fstest.MapFS{
+                "requests/list.json": orders["requests/list.json"],
+                "tasks/list.yaml":    orders["tasks/list.yaml"],
+                "orders.yaml": {Data: []byte(`
+name: orders
+tasks:
+  - {name: again, expectedStatus: 200, request: listOrders}
+steps:
+  - {name: browse, numPetitions: 1, concurrentUsers: 1, tasks: [list, again]}
+`)},
+            },
+            plan:  "orders.yaml",
+            steps: map[string][]string{"browse": {"list", "again"}},
+            paths: map[string]string{"list": "/orders", "again": "/orders"},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var planFile []string
+            if tt.plan != "" {
+                planFile = append(planFile, tt.plan)
+            }
+            conf, err := Load(tt.fsys, planFile...)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if conf.Plan.Name != "orders" {
+                t.Errorf("plan name = %s", conf.Plan.Name)
+            }
+            for name, tasks := range tt.steps {
+                s := conf.Steps[name]
+                if s == nil {
+                    t.Fatalf("step %s not loaded", name)
+                }
+                if !reflect.DeepEqual(s.TasksNames, tasks) {
+                    t.Errorf("step %s tasks = %q, want %q", name, s.TasksNames, tasks)
+                }
+            }
+            for name, path := range tt.paths {
+                if got := conf.Tasks[name].Request.Path; got != path {
+                    t.Errorf("task %s path = %s, want %s", name, got, path)
+                }
+            }
+        })
+    }
+}
+
+func TestLoadErrors(t *testing.T) {
+    tests := []struct {
+        name string
+        fsys fstest.MapFS
+        err  string
+    }{
+        {"no plan file", fstest.MapFS{"requests/list.json": orders["requests/list.json"]}, "no plan file found"},
+        {"plan not an object", fstest.MapFS{"plan.yaml": {Data: []byte("- a\n")}}, "plan.yaml: the plan must be an object"},
+        {"steps not a list", fstest.MapFS{"plan.yaml": {Data: []byte("name: p\nsteps: browse\n")}}, "steps must be a list"},
+        {"inline without name", fstest.MapFS{
+            "plan.yaml": {Data: []byte("name: p\nsteps:\n  - {numPetitions: 1}\n")},
+        }, "step without name"},
+        {"defined twice", fstest.MapFS{
+            "requests/list.json": orders["requests/list.json"],
+            "plan.yaml":          {Data: []byte("name: p\nrequests:\n  - {name: listOrders, method: GET, path: /}\n")},
+        }, "request listOrders defined twice"},
+        {"inline request without name", fstest.MapFS{
+            "plan.yaml": {Data: []byte("name: p\ntasks:\n  - {name: t, request: {method: GET}}\n")},
+        }, "task t: request without name"},
+        {"bad threshold", fstest.MapFS{
+            "plan.yaml": {Data: []byte("name: p\nsteps: []\nthresholds:\n  - {metric: p95, max: fast}\n")},
+        }, "plan.yaml: plan p: threshold p95: fast is not a duration"},
+        {"bad yaml", fstest.MapFS{"tasks/list.yaml": {Data: []byte("name: [\n")}, "plan.yaml": {Data: []byte("name: p\n")}}, "tasks/list.yaml"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := Load(tt.fsys)
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Fatalf("error = %v, want %q", err, tt.err)
+            }
+        })
+    }
+}
+
+func TestLoadSchemaFile(t *testing.T) {
+    fsys := fstest.MapFS{
+        "schemas/order.json": {Data: []byte(`{"type": "object", "required": ["id"], "properties": {"items": {"type": "array", "items": {"$ref": "item.yaml"}}}}`)},
+        "schemas/item.yaml":  {Data: []byte("type: object\nproperties: {id: {type: integer}}\n")},
+        "shared/lib/tasks/get.yaml": {Data: []byte(`
+name: get
+schemaFile: schemas/id.json
+request: {name: get, method: GET, path: /}
+`)},
+        "shared/lib/schemas/id.json": {Data: []byte(`{"type": "object", "required": ["id"]}`)},
+        "plan.yaml": {Data: []byte(`
+name: p
+imports: {lib: shared/lib}
+tasks:
+  - {name: order, schemaFile: schemas/order.json, request: {name: order, method: GET, path: /}}
+steps: []
+`)},
+    }
+    conf, err := Load(fsys)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        task   string
+        body   string
+        errors int
+    }{
+        {"order", `{"id": 1, "items": [{"id": 2}]}`, 0},
+        {"order", `{"items": [{"id": "2"}, {"id": 3}]}`, 2},
+        {"lib.get", `{"id": 1}`, 0},
+        {"lib.get", `{}`, 1},
+    }
+    for _, tt := range tests {
+        schema := conf.Tasks[tt.task].ResponseSchema
+        if schema == nil {
+            t.Fatalf("task %s has no schema", tt.task)
+        }
+        var doc interface{}
+        if err := json.Unmarshal([]byte(tt.body), &doc); err != nil {
+            t.Fatal(err)
+        }
+        if errs := schema.Validate(doc); len(errs) != tt.errors {
+            t.Errorf("%s validates %s with %v, want %d errors", tt.task, tt.body, errs, tt.errors)
+        }
+    }
+    
+    errTests := []struct {
+        name, plan, err string
+    }{
+        {"missing file", "tasks:\n  - {name: t, schemaFile: nope.json, request: {name: r, method: GET, path: /}}\n", "task t: schemaFile nope.json: "},
+        {"both", "tasks:\n  - {name: t, schema: {}, schemaFile: schemas/order.json, request: {name: r, method: GET, path: /}}\n", "task t has both a schema and a schemaFile"},
+    }
+    for _, tt := range errTests {
+        fsys["plan.yaml"] = &fstest.MapFile{Data: []byte("name: p\nsteps: []\n" + tt.plan)}
+        _, err := Load(fsys)
+        if err == nil || !strings.Contains(err.Error(), tt.err) {
+            t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+        }
+    }
+}
+
+func TestLoadFeeder(t *testing.T) {
+    fsys := fstest.MapFS{
+        "data/users.csv": {Data: []byte("user,pass\nann,a1\nbob,b2\n")},
+        "plan.yaml":      {Data: []byte("name: p\nfeeder: ./data/users.csv\nsteps: []\n")},
+    }
+    conf, err := Load(fsys)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if f := conf.Plan.Feeder; f.Len() != 2 || f.Row(1)["user"] != "bob" {
+        t.Errorf("feeder rows = %v, %v", f.Row(0), f.Row(1))
+    }
+    
+    fsys["plan.yaml"] = &fstest.MapFile{Data: []byte("name: p\nfeeder: data/nope.csv\nsteps: []\n")}
+    if _, err := Load(fsys); err == nil || !strings.Contains(err.Error(), "plan.yaml: feeder data/nope.csv: ") {
+        t.Errorf("error = %v, want the feeder file not found", err)
+    }
+}
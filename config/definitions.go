@@ -0,0 +1,241 @@
+package config
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/fs"
+    "path"
+    "path/filepath"
+    "strings"
+    
+    "github.com/jarlex/gommander/jsonschema"
+    "github.com/jarlex/gommander/request"
+    "github.com/jarlex/gommander/step"
+    "github.com/jarlex/gommander/task"
+    "gopkg.in/yaml.v3"
+)
+
+// decode decodes a plan document, YAML or JSON depending on the extension of
+// name, into generic values.
+func decode(name string, raw []byte) (interface{}, error) {
+    var doc interface{}
+    switch strings.ToLower(path.Ext(name)) {
+    case ".yaml", ".yml":
+        if err := yaml.Unmarshal(raw, &doc); err != nil {
+            return nil, err
+        }
+    default:
+        if err := json.Unmarshal(raw, &doc); err != nil {
+            return nil, err
+        }
+    }
+    return doc, nil
+}
+
+// definitions holds the requests, tasks and steps of a plan, as JSON, while
+// the plan documents are read. Inline definitions are moved here and replaced
+// by their names.
+type definitions struct {
+    requests map[string][]byte
+    tasks    map[string][]byte
+    steps    map[string][]byte
+    schemas  map[string]*jsonschema.Schema // Schema files of the tasks by task name
+    fsys     fs.FS                         // Folder the schema files are read from
+    secrets  map[string]string             // Secret references by the value they resolved to
+}
+
+func newDefinitions(fsys fs.FS) *definitions {
+    return &definitions{
+        requests: make(map[string][]byte),
+        tasks:    make(map[string][]byte),
+        steps:    make(map[string][]byte),
+        schemas:  make(map[string]*jsonschema.Schema),
+        fsys:     fsys,
+        secrets:  make(map[string]string),
+    }
+}
+
+// add stores the definition def of kind and returns its name.
+func (d *definitions) add(kind string, defs map[string][]byte, def map[string]interface{}) (string, error) {
+    name, ok := def["name"].(string)
+    if !ok || name == "" {
+        return "", fmt.Errorf("%s without name", kind)
+    }
+    if defs[name] != nil {
+        return "", fmt.Errorf("%s %s defined twice", kind, name)
+    }
+    raw, err := json.Marshal(def)
+    if err != nil {
+        return "", err
+    }
+    defs[name] = raw
+    return name, nil
+}
+
+// request stores v if it is an inline request and returns its name.
+func (d *definitions) request(v interface{}) (interface{}, error) {
+    def, ok := v.(map[string]interface{})
+    if !ok {
+        return v, nil
+    }
+    return d.add("request", d.requests, def)
+}
+
+// task stores v, and its inline request, if it is an inline task and
+// returns its name.
+func (d *definitions) task(v interface{}) (interface{}, error) {
+    def, ok := v.(map[string]interface{})
+    if !ok {
+        return v, nil
+    }
+    if r, ok := def["request"]; ok {
+        name, err := d.request(r)
+        if err != nil {
+            return nil, fmt.Errorf("task %v: %s", def["name"], err.Error())
+        }
+        def["request"] = name
+    }
+    name, err := d.add("task", d.tasks, def)
+    if err != nil {
+        return nil, err
+    }
+    if file, ok := def["schemaFile"].(string); ok {
+        schema, err := d.schemaFile(file)
+        if err != nil {
+            return nil, fmt.Errorf("task %s: schemaFile %s: %s", name, file, err.Error())
+        }
+        d.schemas[name] = schema
+    }
+    return name, nil
+}
+
+// schemaFile loads the JSON Schema file, JSON or YAML, and the files it
+// references, relative to the folder of the definitions.
+func (d *definitions) schemaFile(file string) (*jsonschema.Schema, error) {
+    fsys := d.fsys
+    return jsonschema.Load(path.Clean(filepath.ToSlash(file)), func(name string) (interface{}, error) {
+        raw, err := fs.ReadFile(fsys, name)
+        if err != nil {
+            return nil, err
+        }
+        return decode(name, raw)
+    })
+}
+
+// params returns the names of the params of the requests and tasks read so
+// far, see collectParams.
+func (d *definitions) params() map[string]bool {
+    params := make(map[string]bool)
+    for _, defs := range []map[string][]byte{d.requests, d.tasks} {
+        for _, raw := range defs {
+            var doc interface{}
+            if json.Unmarshal(raw, &doc) == nil {
+                collectParams(doc, params)
+            }
+        }
+    }
+    return params
+}
+
+// step stores v, and its inline tasks, if it is an inline step and returns
+// its name.
+func (d *definitions) step(v interface{}) (interface{}, error) {
+    def, ok := v.(map[string]interface{})
+    if !ok {
+        return v, nil
+    }
+    for _, key := range []string{"tasks", "setup", "teardown"} {
+        if err := inlineList(def, key, d.task); err != nil {
+            return nil, fmt.Errorf("step %v: %s", def["name"], err.Error())
+        }
+    }
+    return d.add("step", d.steps, def)
+}
+
+// plan stores the inline definitions of a plan document and returns the
+// plan itself as JSON. The plan document can also list shared definitions
+// in its requests and tasks keys.
+func (d *definitions) plan(v interface{}) ([]byte, error) {
+    def, ok := v.(map[string]interface{})
+    if !ok {
+        return nil, fmt.Errorf("the plan must be an object")
+    }
+    shared := []struct {
+        key    string
+        inline func(v interface{}) (interface{}, error)
+    }{
+        {"requests", d.request},
+        {"tasks", d.task},
+    }
+    for _, s := range shared {
+        if err := inlineList(def, s.key, s.inline); err != nil {
+            return nil, err
+        }
+        delete(def, s.key)
+    }
+    if err := inlineList(def, "steps", d.step); err != nil {
+        return nil, err
+    }
+    for _, key := range []string{"setup", "teardown"} {
+        if err := inlineList(def, key, d.task); err != nil {
+            return nil, err
+        }
+    }
+    return json.Marshal(def)
+}
+
+// inlineList replaces the inline definitions of the list def[key] by their
+// names.
+func inlineList(def map[string]interface{}, key string, inline func(v interface{}) (interface{}, error)) error {
+    v, ok := def[key]
+    if !ok || v == nil {
+        return nil
+    }
+    list, ok := v.([]interface{})
+    if !ok {
+        return fmt.Errorf("%s must be a list", key)
+    }
+    for i, item := range list {
+        name, err := inline(item)
+        if err != nil {
+            return err
+        }
+        list[i] = name
+    }
+    return nil
+}
+
+// parse parses the definitions, requests first so the tasks can link them,
+// then the tasks and the steps.
+func (d *definitions) parse() (*Config, error) {
+    conf := &Config{}
+    conf.Requests = make(map[string]*request.Request)
+    conf.Steps = make(map[string]*step.Step)
+    conf.Tasks = make(map[string]*task.Task)
+    
+    for name, raw := range d.requests {
+        r, err := request.Parse(raw)
+        if err != nil {
+            return nil, fmt.Errorf("request %s: %s", name, err.Error())
+        }
+        conf.Requests[r.Name] = r
+    }
+    for name, raw := range d.tasks {
+        t, err := task.Parse(raw, conf.Requests)
+        if err != nil {
+            return nil, fmt.Errorf("task %s: %s", name, err.Error())
+        }
+        if schema := d.schemas[name]; schema != nil {
+            t.ResponseSchema = schema
+        }
+        conf.Tasks[t.Name] = t
+    }
+    for name, raw := range d.steps {
+        s, err := step.Parse(raw, conf.Tasks)
+        if err != nil {
+            return nil, fmt.Errorf("step %s: %s", name, err.Error())
+        }
+        conf.Steps[s.Name] = s
+    }
+    return conf, nil
+}
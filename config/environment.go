@@ -0,0 +1,240 @@
+package config
+
+import (
+    "fmt"
+    "io/fs"
+    "path"
+    "regexp"
+    "sort"
+    "strings"
+    
+    "github.com/jarlex/gommander/strict"
+)
+
+// planFields are the plan fields an environment profile or a GOMMANDER_*
+// variable can override.
+var planFields = []string{"type", "name", "authType", "authUser", "authPass", "authEndpoint", "url", "path"}
+
+// EnvPrefix prefixes the environment variables overriding the plan fields,
+// GOMMANDER_URL or GOMMANDER_AUTHUSER for instance, and, followed by VAR_,
+// the plan variables.
+const EnvPrefix = "GOMMANDER_"
+
+// resolve applies to the plan document doc, from lowest to highest
+// precedence, the environment profile l.Env, the GOMMANDER_* variables of
+// l.Environ and l.Vars. Profiles are defined in the environments key of the
+// plan or as files of the envs/ folder named after them. It returns where
+// every plan field and variable got its value, variables keyed as
+// "vars.<name>". The names of the GOMMANDER_VAR_* variables, upper case as
+// usual, are matched case insensitively with the plan variables and with the
+// params of the plan and of params, GOMMANDER_VAR_USERID setting userId.
+func (l Loader) resolve(fsys fs.FS, doc interface{}, params map[string]bool, warnings *[]string) (map[string]string, error) {
+    def, ok := doc.(map[string]interface{})
+    if !ok {
+        return nil, fmt.Errorf("the plan must be an object")
+    }
+    vars, ok := def["vars"].(map[string]interface{})
+    if !ok {
+        if def["vars"] != nil {
+            return nil, fmt.Errorf("vars must be an object")
+        }
+        vars = make(map[string]interface{})
+    }
+    profiles, err := environments(fsys, def, warnings)
+    if err != nil {
+        return nil, err
+    }
+    delete(def, "environments")
+    
+    sources := make(map[string]string)
+    for _, field := range planFields {
+        if _, ok := def[field]; ok {
+            sources[field] = "plan"
+        }
+    }
+    for name := range vars {
+        sources["vars."+name] = "plan"
+    }
+    
+    if l.Env != "" {
+        profile := profiles[l.Env]
+        if profile == nil {
+            return nil, fmt.Errorf("environment %s not found, available: %v", l.Env, names(profiles))
+        }
+        source := "env:" + l.Env
+        for key, value := range profile {
+            switch key {
+            case "name", strict.SchemaKey:
+            case "vars":
+                pvars, ok := value.(map[string]interface{})
+                if !ok {
+                    return nil, fmt.Errorf("vars of environment %s must be an object", l.Env)
+                }
+                for name, v := range pvars {
+                    vars[name] = v
+                    sources["vars."+name] = source
+                }
+            default:
+                field := planField(key)
+                if field == "" {
+                    return nil, fmt.Errorf("environment %s overrides %s, only %v can be", l.Env, key, planFields)
+                }
+                def[field] = value
+                sources[field] = source
+            }
+        }
+    }
+    
+    collectParams(def, params)
+    for _, kv := range l.Environ {
+        if !strings.HasPrefix(kv, EnvPrefix) {
+            continue
+        }
+        kv = strings.TrimPrefix(kv, EnvPrefix)
+        eq := strings.Index(kv, "=")
+        if eq < 0 {
+            continue
+        }
+        key, value := kv[:eq], kv[eq+1:]
+        source := "$" + EnvPrefix + key
+        if strings.HasPrefix(key, "VAR_") {
+            name := varName(strings.TrimPrefix(key, "VAR_"), vars, params)
+            vars[name] = value
+            sources["vars."+name] = source
+            continue
+        }
+        if field := planField(strings.Replace(key, "_", "", -1)); field != "" {
+            def[field] = value
+            sources[field] = source
+        }
+    }
+    
+    for name, value := range l.Vars {
+        vars[name] = value
+        sources["vars."+name] = "--var"
+    }
+    
+    if len(vars) > 0 {
+        def["vars"] = vars
+    }
+    return sources, nil
+}
+
+// planField returns the plan field matching key case insensitively, empty
+// when none does.
+func planField(key string) string {
+    for _, field := range planFields {
+        if strings.EqualFold(field, key) {
+            return field
+        }
+    }
+    return ""
+}
+
+// varName returns the plan variable or, when none matches, the param key
+// names case insensitively, an exact match first. key is returned as is when
+// nothing matches.
+func varName(key string, vars map[string]interface{}, params map[string]bool) string {
+    if _, ok := vars[key]; ok || params[key] {
+        return key
+    }
+    declared := make([]string, 0, len(vars))
+    for name := range vars {
+        declared = append(declared, name)
+    }
+    sort.Strings(declared)
+    used := make([]string, 0, len(params))
+    for name := range params {
+        used = append(used, name)
+    }
+    sort.Strings(used)
+    for _, name := range append(declared, used...) {
+        if strings.EqualFold(name, key) {
+            return name
+        }
+    }
+    return key
+}
+
+// paramKeys are the keys of the definitions listing param names.
+var paramKeys = map[string]bool{"paramsURL": true, "paramsBody": true, "previousData": true, "nextData": true}
+
+// paramPattern matches the {{name}} params of the paths.
+var paramPattern = regexp.MustCompile(`{{([^{}]+)}}`)
+
+// collectParams adds to params the names of the params found in the plan
+// document v: the {{name}} of its strings and the names listed by its
+// paramKeys.
+func collectParams(v interface{}, params map[string]bool) {
+    switch v := v.(type) {
+    case string:
+        for _, m := range paramPattern.FindAllStringSubmatch(v, -1) {
+            params[m[1]] = true
+        }
+    case []interface{}:
+        for _, item := range v {
+            collectParams(item, params)
+        }
+    case map[string]interface{}:
+        for k, item := range v {
+            if list, ok := item.([]interface{}); ok && paramKeys[k] {
+                for _, name := range list {
+                    if name, ok := name.(string); ok {
+                        params[name] = true
+                    }
+                }
+                continue
+            }
+            collectParams(item, params)
+        }
+    }
+}
+
+// environments returns the environment profiles of the plan document def
+// and of the envs/ folder, adding the literal credentials of the latter to
+// warnings.
+func environments(fsys fs.FS, def map[string]interface{}, warnings *[]string) (map[string]map[string]interface{}, error) {
+    profiles := make(map[string]map[string]interface{})
+    if envs, ok := def["environments"]; ok && envs != nil {
+        envsMap, ok := envs.(map[string]interface{})
+        if !ok {
+            return nil, fmt.Errorf("environments must be an object")
+        }
+        for name, env := range envsMap {
+            profile, ok := env.(map[string]interface{})
+            if !ok {
+                return nil, fmt.Errorf("environment %s must be an object", name)
+            }
+            profiles[name] = profile
+        }
+    }
+    
+    err := readDir(fsys, "envs", func(file string, raw []byte) error {
+        doc, err := decode(file, raw)
+        if err != nil {
+            return err
+        }
+        profile, ok := doc.(map[string]interface{})
+        if !ok {
+            return fmt.Errorf("the environment must be an object")
+        }
+        *warnings = append(*warnings, lint(file, profile)...)
+        base := path.Base(file)
+        name := strings.TrimSuffix(base, path.Ext(base))
+        if profiles[name] != nil {
+            return fmt.Errorf("environment %s defined twice", name)
+        }
+        profiles[name] = profile
+        return nil
+    })
+    return profiles, err
+}
+
+func names(profiles map[string]map[string]interface{}) []string {
+    var list []string
+    for name := range profiles {
+        list = append(list, name)
+    }
+    sort.Strings(list)
+    return list
+}
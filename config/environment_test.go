@@ -0,0 +1,179 @@
+package config
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+    "testing/fstest"
+)
+
+var profiles = fstest.MapFS{
+    "plan.yaml": {Data: []byte(`
+name: orders
+url: http://localhost
+authUser: plan
+vars: {tenant: 1, user: plan}
+environments:
+  staging:
+    url: https://staging
+    vars: {tenant: 2, region: eu}
+steps: []
+`)},
+    "envs/prod.yaml": {Data: []byte("url: https://prod\nauthUser: prod\n")},
+}
+
+func TestLoaderResolve(t *testing.T) {
+    tests := []struct {
+        name    string
+        loader  Loader
+        url     string
+        user    string
+        vars    map[string]interface{}
+        sources map[string]string
+    }{
+        {
+            name: "plan",
+            url:  "http://localhost",
+            user: "plan",
+            vars: map[string]interface{}{"tenant": 1, "user": "plan"},
+            sources: map[string]string{
+                "name": "plan", "url": "plan", "authUser": "plan",
+                "vars.tenant": "plan", "vars.user": "plan",
+            },
+        },
+        {
+            name:   "profile",
+            loader: Loader{Env: "staging"},
+            url:    "https://staging",
+            user:   "plan",
+            vars:   map[string]interface{}{"tenant": 2, "user": "plan", "region": "eu"},
+            sources: map[string]string{
+                "name": "plan", "url": "env:staging", "authUser": "plan",
+                "vars.tenant": "env:staging", "vars.user": "plan", "vars.region": "env:staging",
+            },
+        },
+        {
+            name:   "envs folder",
+            loader: Loader{Env: "prod"},
+            url:    "https://prod",
+            user:   "prod",
+            vars:   map[string]interface{}{"tenant": 1, "user": "plan"},
+            sources: map[string]string{
+                "name": "plan", "url": "env:prod", "authUser": "env:prod",
+                "vars.tenant": "plan", "vars.user": "plan",
+            },
+        },
+        {
+            name: "environment variables over profile",
+            loader: Loader{Env: "staging", Environ: []string{
+                "GOMMANDER_URL=http://env", "GOMMANDER_AUTH_USER=env", "GOMMANDER_VAR_tenant=3", "GOMMANDER_UNKNOWN=x", "HOME=/root",
+            }},
+            url:  "http://env",
+            user: "env",
+            vars: map[string]interface{}{"tenant": "3", "user": "plan", "region": "eu"},
+            sources: map[string]string{
+                "name": "plan", "url": "$GOMMANDER_URL", "authUser": "$GOMMANDER_AUTH_USER",
+                "vars.tenant": "$GOMMANDER_VAR_tenant", "vars.user": "plan", "vars.region": "env:staging",
+            },
+        },
+        {
+            name:   "environment variables in upper case",
+            loader: Loader{Environ: []string{"GOMMANDER_VAR_TENANT=3", "GOMMANDER_VAR_REGION=us"}},
+            url:    "http://localhost",
+            user:   "plan",
+            vars:   map[string]interface{}{"tenant": "3", "user": "plan", "REGION": "us"},
+            sources: map[string]string{
+                "name": "plan", "url": "plan", "authUser": "plan",
+                "vars.tenant": "$GOMMANDER_VAR_TENANT", "vars.user": "plan", "vars.REGION": "$GOMMANDER_VAR_REGION",
+            },
+        },
+        {
+            name: "flags over everything",
+            loader: Loader{
+                Env:     "staging",
+                Environ: []string{"GOMMANDER_VAR_tenant=3", "GOMMANDER_VAR_user=env"},
+                Vars:    map[string]string{"tenant": "4"},
+            },
+            url:  "https://staging",
+            user: "plan",
+            vars: map[string]interface{}{"tenant": "4", "user": "env", "region": "eu"},
+            sources: map[string]string{
+                "name": "plan", "url": "env:staging", "authUser": "plan",
+                "vars.tenant": "--var", "vars.user": "$GOMMANDER_VAR_user", "vars.region": "env:staging",
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            conf, err := tt.loader.Load(profiles)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if conf.Plan.URL != tt.url || conf.Plan.AuthUser != tt.user {
+                t.Errorf("url, authUser = %s, %s, want %s, %s", conf.Plan.URL, conf.Plan.AuthUser, tt.url, tt.user)
+            }
+            vars := make(map[string]interface{})
+            for name, v := range conf.Plan.Vars {
+                if f, ok := v.(float64); ok {
+                    v = int(f)
+                }
+                vars[name] = v
+            }
+            if !reflect.DeepEqual(vars, tt.vars) {
+                t.Errorf("vars = %v, want %v", vars, tt.vars)
+            }
+            if !reflect.DeepEqual(conf.Sources, tt.sources) {
+                t.Errorf("sources = %v\nwant %v", conf.Sources, tt.sources)
+            }
+        })
+    }
+}
+
+func TestLoaderVarParams(t *testing.T) {
+    fsys := fstest.MapFS{
+        "requests/get.json": {Data: []byte(`{"name": "getUser", "method": "GET", "path": "/users/{{userId}}", "paramsURL": ["userId"]}`)},
+        "tasks/get.yaml":    {Data: []byte("name: get\nexpectedStatus: 200\nrequest: getUser\npreviousData: [orgId]\n")},
+        "plan.yaml":         {Data: []byte("name: p\npath: /{{tenantId}}\nsteps: []\n")},
+    }
+    loader := Loader{Environ: []string{"GOMMANDER_VAR_USERID=7", "GOMMANDER_VAR_ORGID=2", "GOMMANDER_VAR_TENANTID=1", "GOMMANDER_VAR_OTHER=x"}}
+    conf, err := loader.Load(fsys)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := map[string]interface{}{"userId": "7", "orgId": "2", "tenantId": "1", "OTHER": "x"}
+    if !reflect.DeepEqual(conf.Plan.Vars, want) {
+        t.Errorf("vars = %v, want %v", conf.Plan.Vars, want)
+    }
+    if source := conf.Sources["vars.userId"]; source != "$GOMMANDER_VAR_USERID" {
+        t.Errorf("vars.userId source = %q", source)
+    }
+}
+
+func TestLoaderResolveErrors(t *testing.T) {
+    tests := []struct {
+        name   string
+        fsys   fstest.MapFS
+        loader Loader
+        err    string
+    }{
+        {"unknown profile", profiles, Loader{Env: "qa"}, "environment qa not found, available: [prod staging]"},
+        {"unknown field", fstest.MapFS{
+            "plan.yaml": {Data: []byte("name: p\nenvironments: {qa: {steps: [a]}}\n")},
+        }, Loader{Env: "qa"}, "environment qa overrides steps"},
+        {"vars not an object", fstest.MapFS{
+            "plan.yaml": {Data: []byte("name: p\nvars: [a]\n")},
+        }, Loader{}, "vars must be an object"},
+        {"defined twice", fstest.MapFS{
+            "plan.yaml":    {Data: []byte("name: p\nenvironments: {qa: {url: a}}\n")},
+            "envs/qa.json": {Data: []byte(`{"url": "b"}`)},
+        }, Loader{}, "environment qa defined twice"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := tt.loader.Load(tt.fsys)
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Fatalf("error = %v, want %q", err, tt.err)
+            }
+        })
+    }
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+    "fmt"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// imports loads the libraries of the imports key of the plan document def.
+// Each key is a namespace and each value the folder of a library, holding
+// requests/ and tasks/ folders like a plan folder. Relative folders are
+// resolved from the folder of the plan file. The library definitions are
+// named <namespace>.<name>, auth.login for instance, and the requests
+// referenced by its tasks are looked for in the same library.
+func (l Loader) imports(fsys fs.FS, planFile string, def map[string]interface{}, defs *definitions, warnings *[]string) error {
+    v, ok := def["imports"]
+    delete(def, "imports")
+    if !ok || v == nil {
+        return nil
+    }
+    libs, ok := v.(map[string]interface{})
+    if !ok {
+        return fmt.Errorf("imports must be an object")
+    }
+    defer func() { defs.fsys = fsys }()
+    
+    namespaces := make([]string, 0, len(libs))
+    for ns := range libs {
+        namespaces = append(namespaces, ns)
+    }
+    sort.Strings(namespaces)
+    for _, ns := range namespaces {
+        dir, ok := libs[ns].(string)
+        if !ok {
+            return fmt.Errorf("import %s must be a folder", ns)
+        }
+        if ns == "" || strings.Contains(ns, ".") {
+            return fmt.Errorf("import namespace %q must be a name without dots", ns)
+        }
+        libFS, err := l.open(fsys, path.Join(path.Dir(planFile), filepath.ToSlash(dir)), dir)
+        if err != nil {
+            return fmt.Errorf("import %s: %s", ns, err.Error())
+        }
+        
+        folders := []struct {
+            dir    string
+            inline func(v interface{}) (interface{}, error)
+        }{
+            {"requests", func(v interface{}) (interface{}, error) { return defs.request(namespaced(ns, v)) }},
+            {"tasks", func(v interface{}) (interface{}, error) { return defs.task(namespacedTask(ns, v)) }},
+        }
+        defs.fsys = libFS
+        for _, folder := range folders {
+            err := readDir(libFS, folder.dir, func(name string, raw []byte) error {
+                doc, err := decode(name, raw)
+                if err != nil {
+                    return err
+                }
+                *warnings = append(*warnings, lint(dir+"/"+name, doc)...)
+                doc, err = expand(libFS, doc, defs.secrets)
+                if err != nil {
+                    return err
+                }
+                _, err = folder.inline(doc)
+                return err
+            })
+            if err != nil {
+                return fmt.Errorf("import %s: %s", ns, err.Error())
+            }
+        }
+    }
+    return nil
+}
+
+// open returns the library folder dir. rel is dir relative to the root of
+// fsys, used when it stays inside it. Otherwise the library is read from
+// disk, which needs the plan to be read from disk too, see Loader.Read.
+func (l Loader) open(fsys fs.FS, rel, dir string) (fs.FS, error) {
+    if filepath.IsAbs(dir) {
+        return os.DirFS(dir), nil
+    }
+    if fs.ValidPath(rel) {
+        return fs.Sub(fsys, rel)
+    }
+    if l.dir == "" {
+        return nil, fmt.Errorf("%s is outside the plan folder", dir)
+    }
+    return os.DirFS(filepath.Join(l.dir, filepath.FromSlash(rel))), nil
+}
+
+// namespaced prefixes the name of the inline definition v with ns.
+func namespaced(ns string, v interface{}) interface{} {
+    switch def := v.(type) {
+    case map[string]interface{}:
+        if name, ok := def["name"].(string); ok && name != "" {
+            def["name"] = ns + "." + name
+        }
+    case string:
+        return ns + "." + def
+    }
+    return v
+}
+
+// namespacedTask prefixes the name of the inline task v, and of its request,
+// with ns.
+func namespacedTask(ns string, v interface{}) interface{} {
+    if def, ok := v.(map[string]interface{}); ok {
+        if r, ok := def["request"]; ok {
+            def["request"] = namespaced(ns, r)
+        }
+    }
+    return namespaced(ns, v)
+}
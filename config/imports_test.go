@@ -0,0 +1,121 @@
+package config
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "testing/fstest"
+)
+
+var library = fstest.MapFS{
+    "shared/auth/requests/login.json": {Data: []byte(`{"name": "login", "method": "POST", "path": "/login"}`)},
+    "shared/auth/tasks/login.yaml":    {Data: []byte("name: login\nexpectedStatus: 200\nrequest: login\n")},
+    "shared/auth/tasks/logout.yaml":   {Data: []byte("name: logout\nexpectedStatus: 204\nrequest: {name: logout, method: DELETE, path: /login}\n")},
+}
+
+func withPlan(plan string) fstest.MapFS {
+    fsys := fstest.MapFS{"plans/orders.yaml": {Data: []byte(plan)}}
+    for name, file := range library {
+        fsys[name] = file
+    }
+    return fsys
+}
+
+func TestImports(t *testing.T) {
+    fsys := withPlan(`
+name: orders
+imports: {auth: ../shared/auth}
+setup: [auth.login]
+teardown: [auth.logout]
+tasks:
+  - {name: login, expectedStatus: 200, request: {name: login, method: GET, path: /local}}
+steps: []
+`)
+    conf, err := Loader{PlanFile: "plans/orders.yaml"}.Load(fsys)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        task, request, path string
+    }{
+        {"auth.login", "auth.login", "/login"},
+        {"auth.logout", "auth.logout", "/login"},
+        {"login", "login", "/local"},
+    }
+    for _, tt := range tests {
+        task := conf.Tasks[tt.task]
+        if task == nil {
+            t.Errorf("task %s not loaded", tt.task)
+            continue
+        }
+        if task.NameRequest != tt.request || task.Request.Path != tt.path {
+            t.Errorf("task %s request = %s %s, want %s %s", tt.task, task.NameRequest, task.Request.Path, tt.request, tt.path)
+        }
+    }
+    if conf.Plan.Setup[0] != conf.Tasks["auth.login"] || conf.Plan.Teardown[0] != conf.Tasks["auth.logout"] {
+        t.Error("plan hooks do not use the imported tasks")
+    }
+}
+
+func TestImportsErrors(t *testing.T) {
+    tests := []struct {
+        name string
+        plan string
+        err  string
+    }{
+        {"not an object", "imports: [a]\n", "imports must be an object"},
+        {"not a folder", "imports: {auth: 1}\n", "import auth must be a folder"},
+        {"dotted namespace", "imports: {a.b: ../shared/auth}\n", `import namespace "a.b" must be a name without dots`},
+        {"collision", "imports: {auth: ../shared/auth}\ntasks:\n  - {name: auth.login, request: auth.login}\n", "task auth.login defined twice"},
+        {"same library twice", "imports: {auth: ../shared/auth, login: ../shared/auth}\n", ""},
+        {"outside the plan folder", "imports: {auth: ../../shared/auth}\n", "../../shared/auth is outside the plan folder"},
+        {"missing request", "imports: {auth: ../shared/auth}\ntasks:\n  - {name: t, request: login}\n", "task t"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := Loader{PlanFile: "plans/orders.yaml"}.Load(withPlan("name: p\nsteps: []\n" + tt.plan))
+            if tt.err == "" {
+                if err != nil {
+                    t.Fatal(err)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Fatalf("error = %v, want %q", err, tt.err)
+            }
+        })
+    }
+}
+
+func TestReadImportsOutside(t *testing.T) {
+    dir := t.TempDir()
+    for name, file := range library {
+        path := filepath.Join(dir, filepath.FromSlash(name))
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            t.Fatal(err)
+        }
+        if err := ioutil.WriteFile(path, file.Data, 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    planDir := filepath.Join(dir, "plans", "orders")
+    if err := os.MkdirAll(planDir, 0755); err != nil {
+        t.Fatal(err)
+    }
+    plan := "name: orders\nimports: {auth: ../../shared/auth}\nsetup: [auth.login]\nsteps: []\n"
+    if err := ioutil.WriteFile(filepath.Join(planDir, "plan.yaml"), []byte(plan), 0644); err != nil {
+        t.Fatal(err)
+    }
+    
+    for _, path := range []string{planDir, filepath.Join(planDir, "plan.yaml")} {
+        conf, err := Loader{}.Read(path)
+        if err != nil {
+            t.Fatalf("Read(%s): %v", path, err)
+        }
+        if task := conf.Tasks["auth.login"]; task == nil || task.Request.Path != "/login" {
+            t.Errorf("Read(%s) did not import auth.login", path)
+        }
+    }
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "reflect"
+    "sort"
+    "strings"
+    
+    "github.com/jarlex/gommander/plan"
+    "github.com/jarlex/gommander/request"
+    "github.com/jarlex/gommander/step"
+    "github.com/jarlex/gommander/task"
+)
+
+// FromPlan returns the config holding p and every step, task and request it
+// uses.
+func FromPlan(p *plan.Plan) *Config {
+    conf := &Config{Plan: p}
+    conf.Requests = make(map[string]*request.Request)
+    conf.Steps = make(map[string]*step.Step)
+    conf.Tasks = make(map[string]*task.Task)
+    
+    addTasks := func(tasks []*task.Task) {
+        for _, t := range tasks {
+            conf.Tasks[t.Name] = t
+            if t.Request != nil {
+                conf.Requests[t.Request.Name] = t.Request
+            }
+        }
+    }
+    addTasks(p.Setup)
+    addTasks(p.Teardown)
+    for _, s := range p.Steps {
+        conf.Steps[s.Name] = s
+        addTasks(s.Setup)
+        addTasks(s.Tasks)
+        addTasks(s.Teardown)
+    }
+    return conf
+}
+
+// Save writes the config as a plan folder in dir, one file per request, task
+// and step, and plan.json. Existing files with the same names are
+// overwritten. The values read from secret references are written back as
+// the references, never as the secrets. Two names of a kind sharing a file
+// name, "a/b" and "a_b" for instance, are an error.
+func (c *Config) Save(dir string) error {
+    files := make(map[string]map[string]interface{}, 3)
+    for _, kind := range []struct {
+        sub  string
+        defs map[string]interface{}
+    }{
+        {"requests", definitionsOf(c.Requests)},
+        {"tasks", definitionsOf(c.Tasks)},
+        {"steps", definitionsOf(c.Steps)},
+    } {
+        names := make([]string, 0, len(kind.defs))
+        for name := range kind.defs {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        byFile := make(map[string]string, len(names))
+        files[kind.sub] = make(map[string]interface{}, len(names))
+        for _, name := range names {
+            file := fileName(name)
+            if other, ok := byFile[file]; ok {
+                return fmt.Errorf("%s %q and %q would both be saved as %s/%s", kind.sub, other, name, kind.sub, file)
+            }
+            byFile[file] = name
+            files[kind.sub][file] = kind.defs[name]
+        }
+    }
+    
+    for _, sub := range []string{"requests", "tasks", "steps"} {
+        if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+            return err
+        }
+        for file, v := range files[sub] {
+            if err := c.writeJSON(filepath.Join(dir, sub, file), v); err != nil {
+                return err
+            }
+        }
+    }
+    return c.writeJSON(filepath.Join(dir, "plan.json"), c.Plan)
+}
+
+// definitionsOf returns the values of a map of definitions by name.
+func definitionsOf(defs interface{}) map[string]interface{} {
+    out := make(map[string]interface{})
+    v := reflect.ValueOf(defs)
+    for _, key := range v.MapKeys() {
+        out[key.String()] = v.MapIndex(key).Interface()
+    }
+    return out
+}
+
+// fileName turns a definition name into a safe file name.
+func fileName(name string) string {
+    return strings.Map(func(r rune) rune {
+        switch r {
+        case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+            return '_'
+        }
+        return r
+    }, name) + ".json"
+}
+
+// writeJSON writes v as indented JSON at path, with the secret values
+// replaced by the references they were read from.
+func (c *Config) writeJSON(path string, v interface{}) error {
+    raw, err := json.MarshalIndent(v, "", "  ")
+    if err != nil {
+        return err
+    }
+    values := make([]string, 0, len(c.secrets))
+    for value := range c.secrets {
+        values = append(values, value)
+    }
+    // The longest first, a secret may hold a shorter one
+    sort.Slice(values, func(i, j int) bool {
+        if len(values[i]) != len(values[j]) {
+            return len(values[i]) > len(values[j])
+        }
+        return values[i] < values[j]
+    })
+    for _, value := range values {
+        // Replaced as encoded in the JSON strings, quotes left out
+        encodedValue, _ := json.Marshal(value)
+        encodedRef, _ := json.Marshal(c.secrets[value])
+        raw = bytes.ReplaceAll(raw, encodedValue[1:len(encodedValue)-1], encodedRef[1:len(encodedRef)-1])
+    }
+    return ioutil.WriteFile(path, append(raw, '\n'), 0644)
+}
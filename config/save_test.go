@@ -0,0 +1,242 @@
+package config
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "reflect"
+    "sort"
+    "strings"
+    "testing"
+    "testing/fstest"
+    "time"
+    
+    "github.com/jarlex/gommander/jsonschema"
+    "github.com/jarlex/gommander/plan"
+)
+
+func TestSaveRoundTrip(t *testing.T) {
+    p, err := plan.New("shop").URL("http://localhost").
+        Setup("login", 200).Request("login", "POST", "/login").Body(map[string]interface{}{"user": "u"}, "password").Extract("token").
+        Step("browse", 2, 4).
+        Task("list", 200).Request("list", "GET", "/items").Extract("id").
+        Task("get", 200).Needs("id").Request("get", "GET", "/items/{{id}}").
+        Step("buy", 1, 1).DependsOn().StartAfter(2*time.Second).
+        Task("order", 201).Request("order", "POST", "/orders").
+        Teardown("cleanup", 204).Request("cleanup", "DELETE", "/orders").
+        Build()
+    if err != nil {
+        t.Fatal(err)
+    }
+    dir := t.TempDir()
+    if err := FromPlan(p).Save(dir); err != nil {
+        t.Fatal(err)
+    }
+    
+    var files []string
+    for _, sub := range []string{"requests", "tasks", "steps"} {
+        entries, err := ioutil.ReadDir(filepath.Join(dir, sub))
+        if err != nil {
+            t.Fatal(err)
+        }
+        for _, e := range entries {
+            files = append(files, sub+"/"+e.Name())
+        }
+    }
+    sort.Strings(files)
+    want := []string{
+        "requests/cleanup.json", "requests/get.json", "requests/list.json", "requests/login.json", "requests/order.json",
+        "steps/browse.json", "steps/buy.json",
+        "tasks/cleanup.json", "tasks/get.json", "tasks/list.json", "tasks/login.json", "tasks/order.json",
+    }
+    if !reflect.DeepEqual(files, want) {
+        t.Errorf("files = %q\nwant %q", files, want)
+    }
+    
+    conf, err := Read(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    got := conf.Plan
+    if got.Name != "shop" || got.URL != "http://localhost" || !reflect.DeepEqual(got.StepsNames, []string{"browse", "buy"}) {
+        t.Fatalf("plan = %+v", got)
+    }
+    if got.Setup[0].Request.Path != "/login" || !reflect.DeepEqual(got.Setup[0].Request.ParamsBody, []string{"password"}) || !reflect.DeepEqual(got.Setup[0].NextData, []string{"token"}) {
+        t.Errorf("setup = %+v", got.Setup[0])
+    }
+    browse, buy := got.Steps[0], got.Steps[1]
+    if browse.ConcurrentUsers != 2 || browse.NumPetitions != 4 || browse.DependsOn != nil || len(browse.Tasks) != 2 {
+        t.Errorf("browse = %+v", browse)
+    }
+    if get := browse.Tasks[1]; !reflect.DeepEqual(get.PreviousData, []string{"id"}) || !reflect.DeepEqual(get.Request.ParamsURL, []string{"id"}) {
+        t.Errorf("get = %+v", get)
+    }
+    if buy.DependsOn == nil || len(buy.DependsOn) != 0 || buy.StartAfter != 2*time.Second || len(buy.Teardown) != 1 {
+        t.Errorf("buy = %+v", buy)
+    }
+}
+
+func TestFileName(t *testing.T) {
+    tests := []struct {
+        name, want string
+    }{
+        {"list", "list.json"},
+        {"list orders", "list_orders.json"},
+        {"orders/get", "orders_get.json"},
+        {`a:b*c?"d<e>f|g\h`, "a_b_c__d_e_f_g_h.json"},
+    }
+    for _, tt := range tests {
+        if got := fileName(tt.name); got != tt.want {
+            t.Errorf("fileName(%q) = %s, want %s", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestSaveMatchesSchemas(t *testing.T) {
+    p, err := plan.New("shop").URL("http://localhost").
+        Step("browse", 2, 4).
+        Task("list", 200).Request("list", "GET", "/items").
+        Step("buy", 1, 1).DependsOn().
+        Task("order", 201).Request("order", "POST", "/orders").Body(map[string]interface{}{}).
+        Build()
+    if err != nil {
+        t.Fatal(err)
+    }
+    dir := t.TempDir()
+    if err := FromPlan(p).Save(dir); err != nil {
+        t.Fatal(err)
+    }
+    
+    files := []struct {
+        kind string
+        path string
+    }{
+        {"plan", "plan.json"},
+        {"step", "steps/browse.json"},
+        {"step", "steps/buy.json"},
+        {"task", "tasks/list.json"},
+        {"task", "tasks/order.json"},
+        {"request", "requests/list.json"},
+        {"request", "requests/order.json"},
+    }
+    for _, f := range files {
+        t.Run(f.path, func(t *testing.T) {
+            s, err := jsonschema.New(roundTrip(t, Schema(f.kind)))
+            if err != nil {
+                t.Fatal(err)
+            }
+            raw, err := ioutil.ReadFile(filepath.Join(dir, f.path))
+            if err != nil {
+                t.Fatal(err)
+            }
+            var doc interface{}
+            if err := json.Unmarshal(raw, &doc); err != nil {
+                t.Fatal(err)
+            }
+            for _, e := range s.Validate(doc) {
+                t.Error(e)
+            }
+        })
+    }
+}
+
+func TestSaveKeepsEmptyLists(t *testing.T) {
+    p, err := plan.New("shop").URL("http://localhost").
+        Step("browse", 1, 1).
+        Task("list", 200).Request("list", "GET", "/items").
+        Step("buy", 1, 1).DependsOn().
+        Task("order", 201).Request("order", "POST", "/orders").Body(map[string]interface{}{}).
+        Build()
+    if err != nil {
+        t.Fatal(err)
+    }
+    dir := t.TempDir()
+    if err := FromPlan(p).Save(dir); err != nil {
+        t.Fatal(err)
+    }
+    
+    tests := []struct {
+        path    string
+        key     string
+        present bool
+    }{
+        {"steps/browse.json", "dependsOn", false},
+        {"steps/buy.json", "dependsOn", true},
+        {"requests/list.json", "body", false},
+        {"requests/order.json", "body", true},
+        {"tasks/list.json", "previousData", false},
+        {"requests/list.json", "paramsURL", false},
+    }
+    for _, tt := range tests {
+        raw, err := ioutil.ReadFile(filepath.Join(dir, tt.path))
+        if err != nil {
+            t.Fatal(err)
+        }
+        var doc map[string]interface{}
+        if err := json.Unmarshal(raw, &doc); err != nil {
+            t.Fatal(err)
+        }
+        v, ok := doc[tt.key]
+        if ok != tt.present {
+            t.Errorf("%s: %s present = %v, want %v", tt.path, tt.key, ok, tt.present)
+        }
+        if ok && v == nil {
+            t.Errorf("%s: %s is null", tt.path, tt.key)
+        }
+    }
+}
+
+func TestSaveKeepsSecretReferences(t *testing.T) {
+    os.Setenv("GOMMANDER_TEST_SAVE_TOKEN", "tok-\"1\"")
+    defer os.Unsetenv("GOMMANDER_TEST_SAVE_TOKEN")
+    fsys := fstest.MapFS{
+        "plan.yaml":          {Data: []byte("name: p\nurl: http://localhost\nauthUser: u\nauthPass: ${file:secrets/pass}\nsteps: [browse]\n")},
+        "secrets/pass":       {Data: []byte("hunter22\n")},
+        "steps/browse.yaml":  {Data: []byte("name: browse\nnumPetitions: 1\nconcurrentUsers: 1\ntasks: [list]\n")},
+        "tasks/list.yaml":    {Data: []byte("name: list\nexpectedStatus: 200\nrequest: list\n")},
+        "requests/list.yaml": {Data: []byte("name: list\nmethod: GET\npath: /items\nheaders:\n  Authorization: Bearer ${env:GOMMANDER_TEST_SAVE_TOKEN}\n")},
+    }
+    conf, err := Load(fsys)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if conf.Requests["list"].Headers["Authorization"] != `Bearer tok-"1"` {
+        t.Fatalf("headers = %v", conf.Requests["list"].Headers)
+    }
+    dir := t.TempDir()
+    if err := conf.Save(dir); err != nil {
+        t.Fatal(err)
+    }
+    
+    tests := []struct {
+        path, ref, value string
+    }{
+        {"plan.json", "${file:secrets/pass}", "hunter22"},
+        {"requests/list.json", "Bearer ${env:GOMMANDER_TEST_SAVE_TOKEN}", "tok-"},
+    }
+    for _, tt := range tests {
+        raw, err := ioutil.ReadFile(filepath.Join(dir, tt.path))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !strings.Contains(string(raw), tt.ref) || strings.Contains(string(raw), tt.value) {
+            t.Errorf("%s holds the secret instead of %s:\n%s", tt.path, tt.ref, raw)
+        }
+    }
+}
+
+func TestSaveFileNameCollision(t *testing.T) {
+    p, err := plan.New("shop").URL("http://localhost").
+        Step("browse", 1, 1).
+        Task("a/b", 200).Request("a/b", "GET", "/a/b").
+        Task("a_b", 200).Request("list", "GET", "/a_b").
+        Build()
+    if err != nil {
+        t.Fatal(err)
+    }
+    err = FromPlan(p).Save(t.TempDir())
+    if err == nil || err.Error() != `tasks "a/b" and "a_b" would both be saved as tasks/a_b.json` {
+        t.Errorf("Save = %v, want a collision of tasks/a_b.json", err)
+    }
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+    "reflect"
+    "strings"
+    
+    "github.com/jarlex/gommander/plan"
+    "github.com/jarlex/gommander/request"
+    "github.com/jarlex/gommander/step"
+    "github.com/jarlex/gommander/strict"
+    "github.com/jarlex/gommander/task"
+)
+
+// SchemaKinds are the kinds of plan documents Schema describes.
+var SchemaKinds = []string{"plan", "step", "task", "request"}
+
+// definitionRefs are the fields referencing other definitions by name, which
+// can hold inline definitions too.
+var definitionRefs = map[string]map[string]string{
+    "plan": {"steps": "step", "setup": "task", "teardown": "task"},
+    "step": {"tasks": "task", "setup": "task", "teardown": "task"},
+    "task": {"request": "request"},
+}
+
+// durationFields are the fields holding durations, by kind.
+var durationFields = map[string]map[string]bool{
+    "step":    {"startAfter": true},
+    "task":    {"thinkTime": true},
+    "latency": {"mean": true, "stdDev": true, "min": true, "max": true},
+}
+
+// minimums are the lowest values of the integer fields, by kind.
+var minimums = map[string]map[string]int{
+    "step": {"concurrentUsers": 1, "numPetitions": 0},
+}
+
+// Schema returns the JSON Schema (draft 7) of the documents of kind, one of
+// SchemaKinds, generated from the Go types. Every schema holds the
+// definitions of the other kinds, for the inline definitions.
+func Schema(kind string) map[string]interface{} {
+    definitions := map[string]interface{}{
+        "plan":    planSchema(),
+        "step":    objectSchema("step", reflect.TypeOf(step.Step{}), nil),
+        "task":    objectSchema("task", reflect.TypeOf(task.Task{}), task.LegacyKeys),
+        "request": objectSchema("request", reflect.TypeOf(request.Request{}), request.LegacyKeys),
+    }
+    if kind != "plan" {
+        delete(definitions, "plan")
+    }
+    return map[string]interface{}{
+        "$schema":     "http://json-schema.org/draft-07/schema#",
+        "title":       "gommander " + kind,
+        "$ref":        "#/definitions/" + kind,
+        "definitions": definitions,
+    }
+}
+
+// planSchema adds to the plan fields the keys only the loader knows.
+func planSchema() map[string]interface{} {
+    s := objectSchema("plan", reflect.TypeOf(plan.Plan{}), nil)
+    props := s["properties"].(map[string]interface{})
+    props["requests"] = map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/request"}}
+    props["tasks"] = map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/definitions/task"}}
+    props["imports"] = map[string]interface{}{
+        "description":          "Request and task libraries by namespace",
+        "type":                 "object",
+        "additionalProperties": map[string]interface{}{"type": "string"},
+    }
+    
+    profile := map[string]interface{}{"name": map[string]interface{}{"type": "string"}, "vars": props["vars"]}
+    for _, field := range planFields {
+        profile[field] = props[field]
+    }
+    props["environments"] = map[string]interface{}{
+        "description": "Environment profiles by name",
+        "type":        "object",
+        "additionalProperties": map[string]interface{}{
+            "type":                 "object",
+            "properties":           profile,
+            "additionalProperties": false,
+        },
+    }
+    return s
+}
+
+// objectSchema describes the JSON fields of the struct t.
+func objectSchema(kind string, t reflect.Type, legacy map[string]string) map[string]interface{} {
+    props := map[string]interface{}{
+        strict.SchemaKey: map[string]interface{}{"type": "string"},
+    }
+    for i := 0; i < t.NumField(); i++ {
+        f := t.Field(i)
+        name := strings.Split(f.Tag.Get("json"), ",")[0]
+        if name == "-" || f.PkgPath != "" {
+            continue
+        }
+        if name == "" {
+            name = f.Name
+        }
+        props[name] = fieldSchema(kind, name, f.Type)
+    }
+    for old, current := range legacy {
+        props[old] = map[string]interface{}{
+            "description": "Legacy spelling of " + current,
+            "deprecated":  true,
+            "$ref":        "#/definitions/" + kind + "/properties/" + current,
+        }
+    }
+    return map[string]interface{}{
+        "type":                 "object",
+        "properties":           props,
+        "required":             []string{"name"},
+        "additionalProperties": false,
+    }
+}
+
+func fieldSchema(kind, name string, t reflect.Type) map[string]interface{} {
+    if ref, ok := definitionRefs[kind][name]; ok {
+        byName := map[string]interface{}{
+            "oneOf": []interface{}{
+                map[string]interface{}{"type": "string"},
+                map[string]interface{}{"$ref": "#/definitions/" + ref},
+            },
+        }
+        if t.Kind() == reflect.Slice {
+            return map[string]interface{}{"type": "array", "items": byName}
+        }
+        return byName
+    }
+    if durationFields[kind][name] {
+        return map[string]interface{}{"type": "string", "pattern": `^([0-9.]+(ns|us|µs|ms|s|m|h))+$`}
+    }
+    
+    switch t.Kind() {
+    case reflect.String:
+        return map[string]interface{}{"type": "string"}
+    case reflect.Int, reflect.Int64, reflect.Int32:
+        if min, ok := minimums[kind][name]; ok {
+            return map[string]interface{}{"type": "integer", "minimum": min}
+        }
+        return map[string]interface{}{"type": "integer"}
+    case reflect.Float64, reflect.Float32:
+        return map[string]interface{}{"type": "number"}
+    case reflect.Bool:
+        return map[string]interface{}{"type": "boolean"}
+    case reflect.Slice:
+        return map[string]interface{}{"type": "array", "items": fieldSchema("", "", t.Elem())}
+    case reflect.Map:
+        return map[string]interface{}{"type": "object", "additionalProperties": fieldSchema("", "", t.Elem())}
+    case reflect.Ptr:
+        return fieldSchema(kind, name, t.Elem())
+    case reflect.Struct:
+        nested := strings.ToLower(t.Name())
+        props := map[string]interface{}{}
+        for i := 0; i < t.NumField(); i++ {
+            f := t.Field(i)
+            name := strings.Split(f.Tag.Get("json"), ",")[0]
+            if name == "-" || f.PkgPath != "" {
+                continue
+            }
+            props[name] = fieldSchema(nested, name, f.Type)
+        }
+        return map[string]interface{}{"type": "object", "properties": props, "additionalProperties": false}
+    }
+    return map[string]interface{}{}
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "testing/fstest"
+)
+
+// TestSchemaFiles checks the published schemas are up to date with the Go
+// types, regenerate them with gommander schema --out schema otherwise.
+func TestSchemaFiles(t *testing.T) {
+    for _, kind := range SchemaKinds {
+        raw, err := ioutil.ReadFile(filepath.Join("..", "schema", kind+".schema.json"))
+        if err != nil {
+            t.Fatal(err)
+        }
+        var published interface{}
+        if err := json.Unmarshal(raw, &published); err != nil {
+            t.Fatal(err)
+        }
+        if !reflect.DeepEqual(published, roundTrip(t, Schema(kind))) {
+            t.Errorf("schema/%s.schema.json is out of date", kind)
+        }
+    }
+}
+
+func TestSchema(t *testing.T) {
+    tests := []struct {
+        kind, definition, property string
+        want                       string
+    }{
+        {"plan", "plan", "steps", `{"items":{"oneOf":[{"type":"string"},{"$ref":"#/definitions/step"}]},"type":"array"}`},
+        {"plan", "plan", "imports", `{"additionalProperties":{"type":"string"},"description":"Request and task libraries by namespace","type":"object"}`},
+        {"plan", "plan", "$schema", `{"type":"string"}`},
+        {"step", "step", "startAfter", `{"pattern":"^([0-9.]+(ns|us|µs|ms|s|m|h))+$","type":"string"}`},
+        {"step", "step", "numPetitions", `{"minimum":0,"type":"integer"}`},
+        {"step", "step", "concurrentUsers", `{"minimum":1,"type":"integer"}`},
+        {"task", "task", "expectedStatus", `{"type":"integer"}`},
+        {"task", "task", "request", `{"oneOf":[{"type":"string"},{"$ref":"#/definitions/request"}]}`},
+        {"task", "task", "previusData", `{"$ref":"#/definitions/task/properties/previousData","deprecated":true,"description":"Legacy spelling of previousData"}`},
+        {"request", "request", "body", `{"additionalProperties":{},"type":"object"}`},
+        {"request", "request", "ParamsBody", `{"$ref":"#/definitions/request/properties/paramsBody","deprecated":true,"description":"Legacy spelling of paramsBody"}`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.kind+"/"+tt.property, func(t *testing.T) {
+            s := Schema(tt.kind)
+            if s["$ref"] != "#/definitions/"+tt.kind {
+                t.Errorf("$ref = %v", s["$ref"])
+            }
+            def := s["definitions"].(map[string]interface{})[tt.definition].(map[string]interface{})
+            if def["additionalProperties"] != false {
+                t.Error("additional properties allowed")
+            }
+            raw, err := json.Marshal(def["properties"].(map[string]interface{})[tt.property])
+            if err != nil {
+                t.Fatal(err)
+            }
+            if string(raw) != tt.want {
+                t.Errorf("%s = %s\nwant %s", tt.property, raw, tt.want)
+            }
+        })
+    }
+    if _, ok := Schema("task")["definitions"].(map[string]interface{})["plan"]; ok {
+        t.Error("task schema defines the plan")
+    }
+}
+
+func TestLoadStrict(t *testing.T) {
+    tests := []struct {
+        name string
+        fsys fstest.MapFS
+        err  string
+    }{
+        {"plan", fstest.MapFS{"plan.yaml": {Data: []byte("name: p\nstep: []\n")}}, `plan.yaml: unknown key "step", did you mean "steps"?`},
+        {"inline task", fstest.MapFS{
+            "plan.yaml": {Data: []byte("name: p\ntasks:\n  - {name: t, expectedStatu: 200, request: {name: r, method: GET, path: /}}\n")},
+        }, `task t: unknown key "expectedStatu", did you mean "expectedStatus"?`},
+        {"legacy keys", fstest.MapFS{
+            "requests/r.json": {Data: []byte(`{"name": "r", "method": "POST", "path": "/", "ParamsBody": ["id"]}`)},
+            "tasks/t.json":    {Data: []byte(`{"name": "t", "request": "r", "previusData": ["id"]}`)},
+            "plan.json":       {Data: []byte(`{"$schema": "schema/plan.schema.json", "name": "p", "steps": []}`)},
+        }, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            conf, err := Load(tt.fsys)
+            if tt.err == "" {
+                if err != nil {
+                    t.Fatal(err)
+                }
+                if got := conf.Tasks["t"].PreviousData; !reflect.DeepEqual(got, []string{"id"}) {
+                    t.Errorf("previousData = %q", got)
+                }
+                if got := conf.Requests["r"].ParamsBody; !reflect.DeepEqual(got, []string{"id"}) {
+                    t.Errorf("paramsBody = %q", got)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Fatalf("error = %v, want %q", err, tt.err)
+            }
+        })
+    }
+}
+
+// roundTrip turns v into the generic JSON values a schema file decodes to.
+func roundTrip(t *testing.T, v interface{}) interface{} {
+    raw, err := json.Marshal(v)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var out interface{}
+    if err := json.Unmarshal(raw, &out); err != nil {
+        t.Fatal(err)
+    }
+    return out
+}
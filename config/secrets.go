@@ -0,0 +1,114 @@
+package config
+
+import (
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    
+    "github.com/jarlex/gommander/secret"
+)
+
+// secretRef matches the secret references, ${env:NAME} and ${file:path}.
+var secretRef = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+// credentialKey matches the keys whose values look like credentials.
+var credentialKey = regexp.MustCompile(`(?i)(pass(word)?|secret|token|api[-_]?key|authorization|credential)`)
+
+// expand replaces the secret references of the strings of doc by their
+// values and registers them as secrets. File references are relative to the
+// plan folder unless absolute. Each reference is stored in refs, when not
+// nil, by its value so the plan can be saved without the secrets.
+func expand(fsys fs.FS, doc interface{}, refs map[string]string) (interface{}, error) {
+    switch v := doc.(type) {
+    case map[string]interface{}:
+        for key, item := range v {
+            expanded, err := expand(fsys, item, refs)
+            if err != nil {
+                return nil, fmt.Errorf("%s: %s", key, err.Error())
+            }
+            v[key] = expanded
+        }
+    case []interface{}:
+        for i, item := range v {
+            expanded, err := expand(fsys, item, refs)
+            if err != nil {
+                return nil, err
+            }
+            v[i] = expanded
+        }
+    case string:
+        var err error
+        expanded := secretRef.ReplaceAllStringFunc(v, func(ref string) string {
+            m := secretRef.FindStringSubmatch(ref)
+            value, e := resolveSecret(fsys, m[1], m[2])
+            if e != nil && err == nil {
+                err = e
+            }
+            secret.Register(value)
+            if refs != nil && value != "" {
+                refs[value] = ref
+            }
+            return value
+        })
+        return expanded, err
+    }
+    return doc, nil
+}
+
+func resolveSecret(fsys fs.FS, kind, ref string) (string, error) {
+    switch kind {
+    case "env":
+        value, ok := os.LookupEnv(ref)
+        if !ok {
+            return "", fmt.Errorf("environment variable %s of ${env:%s} is not set", ref, ref)
+        }
+        return value, nil
+    default:
+        var raw []byte
+        var err error
+        if filepath.IsAbs(ref) {
+            raw, err = os.ReadFile(ref)
+        } else {
+            raw, err = fs.ReadFile(fsys, filepath.ToSlash(filepath.Clean(ref)))
+        }
+        if err != nil {
+            return "", fmt.Errorf("${file:%s}: %s", ref, err.Error())
+        }
+        return strings.TrimRight(string(raw), "\r\n"), nil
+    }
+}
+
+// lint returns a warning for every credential looking key of doc holding a
+// literal value instead of a secret reference.
+func lint(where string, doc interface{}) []string {
+    var warnings []string
+    var walk func(path string, v interface{})
+    walk = func(path string, v interface{}) {
+        switch v := v.(type) {
+        case map[string]interface{}:
+            keys := make([]string, 0, len(v))
+            for key := range v {
+                keys = append(keys, key)
+            }
+            sort.Strings(keys)
+            for _, key := range keys {
+                item := v[key]
+                if s, ok := item.(string); ok && s != "" && credentialKey.MatchString(key) && !secretRef.MatchString(s) {
+                    warnings = append(warnings, fmt.Sprintf("%s: %s%s holds a literal credential, use ${env:NAME} or ${file:path}", where, path, key))
+                    continue
+                }
+                walk(path+key+".", item)
+            }
+        case []interface{}:
+            for i, item := range v {
+                walk(fmt.Sprintf("%s%d.", path, i), item)
+            }
+        }
+    }
+    walk("", doc)
+    return warnings
+}
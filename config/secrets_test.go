@@ -0,0 +1,106 @@
+package config
+
+import (
+    "os"
+    "reflect"
+    "strings"
+    "testing"
+    "testing/fstest"
+    
+    "github.com/jarlex/gommander/secret"
+)
+
+func TestExpand(t *testing.T) {
+    os.Setenv("GOMMANDER_TEST_TOKEN", "s3cr3t-token")
+    defer os.Unsetenv("GOMMANDER_TEST_TOKEN")
+    fsys := fstest.MapFS{"secrets/pass": {Data: []byte("hunter22\n")}}
+    
+    tests := []struct {
+        name string
+        doc  interface{}
+        want interface{}
+        err  string
+    }{
+        {"no reference", "plain", "plain", ""},
+        {"env", "Bearer ${env:GOMMANDER_TEST_TOKEN}", "Bearer s3cr3t-token", ""},
+        {"file", "${file:secrets/pass}", "hunter22", ""},
+        {"file relative", "${file:./secrets/../secrets/pass}", "hunter22", ""},
+        {"nested", map[string]interface{}{
+            "headers": []interface{}{"${env:GOMMANDER_TEST_TOKEN}", 1.0},
+        }, map[string]interface{}{
+            "headers": []interface{}{"s3cr3t-token", 1.0},
+        }, ""},
+        {"unset env", map[string]interface{}{"authPass": "${env:GOMMANDER_TEST_UNSET}"}, nil,
+            "authPass: environment variable GOMMANDER_TEST_UNSET of ${env:GOMMANDER_TEST_UNSET} is not set"},
+        {"missing file", "${file:missing}", nil, "${file:missing}: open missing"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := expand(fsys, tt.doc, nil)
+            if tt.err != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.err) {
+                    t.Fatalf("error = %v, want %q", err, tt.err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("expand = %v, want %v", got, tt.want)
+            }
+        })
+    }
+    refs := make(map[string]string)
+    if _, err := expand(fsys, []interface{}{"${env:GOMMANDER_TEST_TOKEN}", "${file:secrets/pass}"}, refs); err != nil {
+        t.Fatal(err)
+    }
+    if want := map[string]string{"s3cr3t-token": "${env:GOMMANDER_TEST_TOKEN}", "hunter22": "${file:secrets/pass}"}; !reflect.DeepEqual(refs, want) {
+        t.Errorf("references = %v, want %v", refs, want)
+    }
+    if got := secret.Mask("token s3cr3t-token pass hunter22"); got != "token **** pass ****" {
+        t.Errorf("expanded values not registered: %s", got)
+    }
+}
+
+func TestLint(t *testing.T) {
+    doc := map[string]interface{}{
+        "name":     "login",
+        "authPass": "literal",
+        "headers": map[string]interface{}{
+            "Authorization": "Bearer abc",
+            "X-Api-Key":     "${env:KEY}",
+            "Accept":        "application/json",
+        },
+        "body": []interface{}{
+            map[string]interface{}{"password": "p", "token": ""},
+        },
+    }
+    want := []string{
+        "plan.yaml: authPass holds a literal credential, use ${env:NAME} or ${file:path}",
+        "plan.yaml: body.0.password holds a literal credential, use ${env:NAME} or ${file:path}",
+        "plan.yaml: headers.Authorization holds a literal credential, use ${env:NAME} or ${file:path}",
+    }
+    if got := lint("plan.yaml", doc); !reflect.DeepEqual(got, want) {
+        t.Errorf("lint = %q\nwant %q", got, want)
+    }
+}
+
+func TestLoadSecrets(t *testing.T) {
+    os.Setenv("GOMMANDER_TEST_PASS", "plan-pass-42")
+    defer os.Unsetenv("GOMMANDER_TEST_PASS")
+    fsys := fstest.MapFS{
+        "plan.yaml":     {Data: []byte("name: p\nauthUser: u\nauthPass: ${env:GOMMANDER_TEST_PASS}\nsteps: []\n")},
+        "envs/dev.yaml": {Data: []byte("authPass: devpass\n")},
+    }
+    conf, err := Load(fsys)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if conf.Plan.AuthPass != "plan-pass-42" || len(conf.Warnings) != 1 || !strings.HasPrefix(conf.Warnings[0], "envs/dev.yaml: authPass") {
+        t.Errorf("authPass = %s, warnings = %q", conf.Plan.AuthPass, conf.Warnings)
+    }
+    if got := secret.Mask("login u:plan-pass-42"); got != "login u:****" {
+        t.Errorf("Mask = %s", got)
+    }
+}
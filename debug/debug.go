@@ -0,0 +1,184 @@
+// Package debug dumps the petitions of the failing samples or of a selected
+// virtual user: the request and the response, bodies truncated and secrets
+// masked, with the variables of the user before and after the task.
+package debug
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "sort"
+    "strings"
+    "time"
+    
+    "github.com/jarlex/gommander/metrics"
+    "github.com/jarlex/gommander/secret"
+)
+
+// DefaultLimit is the number of body bytes dumped when Options.Limit is 0.
+const DefaultLimit = 4096
+
+// maskedHeaders are the headers whose values are never dumped.
+var maskedHeaders = map[string]bool{
+    "Authorization":       true,
+    "Proxy-Authorization": true,
+    "Cookie":              true,
+    "Set-Cookie":          true,
+}
+
+// Options selects the samples dumped.
+type Options struct {
+    Failing bool // Dump the failing samples of every user
+    User    int  // Dump every sample of this user in each step, -1 for none
+    Limit   int  // Bodies are truncated to Limit bytes, DefaultLimit when 0
+}
+
+// Dumper writes the dumps, safe for concurrent use.
+type Dumper struct {
+    opts   Options
+    logger *log.Logger
+}
+
+// New returns a dumper writing to w with the secret values masked.
+func New(w io.Writer, opts Options) *Dumper {
+    if opts.Limit <= 0 {
+        opts.Limit = DefaultLimit
+    }
+    return &Dumper{opts: opts, logger: log.New(secret.Writer(w), "", 0)}
+}
+
+type dumperKey struct{}
+
+type exchangeKey struct{}
+
+// WithDumper returns a copy of ctx whose samples are dumped by d.
+func WithDumper(ctx context.Context, d *Dumper) context.Context {
+    return context.WithValue(ctx, dumperKey{}, d)
+}
+
+// FromContext returns the dumper of ctx, nil when none was set.
+func FromContext(ctx context.Context) *Dumper {
+    d, _ := ctx.Value(dumperKey{}).(*Dumper)
+    return d
+}
+
+// Exchange is a petition and the response it got, Status is 0 when it got
+// none.
+type Exchange struct {
+    Request     *http.Request
+    RequestBody []byte
+    Status      int
+    Header      http.Header
+    Body        []byte
+    Duration    time.Duration
+}
+
+// Watch returns a copy of ctx recording the next petition in the returned
+// exchange when the samples of user may be dumped, ctx and nil otherwise.
+func (d *Dumper) Watch(ctx context.Context, user int) (context.Context, *Exchange) {
+    if d == nil || (!d.opts.Failing && d.opts.User != user) {
+        return ctx, nil
+    }
+    ex := &Exchange{}
+    return context.WithValue(ctx, exchangeKey{}, ex), ex
+}
+
+// Record keeps req and resp in the exchange of ctx, if any. resp is nil when
+// the petition failed.
+func Record(ctx context.Context, req *http.Request, resp *http.Response, body []byte, d time.Duration) {
+    ex, ok := ctx.Value(exchangeKey{}).(*Exchange)
+    if !ok {
+        return
+    }
+    ex.Request = req
+    if req.GetBody != nil {
+        if rc, err := req.GetBody(); err == nil {
+            ex.RequestBody, _ = ioutil.ReadAll(rc)
+            rc.Close()
+        }
+    }
+    if resp != nil {
+        ex.Status = resp.StatusCode
+        ex.Header = resp.Header
+        ex.Body = body
+    }
+    ex.Duration = d
+}
+
+// Dump writes the sample with its exchange and the variables of the user
+// before and after the task, when it was selected.
+func (d *Dumper) Dump(s metrics.Sample, before, after map[string]interface{}, ex *Exchange) {
+    if d == nil || ex == nil || (d.opts.User != s.User && s.Err == nil) {
+        return
+    }
+    var out strings.Builder
+    result := "OK"
+    if s.Err != nil {
+        result = "FAIL: " + s.Err.Error()
+    }
+    fmt.Fprintf(&out, "--- %s|U%d|P%d|%s %s\n", s.Step, s.User, s.Petition, s.Task, result)
+    fmt.Fprintf(&out, "vars before: %s\n", vars(before))
+    if ex.Request != nil {
+        fmt.Fprintf(&out, "> %s %s\n", ex.Request.Method, ex.Request.URL)
+        d.headers(&out, "> ", ex.Request.Header)
+        d.body(&out, "> ", ex.RequestBody)
+    }
+    if ex.Status != 0 {
+        fmt.Fprintf(&out, "< %d %s (%s)\n", ex.Status, http.StatusText(ex.Status), ex.Duration)
+        d.headers(&out, "< ", ex.Header)
+        d.body(&out, "< ", ex.Body)
+    } else if ex.Request != nil {
+        out.WriteString("< no response\n")
+    }
+    fmt.Fprintf(&out, "vars after: %s", vars(after))
+    d.logger.Println(out.String())
+}
+
+func (d *Dumper) headers(out *strings.Builder, prefix string, h http.Header) {
+    keys := make([]string, 0, len(h))
+    for k := range h {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    for _, k := range keys {
+        for _, v := range h[k] {
+            if maskedHeaders[k] {
+                v = secret.Masked
+            }
+            fmt.Fprintf(out, "%s%s: %s\n", prefix, k, v)
+        }
+    }
+}
+
+func (d *Dumper) body(out *strings.Builder, prefix string, body []byte) {
+    if len(body) == 0 {
+        return
+    }
+    more := len(body) - d.opts.Limit
+    if more > 0 {
+        body = body[:d.opts.Limit]
+    }
+    out.WriteString(prefix + "\n")
+    for _, line := range strings.Split(strings.TrimSuffix(string(body), "\n"), "\n") {
+        out.WriteString(prefix + line + "\n")
+    }
+    if more > 0 {
+        fmt.Fprintf(out, "%s... %d more bytes\n", prefix, more)
+    }
+}
+
+// vars formats the variables of a user as a JSON object.
+func vars(v map[string]interface{}) string {
+    if v == nil {
+        return "{}"
+    }
+    raw, err := json.Marshal(v)
+    if err != nil {
+        return fmt.Sprint(v)
+    }
+    return string(raw)
+}
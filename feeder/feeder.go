@@ -0,0 +1,105 @@
+// Package feeder hands the petitions of the users rows of test data, read
+// from CSV or JSON files, so each petition can use a different account,
+// product or search term.
+package feeder
+
+import (
+    "bytes"
+    "context"
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/fs"
+    "strings"
+    "sync/atomic"
+)
+
+// Feeder hands its rows in order, starting over once they are exhausted,
+// safe for concurrent use.
+type Feeder struct {
+    rows []map[string]interface{}
+    next uint64
+}
+
+// New returns a feeder of rows.
+func New(rows []map[string]interface{}) *Feeder {
+    return &Feeder{rows: rows}
+}
+
+// Load reads the feeder file name of fsys, see Parse.
+func Load(fsys fs.FS, name string) (*Feeder, error) {
+    raw, err := fs.ReadFile(fsys, name)
+    if err != nil {
+        return nil, err
+    }
+    return Parse(name, raw)
+}
+
+// Parse decodes the rows of the file name: a CSV file, its first line
+// naming the columns, when name ends in .csv, a JSON array of objects
+// otherwise. A feeder needs at least a row.
+func Parse(name string, raw []byte) (*Feeder, error) {
+    var rows []map[string]interface{}
+    if strings.HasSuffix(strings.ToLower(name), ".csv") {
+        records, err := csv.NewReader(bytes.NewReader(raw)).ReadAll()
+        if err != nil {
+            return nil, err
+        }
+        if len(records) == 0 {
+            return nil, errors.New("no header line")
+        }
+        header := records[0]
+        for _, record := range records[1:] {
+            row := make(map[string]interface{}, len(header))
+            for i, column := range header {
+                row[column] = record[i]
+            }
+            rows = append(rows, row)
+        }
+    } else if err := json.Unmarshal(raw, &rows); err != nil {
+        return nil, fmt.Errorf("not a JSON array of objects: %s", err.Error())
+    }
+    if len(rows) == 0 {
+        return nil, errors.New("no rows")
+    }
+    return New(rows), nil
+}
+
+// Len returns the number of rows, 0 for a nil feeder.
+func (f *Feeder) Len() int {
+    if f == nil {
+        return 0
+    }
+    return len(f.rows)
+}
+
+// Row returns the row i, counting from the first one again past the last.
+// Rows are shared, they must not be modified.
+func (f *Feeder) Row(i int) map[string]interface{} {
+    if f.Len() == 0 {
+        return nil
+    }
+    return f.rows[i%len(f.rows)]
+}
+
+// Next returns the next row, nil for a nil feeder.
+func (f *Feeder) Next() map[string]interface{} {
+    if f.Len() == 0 {
+        return nil
+    }
+    return f.Row(int((atomic.AddUint64(&f.next, 1) - 1) % uint64(len(f.rows))))
+}
+
+type feederKey struct{}
+
+// WithFeeder returns a copy of ctx whose petitions get the rows of f.
+func WithFeeder(ctx context.Context, f *Feeder) context.Context {
+    return context.WithValue(ctx, feederKey{}, f)
+}
+
+// FromContext returns the feeder of ctx, nil when none was set.
+func FromContext(ctx context.Context) *Feeder {
+    f, _ := ctx.Value(feederKey{}).(*Feeder)
+    return f
+}
@@ -0,0 +1,83 @@
+package feeder
+
+import (
+    "context"
+    "reflect"
+    "strings"
+    "sync"
+    "testing"
+    "testing/fstest"
+)
+
+func TestParse(t *testing.T) {
+    tests := []struct {
+        name string
+        raw  string
+        rows []map[string]interface{}
+        err  string
+    }{
+        {"users.csv", "user,pass\nann,a1\nbob,b2\n", []map[string]interface{}{{"user": "ann", "pass": "a1"}, {"user": "bob", "pass": "b2"}}, ""},
+        {"USERS.CSV", "user\n\"a, b\"\n", []map[string]interface{}{{"user": "a, b"}}, ""},
+        {"users.json", `[{"id": 1, "tags": ["a"]}]`, []map[string]interface{}{{"id": 1.0, "tags": []interface{}{"a"}}}, ""},
+        {"header.csv", "user\n", nil, "no rows"},
+        {"empty.csv", "", nil, "no header line"},
+        {"ragged.csv", "user,pass\nann\n", nil, "wrong number of fields"},
+        {"empty.json", `[]`, nil, "no rows"},
+        {"object.json", `{"id": 1}`, nil, "not a JSON array of objects"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            f, err := Parse(tt.name, []byte(tt.raw))
+            if tt.err != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.err) {
+                    t.Fatalf("error = %v, want %q", err, tt.err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(f.rows, tt.rows) {
+                t.Errorf("rows = %v, want %v", f.rows, tt.rows)
+            }
+        })
+    }
+}
+
+func TestNext(t *testing.T) {
+    f, err := Load(fstest.MapFS{"data/ids.csv": {Data: []byte("id\n0\n1\n2\n")}}, "data/ids.csv")
+    if err != nil {
+        t.Fatal(err)
+    }
+    var mu sync.Mutex
+    counts := make(map[interface{}]int)
+    var wg sync.WaitGroup
+    for user := 0; user < 3; user++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := 0; i < 4; i++ {
+                row := f.Next()
+                mu.Lock()
+                counts[row["id"]]++
+                mu.Unlock()
+            }
+        }()
+    }
+    wg.Wait()
+    if want := map[interface{}]int{"0": 4, "1": 4, "2": 4}; !reflect.DeepEqual(counts, want) {
+        t.Errorf("rows handed %v, want each one 4 times", counts)
+    }
+    if got := f.Row(4)["id"]; got != "1" {
+        t.Errorf("Row(4) = %v, want the second row", got)
+    }
+    
+    var none *Feeder
+    if none.Next() != nil || none.Len() != 0 {
+        t.Errorf("a nil feeder handed rows")
+    }
+    ctx := WithFeeder(context.Background(), f)
+    if FromContext(ctx) != f || FromContext(context.Background()) != nil {
+        t.Errorf("FromContext did not return the feeder of the context")
+    }
+}
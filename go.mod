@@ -1,8 +1,9 @@
 module github.com/jarlex/gommander
 
-go 1.12
+go 1.16
 
 require (
 	github.com/jarlex/transporter v1.1.1
 	github.com/spf13/cobra v0.0.5
+	gopkg.in/yaml.v3 v3.0.1
 )
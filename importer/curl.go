@@ -0,0 +1,278 @@
+package importer
+
+import (
+    "encoding/base64"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/url"
+    "strings"
+)
+
+// curlSkipped are the curl options taking a value that do not change the
+// petition.
+var curlSkipped = map[string]bool{
+    "-o": true, "--output": true, "-m": true, "--max-time": true,
+    "--connect-timeout": true, "-w": true, "--write-out": true,
+    "--cacert": true, "--cert": true, "-E": true, "--key": true,
+    "-x": true, "--proxy": true, "--retry": true, "-c": true, "--cookie-jar": true,
+}
+
+// Curl reads curl command lines, one per line, lines ending with a backslash
+// continuing on the next one, as copied from a browser or a shell history.
+func Curl(r io.Reader) ([]Entry, error) {
+    raw, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    commands, err := shellWords(string(raw))
+    if err != nil {
+        return nil, err
+    }
+    var entries []Entry
+    for _, args := range commands {
+        if len(args) == 0 || args[0] != "curl" {
+            continue
+        }
+        e, err := curlEntry(args[1:])
+        if err != nil {
+            return nil, err
+        }
+        entries = append(entries, e)
+    }
+    if len(entries) == 0 {
+        return nil, fmt.Errorf("no curl command found")
+    }
+    return entries, nil
+}
+
+func curlEntry(args []string) (Entry, error) {
+    e := Entry{Headers: make(map[string]string)}
+    var data []string
+    get := false
+    for i := 0; i < len(args); i++ {
+        arg := args[i]
+        if !strings.HasPrefix(arg, "-") || arg == "-" {
+            e.URL = arg
+            continue
+        }
+        name, value, inline := arg, "", false
+        if strings.HasPrefix(arg, "--") {
+            if eq := strings.Index(arg, "="); eq > 0 {
+                name, value, inline = arg[:eq], arg[eq+1:], true
+            }
+        } else if len(arg) > 2 && strings.Contains("XHdubAe", arg[1:2]) {
+            name, value, inline = arg[:2], arg[2:], true
+        }
+        next := func() (string, error) {
+            if inline {
+                return value, nil
+            }
+            if i+1 >= len(args) {
+                return "", fmt.Errorf("curl: %s needs a value", name)
+            }
+            i++
+            return args[i], nil
+        }
+        
+        var err error
+        switch name {
+        case "-X", "--request":
+            e.Method, err = next()
+        case "-H", "--header":
+            var h string
+            if h, err = next(); err == nil {
+                if colon := strings.Index(h, ":"); colon > 0 {
+                    e.Headers[strings.TrimSpace(h[:colon])] = strings.TrimSpace(h[colon+1:])
+                }
+            }
+        case "-d", "--data", "--data-ascii", "--data-binary":
+            var d string
+            if d, err = next(); err == nil {
+                if strings.HasPrefix(d, "@") {
+                    var raw []byte
+                    raw, err = ioutil.ReadFile(d[1:])
+                    d = string(raw)
+                }
+                data = append(data, d)
+            }
+        case "--data-raw":
+            var d string
+            if d, err = next(); err == nil {
+                data = append(data, d)
+            }
+        case "--data-urlencode":
+            var d string
+            if d, err = next(); err == nil {
+                if eq := strings.Index(d, "="); eq >= 0 {
+                    d = d[:eq+1] + url.QueryEscape(d[eq+1:])
+                } else {
+                    d = url.QueryEscape(d)
+                }
+                data = append(data, d)
+            }
+        case "--json":
+            var d string
+            if d, err = next(); err == nil {
+                data = append(data, d)
+                e.Headers["Content-Type"] = "application/json"
+                e.Headers["Accept"] = "application/json"
+            }
+        case "-u", "--user":
+            var u string
+            if u, err = next(); err == nil {
+                e.Headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(u))
+            }
+        case "-b", "--cookie":
+            e.Headers["Cookie"], err = next()
+        case "-A", "--user-agent":
+            e.Headers["User-Agent"], err = next()
+        case "-e", "--referer":
+            e.Headers["Referer"], err = next()
+        case "--url":
+            e.URL, err = next()
+        case "-G", "--get":
+            get = true
+        case "-I", "--head":
+            e.Method = "HEAD"
+        case "-F", "--form":
+            return e, fmt.Errorf("curl: multipart forms (%s) are not supported", name)
+        default:
+            if curlSkipped[name] {
+                _, err = next()
+            }
+        }
+        if err != nil {
+            return e, err
+        }
+    }
+    if e.URL == "" {
+        return e, fmt.Errorf("curl: no URL")
+    }
+    
+    body := strings.Join(data, "&")
+    switch {
+    case get && body != "":
+        sep := "?"
+        if strings.Contains(e.URL, "?") {
+            sep = "&"
+        }
+        e.URL += sep + body
+    case body != "":
+        e.Body = body
+        if e.Method == "" {
+            e.Method = "POST"
+        }
+        if _, ok := e.Headers["Content-Type"]; !ok && !strings.HasPrefix(strings.TrimSpace(body), "{") {
+            e.Headers["Content-Type"] = "application/x-www-form-urlencoded"
+        }
+    }
+    if e.Method == "" {
+        e.Method = "GET"
+    }
+    return e, nil
+}
+
+// shellWords splits s into commands, one per line, and each command into
+// words, following the quoting rules of a POSIX shell, plus the $'...' strings
+// of bash.
+func shellWords(s string) ([][]string, error) {
+    var commands [][]string
+    var words []string
+    var word strings.Builder
+    inWord := false
+    endWord := func() {
+        if inWord {
+            words = append(words, word.String())
+            word.Reset()
+            inWord = false
+        }
+    }
+    endCommand := func() {
+        endWord()
+        if len(words) > 0 {
+            commands = append(commands, words)
+            words = nil
+        }
+    }
+    
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        switch {
+        case c == '\\':
+            if i+1 < len(s) {
+                i++
+                if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+                    i++
+                }
+                if s[i] != '\n' {
+                    word.WriteByte(s[i])
+                    inWord = true
+                }
+            }
+        case c == '\'':
+            end := strings.IndexByte(s[i+1:], '\'')
+            if end < 0 {
+                return nil, fmt.Errorf("unterminated quote")
+            }
+            word.WriteString(s[i+1 : i+1+end])
+            inWord = true
+            i += end + 1
+        case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+            i += 2
+            for ; i < len(s) && s[i] != '\''; i++ {
+                if s[i] == '\\' && i+1 < len(s) {
+                    i++
+                    word.WriteString(ansiEscape(s[i]))
+                    continue
+                }
+                word.WriteByte(s[i])
+            }
+            if i >= len(s) {
+                return nil, fmt.Errorf("unterminated quote")
+            }
+            inWord = true
+        case c == '"':
+            i++
+            for ; i < len(s) && s[i] != '"'; i++ {
+                if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+                    i++
+                    if s[i] == '\n' {
+                        continue
+                    }
+                }
+                word.WriteByte(s[i])
+            }
+            if i >= len(s) {
+                return nil, fmt.Errorf("unterminated quote")
+            }
+            inWord = true
+        case c == '#' && !inWord:
+            for i < len(s) && s[i] != '\n' {
+                i++
+            }
+            endCommand()
+        case c == '\n' || c == ';':
+            endCommand()
+        case c == ' ' || c == '\t' || c == '\r':
+            endWord()
+        default:
+            word.WriteByte(c)
+            inWord = true
+        }
+    }
+    endCommand()
+    return commands, nil
+}
+
+func ansiEscape(c byte) string {
+    switch c {
+    case 'n':
+        return "\n"
+    case 't':
+        return "\t"
+    case 'r':
+        return "\r"
+    }
+    return string(c)
+}
@@ -0,0 +1,120 @@
+package importer
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestShellWords(t *testing.T) {
+    tests := []struct {
+        name string
+        in   string
+        want [][]string
+        err  string
+    }{
+        {"words", "curl  -X\tGET http://a", [][]string{{"curl", "-X", "GET", "http://a"}}, ""},
+        {"single quotes", `curl -d '{"a": "b c"}' 'it'\''s'`, [][]string{{"curl", "-d", `{"a": "b c"}`, "it's"}}, ""},
+        {"double quotes", `curl -H "X-A: \"q\" \$HOME \n"`, [][]string{{"curl", "-H", `X-A: "q" $HOME \n`}}, ""},
+        {"ansi c quotes", `curl -d $'a\nb\'c'`, [][]string{{"curl", "-d", "a\nb'c"}}, ""},
+        {"escapes", `curl a\ b \"c`, [][]string{{"curl", "a b", `"c`}}, ""},
+        {"empty word", `curl ''`, [][]string{{"curl", ""}}, ""},
+        {"line continuation", "curl \\\n  -X POST \\\r\n  http://a", [][]string{{"curl", "-X", "POST", "http://a"}}, ""},
+        {"continuation in double quotes", "curl \"a\\\nb\"", [][]string{{"curl", "ab"}}, ""},
+        {"commands", "curl a\n\ncurl b; curl c\n", [][]string{{"curl", "a"}, {"curl", "b"}, {"curl", "c"}}, ""},
+        {"comments", "# copied\ncurl a#b # c\n", [][]string{{"curl", "a#b"}}, ""},
+        {"unterminated single", "curl 'a", nil, "unterminated quote"},
+        {"unterminated double", `curl "a`, nil, "unterminated quote"},
+        {"unterminated ansi", `curl $'a`, nil, "unterminated quote"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := shellWords(tt.in)
+            if tt.err != "" {
+                if err == nil || err.Error() != tt.err {
+                    t.Fatalf("error = %v, want %s", err, tt.err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("shellWords = %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestCurl(t *testing.T) {
+    tests := []struct {
+        name string
+        in   string
+        want Entry
+        err  string
+    }{
+        {
+            name: "get",
+            in:   "curl http://a/items",
+            want: Entry{Method: "GET", URL: "http://a/items", Headers: map[string]string{}},
+        },
+        {
+            name: "headers and method",
+            in:   `curl -X PUT -H 'Accept: application/json' -HX-Id:7 --header="X-Trace: 1" --url http://a/items/7`,
+            want: Entry{Method: "PUT", URL: "http://a/items/7", Headers: map[string]string{"Accept": "application/json", "X-Id": "7", "X-Trace": "1"}},
+        },
+        {
+            name: "json data posts",
+            in:   `curl http://a/items --data-raw '{"name": "x"}'`,
+            want: Entry{Method: "POST", URL: "http://a/items", Headers: map[string]string{}, Body: `{"name": "x"}`},
+        },
+        {
+            name: "form data",
+            in:   `curl http://a/login -d user=u -d pass=p --data-urlencode 'q=a b'`,
+            want: Entry{Method: "POST", URL: "http://a/login", Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, Body: "user=u&pass=p&q=a+b"},
+        },
+        {
+            name: "get data",
+            in:   `curl -G http://a/search?x=1 -d q=go`,
+            want: Entry{Method: "GET", URL: "http://a/search?x=1&q=go", Headers: map[string]string{}},
+        },
+        {
+            name: "json flag",
+            in:   `curl --json '{"a":1}' http://a`,
+            want: Entry{Method: "POST", URL: "http://a", Headers: map[string]string{"Content-Type": "application/json", "Accept": "application/json"}, Body: `{"a":1}`},
+        },
+        {
+            name: "auth, cookies and agents",
+            in:   `curl -u user:pass -b 'sid=1' -A agent -e http://ref -I http://a`,
+            want: Entry{Method: "HEAD", URL: "http://a", Headers: map[string]string{
+                "Authorization": "Basic dXNlcjpwYXNz", "Cookie": "sid=1", "User-Agent": "agent", "Referer": "http://ref",
+            }},
+        },
+        {
+            name: "skipped options",
+            in:   `curl -s -o out.json --max-time 5 -w '%{http_code}' --compressed http://a`,
+            want: Entry{Method: "GET", URL: "http://a", Headers: map[string]string{}},
+        },
+        {name: "form", in: "curl -F a=@file http://a", err: "curl: multipart forms (-F) are not supported"},
+        {name: "missing value", in: "curl http://a -H", err: "curl: -H needs a value"},
+        {name: "no url", in: "curl -X GET", err: "curl: no URL"},
+        {name: "no curl", in: "wget http://a", err: "no curl command found"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            entries, err := Curl(strings.NewReader(tt.in))
+            if tt.err != "" {
+                if err == nil || err.Error() != tt.err {
+                    t.Fatalf("error = %v, want %s", err, tt.err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(entries) != 1 || !reflect.DeepEqual(entries[0], tt.want) {
+                t.Errorf("entries = %+v\nwant %+v", entries, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,110 @@
+package importer
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "io"
+    "net/url"
+    "strings"
+    "time"
+)
+
+type harFile struct {
+    Log struct {
+        Entries []harEntry `json:"entries"`
+    } `json:"log"`
+}
+
+type harEntry struct {
+    ResourceType string    `json:"_resourceType"`
+    Started      time.Time `json:"startedDateTime"`
+    Time         float64   `json:"time"` // Milliseconds the petition took
+    Request      struct {
+        Method   string      `json:"method"`
+        URL      string      `json:"url"`
+        Headers  []harHeader `json:"headers"`
+        PostData *struct {
+            MimeType string      `json:"mimeType"`
+            Text     string      `json:"text"`
+            Params   []harHeader `json:"params"`
+        } `json:"postData"`
+    } `json:"request"`
+    Response struct {
+        Status  int `json:"status"`
+        Content struct {
+            MimeType string `json:"mimeType"`
+            Text     string `json:"text"`
+            Encoding string `json:"encoding"`
+        } `json:"content"`
+    } `json:"response"`
+}
+
+type harHeader struct {
+    Name  string `json:"name"`
+    Value string `json:"value"`
+}
+
+// harSkipped are the resource types of a browser capture that are not API
+// calls.
+var harSkipped = map[string]bool{
+    "image": true, "stylesheet": true, "script": true, "font": true, "media": true,
+}
+
+// static tells whether a response of type mime is an asset rather than an
+// API call.
+func static(mime string) bool {
+    return strings.HasPrefix(mime, "image/") || strings.HasPrefix(mime, "font/") ||
+        strings.Contains(mime, "css") || strings.Contains(mime, "javascript")
+}
+
+// HAR reads the entries of a HTTP Archive, as saved by the browsers, in
+// order. Images, style sheets, scripts, fonts and media are left out. The
+// gaps between the end of an entry and the start of the next one become
+// think times, like for a recording.
+func HAR(r io.Reader) ([]Entry, error) {
+    var har harFile
+    if err := json.NewDecoder(r).Decode(&har); err != nil {
+        return nil, err
+    }
+    var entries []Entry
+    var last time.Time // End of the previous entry kept
+    for _, h := range har.Log.Entries {
+        if harSkipped[h.ResourceType] || static(h.Response.Content.MimeType) {
+            continue
+        }
+        e := Entry{
+            Method:  h.Request.Method,
+            URL:     h.Request.URL,
+            Headers: make(map[string]string),
+            Status:  h.Response.Status,
+        }
+        for _, header := range h.Request.Headers {
+            e.Headers[header.Name] = header.Value
+        }
+        if data := h.Request.PostData; data != nil {
+            e.Body = data.Text
+            if e.Body == "" && len(data.Params) > 0 {
+                form := url.Values{}
+                for _, p := range data.Params {
+                    form.Add(p.Name, p.Value)
+                }
+                e.Body = form.Encode()
+            }
+        }
+        e.Response = h.Response.Content.Text
+        if h.Response.Content.Encoding == "base64" {
+            raw, err := base64.StdEncoding.DecodeString(e.Response)
+            if err == nil {
+                e.Response = string(raw)
+            }
+        }
+        if !h.Started.IsZero() {
+            if gap := h.Started.Sub(last); !last.IsZero() && gap >= minThinkTime {
+                e.Think = gap.Round(minThinkTime)
+            }
+            last = h.Started.Add(time.Duration(h.Time * float64(time.Millisecond)))
+        }
+        entries = append(entries, e)
+    }
+    return entries, nil
+}
@@ -0,0 +1,95 @@
+package importer
+
+import (
+    "reflect"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+const harCapture = `{"log": {"entries": [
+  {"request": {"method": "GET", "url": "http://a/app.js", "headers": []},
+   "response": {"status": 200, "content": {"mimeType": "application/javascript"}}},
+  {"_resourceType": "image", "request": {"method": "GET", "url": "http://a/logo", "headers": []},
+   "response": {"status": 200, "content": {"mimeType": "application/octet-stream"}}},
+  {"request": {"method": "POST", "url": "http://a/login",
+    "headers": [{"name": "Accept", "value": "application/json"}],
+    "postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "user", "value": "u"}, {"name": "pass", "value": "p w"}]}},
+   "response": {"status": 200, "content": {"mimeType": "application/json", "text": "eyJpZCI6IDd9", "encoding": "base64"}}},
+  {"request": {"method": "PUT", "url": "http://a/users/7", "headers": [],
+    "postData": {"mimeType": "application/json", "text": "{\"name\": \"x\"}"}},
+   "response": {"status": 204, "content": {"mimeType": ""}}}
+]}}`
+
+func TestHAR(t *testing.T) {
+    entries, err := HAR(strings.NewReader(harCapture))
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []Entry{
+        {Method: "POST", URL: "http://a/login", Headers: map[string]string{"Accept": "application/json"}, Body: "pass=p+w&user=u", Status: 200, Response: `{"id": 7}`},
+        {Method: "PUT", URL: "http://a/users/7", Headers: map[string]string{}, Body: `{"name": "x"}`, Status: 204},
+    }
+    if !reflect.DeepEqual(entries, want) {
+        t.Errorf("entries = %+v\nwant %+v", entries, want)
+    }
+    if _, err := HAR(strings.NewReader("{")); err == nil {
+        t.Error("no error for an invalid HAR")
+    }
+}
+
+func TestHARThinkTime(t *testing.T) {
+    entry := func(url, started string, took float64, mime string) string {
+        return `{"startedDateTime": "` + started + `", "time": ` + strconv.FormatFloat(took, 'f', -1, 64) + `,
+          "request": {"method": "GET", "url": "` + url + `", "headers": []},
+          "response": {"status": 200, "content": {"mimeType": "` + mime + `"}}}`
+    }
+    tests := []struct {
+        name    string
+        entries []string
+        want    []time.Duration
+    }{
+        {"no timings", []string{
+            `{"request": {"method": "GET", "url": "http://a/1", "headers": []}, "response": {"status": 200, "content": {}}}`,
+            `{"request": {"method": "GET", "url": "http://a/2", "headers": []}, "response": {"status": 200, "content": {}}}`,
+        }, []time.Duration{0, 0}},
+        {"gap after the end", []string{
+            entry("http://a/1", "2024-05-01T10:00:00.000Z", 200, "application/json"),
+            entry("http://a/2", "2024-05-01T10:00:01.700Z", 50, "application/json"),
+        }, []time.Duration{0, 1500 * time.Millisecond}},
+        {"rounded", []string{
+            entry("http://a/1", "2024-05-01T10:00:00.000Z", 0, "application/json"),
+            entry("http://a/2", "2024-05-01T10:00:02.340+00:00", 0, "application/json"),
+        }, []time.Duration{0, 2300 * time.Millisecond}},
+        {"short gap", []string{
+            entry("http://a/1", "2024-05-01T10:00:00.000Z", 100, "application/json"),
+            entry("http://a/2", "2024-05-01T10:00:00.150Z", 100, "application/json"),
+        }, []time.Duration{0, 0}},
+        {"overlapping", []string{
+            entry("http://a/1", "2024-05-01T10:00:00.000Z", 500, "application/json"),
+            entry("http://a/2", "2024-05-01T10:00:00.200Z", 100, "application/json"),
+        }, []time.Duration{0, 0}},
+        {"assets between", []string{
+            entry("http://a/1", "2024-05-01T10:00:00.000Z", 100, "application/json"),
+            entry("http://a/app.js", "2024-05-01T10:00:00.500Z", 100, "application/javascript"),
+            entry("http://a/2", "2024-05-01T10:00:03.100Z", 100, "application/json"),
+        }, []time.Duration{0, 3 * time.Second}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            har := `{"log": {"entries": [` + strings.Join(tt.entries, ",") + `]}}`
+            entries, err := HAR(strings.NewReader(har))
+            if err != nil {
+                t.Fatal(err)
+            }
+            var got []time.Duration
+            for _, e := range entries {
+                got = append(got, e.Think)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("think times %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,281 @@
+// Package importer turns captured traffic, curl command lines, HAR files and
+// Postman collections, into plans.
+package importer
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+    "unicode"
+    
+    "github.com/jarlex/gommander/plan"
+)
+
+// Entry is a captured petition.
+type Entry struct {
+    Name     string // Derived from the method and the path when empty
+    Method   string
+    URL      string
+    Headers  map[string]string
+    Body     string
+    Status   int                    // Status of the response, 0 when unknown
+    Response string                 // Body of the response, empty when unknown
+    Schema   map[string]interface{} // JSON Schema the response body must match, if any
+    Think    time.Duration          // Pause before the petition
+}
+
+// Suggestion is a value returned by a task and reused in the URL of a later
+// one, which the first task could extract.
+type Suggestion struct {
+    Task  string // Task whose response holds the value
+    Field string // Top level field of the response
+    Value string
+    Next  string // Task using the value
+}
+
+func (s Suggestion) String() string {
+    return fmt.Sprintf("%s returns %s=%q, used in the path of %s as {{%s}}", s.Task, s.Field, s.Value, s.Next, s.Field)
+}
+
+// braces unescapes the {{name}} parameters of a path.
+var braces = strings.NewReplacer("%7B", "{", "%7D", "}")
+
+// skippedHeaders are set by the HTTP client itself or only concern the
+// proxies.
+var skippedHeaders = map[string]bool{
+    "host":                true,
+    "content-length":      true,
+    "connection":          true,
+    "accept-encoding":     true,
+    "keep-alive":          true,
+    "proxy-connection":    true,
+    "proxy-authorization": true,
+    "te":                  true,
+    "trailer":             true,
+    "transfer-encoding":   true,
+    "upgrade":             true,
+}
+
+// Build returns the plan called name with a step, default, where a single
+// user runs a task per entry, in order. Entries on the host of the first one
+// use the plan URL, the others carry their own. The values returned by a
+// task and reused in a later path are returned as suggestions, and applied
+// when chain is set: the task extracts the field and the later path uses it.
+func Build(name string, entries []Entry, chain bool) (*plan.Plan, []Suggestion, error) {
+    if len(entries) == 0 {
+        return nil, nil, fmt.Errorf("nothing to import")
+    }
+    
+    targets := make([]*url.URL, len(entries))
+    names := make([]string, len(entries))
+    used := make(map[string]int)
+    for i, e := range entries {
+        u, err := url.Parse(e.URL)
+        if err != nil || u.Scheme == "" || u.Host == "" {
+            return nil, nil, fmt.Errorf("%s %s: not an absolute URL", e.Method, e.URL)
+        }
+        targets[i] = u
+        names[i] = uniqueName(used, taskName(e, u))
+    }
+    base := targets[0].Scheme + "://" + targets[0].Host
+    
+    paths := make([]string, len(entries))
+    for i, u := range targets {
+        paths[i] = braces.Replace(u.EscapedPath())
+        if paths[i] == "" {
+            paths[i] = "/"
+        }
+    }
+    suggestions := chaining(entries, names, paths)
+    needs := make(map[int][]string)
+    extracts := make(map[int][]string)
+    if chain {
+        for _, s := range suggestions {
+            from, to := index(names, s.Task), index(names, s.Next)
+            paths[to] = replaceSegment(paths[to], s.Value, "{{"+s.Field+"}}")
+            extracts[from] = appendOnce(extracts[from], s.Field)
+            needs[to] = appendOnce(needs[to], s.Field)
+        }
+    }
+    
+    b := plan.New(name).URL(base).Step("default", 1, 1)
+    for i, e := range entries {
+        status := e.Status
+        if status == 0 {
+            status = 200
+        }
+        path := paths[i]
+        if targets[i].RawQuery != "" {
+            path += "?" + targets[i].RawQuery
+        }
+        method := strings.ToUpper(e.Method)
+        if method == "" {
+            method = "GET"
+        }
+        b.Task(names[i], status)
+        if len(needs[i]) > 0 {
+            b.Needs(needs[i]...)
+        }
+        if len(extracts[i]) > 0 {
+            b.Extract(extracts[i]...)
+        }
+        if e.Schema != nil {
+            b.Schema(e.Schema)
+        }
+        if e.Think > 0 {
+            b.ThinkTime(e.Think)
+        }
+        b.Request(names[i], method, path)
+        if host := targets[i].Scheme + "://" + targets[i].Host; host != base {
+            b.RequestURL(host)
+        }
+        for k, v := range e.Headers {
+            if skippedHeaders[strings.ToLower(k)] || strings.HasPrefix(k, ":") {
+                continue
+            }
+            b.Header(k, v)
+        }
+        if e.Body != "" {
+            var body map[string]interface{}
+            if json.Unmarshal([]byte(e.Body), &body) == nil {
+                b.Body(body)
+            } else {
+                b.RawBody(e.Body)
+            }
+        }
+    }
+    p, err := b.Build()
+    if err != nil {
+        return nil, nil, err
+    }
+    return p, suggestions, nil
+}
+
+// chaining finds the top level values of the JSON responses reused as a
+// segment of a later path. The latest response holding a value wins.
+func chaining(entries []Entry, names, paths []string) []Suggestion {
+    var suggestions []Suggestion
+    for to := range entries {
+        for _, segment := range strings.Split(paths[to], "/") {
+            if len(segment) < 2 {
+                continue
+            }
+            for from := to - 1; from >= 0; from-- {
+                if field := fieldWithValue(entries[from].Response, segment); field != "" {
+                    suggestions = append(suggestions, Suggestion{Task: names[from], Field: field, Value: segment, Next: names[to]})
+                    break
+                }
+            }
+        }
+    }
+    return suggestions
+}
+
+func fieldWithValue(response, value string) string {
+    var fields map[string]interface{}
+    if json.Unmarshal([]byte(response), &fields) != nil {
+        return ""
+    }
+    keys := make([]string, 0, len(fields))
+    for k := range fields {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    for _, k := range keys {
+        switch v := fields[k].(type) {
+        case string:
+            if v == value {
+                return k
+            }
+        case float64:
+            if strconv.FormatFloat(v, 'f', -1, 64) == value {
+                return k
+            }
+        }
+    }
+    return ""
+}
+
+func replaceSegment(path, value, with string) string {
+    segments := strings.Split(path, "/")
+    for i, s := range segments {
+        if s == value {
+            segments[i] = with
+        }
+    }
+    return strings.Join(segments, "/")
+}
+
+// taskName returns the name of the entry in camel case, or the method
+// followed by the path segments that do not look like ids.
+func taskName(e Entry, u *url.URL) string {
+    words := strings.FieldsFunc(e.Name, func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+    if len(words) == 0 {
+        words = []string{"get"}
+        if e.Method != "" {
+            words[0] = e.Method
+        }
+        for _, s := range strings.Split(u.Path, "/") {
+            if s != "" && !strings.ContainsAny(s, "0123456789{}:") {
+                words = append(words, strings.FieldsFunc(s, func(r rune) bool {
+                    return !unicode.IsLetter(r)
+                })...)
+            }
+        }
+    }
+    name := ""
+    for i, w := range words {
+        if strings.ToUpper(w) == w {
+            w = strings.ToLower(w)
+        }
+        r := []rune(w)
+        if i == 0 {
+            r[0] = unicode.ToLower(r[0])
+        } else {
+            r[0] = unicode.ToUpper(r[0])
+        }
+        name += string(r)
+    }
+    if name == "" {
+        return "request"
+    }
+    return name
+}
+
+func uniqueName(used map[string]int, name string) string {
+    used[name]++
+    if used[name] == 1 {
+        return name
+    }
+    unique := fmt.Sprintf("%s%d", name, used[name])
+    for used[unique] > 0 {
+        used[name]++
+        unique = fmt.Sprintf("%s%d", name, used[name])
+    }
+    used[unique]++
+    return unique
+}
+
+func index(names []string, name string) int {
+    for i, n := range names {
+        if n == name {
+            return i
+        }
+    }
+    return -1
+}
+
+func appendOnce(list []string, s string) []string {
+    for _, l := range list {
+        if l == s {
+            return list
+        }
+    }
+    return append(list, s)
+}
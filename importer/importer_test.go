@@ -0,0 +1,88 @@
+package importer
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestBuild(t *testing.T) {
+    entries := []Entry{
+        {Method: "post", URL: "http://shop/login", Headers: map[string]string{"Host": "shop", "Content-Length": "9", ":authority": "shop", "X-A": "1"}, Body: `{"user": "u"}`, Response: `{"token": "abc", "id": 77}`},
+        {Method: "GET", URL: "http://shop/users/77/orders?page=2", Status: 206, Response: `{"orderId": "o-1"}`},
+        {Method: "GET", URL: "http://shop/users/77/orders/o-1"},
+        {Name: "Ship it!", Method: "PUT", URL: "http://ship/orders/o-1", Body: "state=sent"},
+        {Method: "GET", URL: "http://shop/users/77/orders"},
+    }
+    wantSuggestions := []Suggestion{
+        {Task: "postLogin", Field: "id", Value: "77", Next: "getUsersOrders"},
+        {Task: "postLogin", Field: "id", Value: "77", Next: "getUsersOrders2"},
+        {Task: "getUsersOrders", Field: "orderId", Value: "o-1", Next: "getUsersOrders2"},
+        {Task: "getUsersOrders", Field: "orderId", Value: "o-1", Next: "shipIt"},
+        {Task: "postLogin", Field: "id", Value: "77", Next: "getUsersOrders3"},
+    }
+    
+    tests := []struct {
+        name  string
+        chain bool
+        paths []string
+    }{
+        {"suggest", false, []string{"/login", "/users/77/orders?page=2", "/users/77/orders/o-1", "/orders/o-1", "/users/77/orders"}},
+        {"chain", true, []string{"/login", "/users/{{id}}/orders?page=2", "/users/{{id}}/orders/{{orderId}}", "/orders/{{orderId}}", "/users/{{id}}/orders"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            p, suggestions, err := Build("shop", entries, tt.chain)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(suggestions, wantSuggestions) {
+                t.Errorf("suggestions = %+v", suggestions)
+            }
+            s := p.Steps[0]
+            if p.URL != "http://shop" || s.Name != "default" || s.ConcurrentUsers != 1 || s.NumPetitions != 1 {
+                t.Errorf("plan %s, step %+v", p.URL, s)
+            }
+            names := []string{"postLogin", "getUsersOrders", "getUsersOrders2", "shipIt", "getUsersOrders3"}
+            if !reflect.DeepEqual(s.TasksNames, names) {
+                t.Errorf("tasks = %q", s.TasksNames)
+            }
+            for i, task := range s.Tasks {
+                if task.Request.Path != tt.paths[i] {
+                    t.Errorf("task %s path = %s, want %s", task.Name, task.Request.Path, tt.paths[i])
+                }
+            }
+            
+            login, orders, ship := s.Tasks[0], s.Tasks[1], s.Tasks[3]
+            if login.Request.Method != "POST" || login.ExpectedStatus != 200 || login.Request.Body["user"] != "u" || login.Request.RawBody != "" {
+                t.Errorf("login = %+v, request %+v", login, login.Request)
+            }
+            if !reflect.DeepEqual(login.Request.Headers, map[string]string{"X-A": "1"}) {
+                t.Errorf("login headers = %v", login.Request.Headers)
+            }
+            if orders.ExpectedStatus != 206 {
+                t.Errorf("orders status = %d", orders.ExpectedStatus)
+            }
+            if ship.Request.URL != "http://ship" || ship.Request.RawBody != "state=sent" {
+                t.Errorf("ship request = %+v", ship.Request)
+            }
+            if tt.chain {
+                if !reflect.DeepEqual(login.NextData, []string{"id"}) || !reflect.DeepEqual(orders.NextData, []string{"orderId"}) {
+                    t.Errorf("extracted %q, %q", login.NextData, orders.NextData)
+                }
+                if got := s.Tasks[2].PreviousData; !reflect.DeepEqual(got, []string{"id", "orderId"}) {
+                    t.Errorf("needs = %q", got)
+                }
+            }
+        })
+    }
+}
+
+func TestBuildErrors(t *testing.T) {
+    if _, _, err := Build("p", nil, false); err == nil || err.Error() != "nothing to import" {
+        t.Errorf("error = %v", err)
+    }
+    _, _, err := Build("p", []Entry{{Method: "GET", URL: "/relative"}}, false)
+    if err == nil || err.Error() != "GET /relative: not an absolute URL" {
+        t.Errorf("error = %v", err)
+    }
+}
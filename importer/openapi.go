@@ -0,0 +1,534 @@
+package importer
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/url"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    
+    "gopkg.in/yaml.v3"
+)
+
+// openAPIMethods are the operations of a path item.
+var openAPIMethods = map[string]bool{
+    "get": true, "put": true, "post": true, "delete": true,
+    "options": true, "head": true, "patch": true, "trace": true,
+}
+
+var (
+    openAPIParam     = regexp.MustCompile(`{([^{}]+)}`)
+    openAPIComponent = regexp.MustCompile(`^#/components/schemas/(.+)$`)
+)
+
+// exampleDepth bounds the examples generated from recursive schemas.
+const exampleDepth = 6
+
+type openAPI struct {
+    spec map[string]interface{}
+}
+
+// OpenAPI reads the operations of an OpenAPI 3 spec, JSON or YAML, in the
+// order of the spec. base replaces the URL of the first server, required when
+// it is relative. Path parameters become {{name}} parameters returned as vars
+// with an example value, required query and header parameters and request
+// bodies get example values, from the spec or generated from the schemas.
+// Each operation expects its first success status and, when the spec
+// describes a JSON body for it, a response matching its schema. Bearer and
+// header API key security schemes read their values from ${env:API_TOKEN}
+// and ${env:<header name>}.
+func OpenAPI(r io.Reader, base string) (entries []Entry, vars map[string]interface{}, err error) {
+    raw, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, nil, err
+    }
+    var root yaml.Node
+    if err := yaml.Unmarshal(raw, &root); err != nil {
+        return nil, nil, err
+    }
+    if len(root.Content) == 0 {
+        return nil, nil, fmt.Errorf("empty spec")
+    }
+    var doc interface{}
+    if err := root.Decode(&doc); err != nil {
+        return nil, nil, err
+    }
+    spec, _ := normalize(doc).(map[string]interface{})
+    if version, _ := spec["openapi"].(string); !strings.HasPrefix(version, "3.") {
+        return nil, nil, fmt.Errorf("not an OpenAPI 3 spec")
+    }
+    o := &openAPI{spec: spec}
+    
+    serverPath, err := o.server(base)
+    if err != nil {
+        return nil, nil, err
+    }
+    u, _ := url.Parse(serverPath)
+    host := u.Scheme + "://" + u.Host
+    prefix := strings.TrimSuffix(u.Path, "/")
+    
+    vars = make(map[string]interface{})
+    paths := mappingValue(root.Content[0], "paths")
+    if paths == nil {
+        return nil, nil, fmt.Errorf("the spec has no paths")
+    }
+    for i := 0; i+1 < len(paths.Content); i += 2 {
+        path := paths.Content[i].Value
+        item := o.deref(lookup(spec, "paths", path))
+        itemParams, _ := item["parameters"].([]interface{})
+        for j := 0; j+1 < len(paths.Content[i+1].Content); j += 2 {
+            method := paths.Content[i+1].Content[j].Value
+            if !openAPIMethods[method] {
+                continue
+            }
+            op, _ := item[method].(map[string]interface{})
+            e, err := o.entry(host, prefix, path, method, op, itemParams, vars)
+            if err != nil {
+                return nil, nil, fmt.Errorf("%s %s: %s", strings.ToUpper(method), path, err.Error())
+            }
+            entries = append(entries, e)
+        }
+    }
+    if len(entries) == 0 {
+        return nil, nil, fmt.Errorf("the spec has no operations")
+    }
+    return entries, vars, nil
+}
+
+// server returns the URL of the first server, its variables replaced by
+// their defaults, or base when set.
+func (o *openAPI) server(base string) (string, error) {
+    if base == "" {
+        servers, _ := o.spec["servers"].([]interface{})
+        if len(servers) > 0 {
+            server, _ := servers[0].(map[string]interface{})
+            base, _ = server["url"].(string)
+            variables, _ := server["variables"].(map[string]interface{})
+            base = openAPIParam.ReplaceAllStringFunc(base, func(m string) string {
+                v, _ := variables[m[1:len(m)-1]].(map[string]interface{})
+                return fmt.Sprint(v["default"])
+            })
+        }
+    }
+    u, err := url.Parse(base)
+    if err != nil || u.Scheme == "" || u.Host == "" {
+        return "", fmt.Errorf("the server URL %q is not absolute, give one", base)
+    }
+    return base, nil
+}
+
+func (o *openAPI) entry(host, prefix, path, method string, op map[string]interface{}, itemParams []interface{}, vars map[string]interface{}) (Entry, error) {
+    name, _ := op["operationId"].(string)
+    e := Entry{Name: name, Method: strings.ToUpper(method), Headers: make(map[string]string)}
+    
+    // The operation parameters override the path item ones
+    params := make(map[string]map[string]interface{})
+    var order []string
+    opParams, _ := op["parameters"].([]interface{})
+    for _, p := range append(append([]interface{}{}, itemParams...), opParams...) {
+        param := o.deref(p)
+        if param == nil {
+            continue
+        }
+        key := fmt.Sprint(param["in"], ":", param["name"])
+        if _, ok := params[key]; !ok {
+            order = append(order, key)
+        }
+        params[key] = param
+    }
+    var query []string
+    for _, key := range order {
+        param := params[key]
+        name, _ := param["name"].(string)
+        required, _ := param["required"].(bool)
+        switch param["in"] {
+        case "path":
+            if _, ok := vars[name]; !ok {
+                vars[name] = scalar(o.paramExample(param))
+            }
+        case "query":
+            if required {
+                query = append(query, url.QueryEscape(name)+"="+url.QueryEscape(scalar(o.paramExample(param))))
+            }
+        case "header":
+            if required {
+                e.Headers[name] = scalar(o.paramExample(param))
+            }
+        }
+    }
+    e.URL = host + prefix + openAPIParam.ReplaceAllString(path, "{{$1}}")
+    if len(query) > 0 {
+        e.URL += "?" + strings.Join(query, "&")
+    }
+    o.security(op, e.Headers)
+    
+    if body := o.deref(op["requestBody"]); body != nil {
+        content, _ := body["content"].(map[string]interface{})
+        for _, mediaType := range sortedKeys(content) {
+            media := o.deref(content[mediaType])
+            if isJSON(mediaType) {
+                raw, err := json.Marshal(o.mediaExample(media, true))
+                if err != nil {
+                    return e, err
+                }
+                e.Body = string(raw)
+                e.Headers["Content-Type"] = mediaType
+                break
+            }
+            if mediaType == "application/x-www-form-urlencoded" {
+                fields, _ := o.mediaExample(media, true).(map[string]interface{})
+                form := url.Values{}
+                for k, v := range fields {
+                    form.Set(k, scalar(v))
+                }
+                e.Body = form.Encode()
+                e.Headers["Content-Type"] = mediaType
+                break
+            }
+        }
+    }
+    
+    // The first success response, or the default one
+    responses, _ := op["responses"].(map[string]interface{})
+    code := ""
+    for _, c := range sortedKeys(responses) {
+        if strings.HasPrefix(c, "2") {
+            code = c
+            break
+        }
+    }
+    if code == "" {
+        if _, ok := responses["default"]; !ok {
+            return e, fmt.Errorf("no success response")
+        }
+        code = "default"
+    }
+    fmt.Sscanf(strings.Replace(code, "XX", "00", 1), "%d", &e.Status)
+    if e.Status == 0 {
+        e.Status = 200
+    }
+    resp := o.deref(responses[code])
+    content, _ := resp["content"].(map[string]interface{})
+    for _, mediaType := range sortedKeys(content) {
+        if !isJSON(mediaType) {
+            continue
+        }
+        media := o.deref(content[mediaType])
+        if schema := o.jsonSchema(media["schema"]); schema != nil {
+            e.Schema = schema
+        }
+        if example := o.mediaExample(media, false); example != nil {
+            raw, _ := json.Marshal(example)
+            e.Response = string(raw)
+        }
+        break
+    }
+    return e, nil
+}
+
+// security sets the headers of the first security requirement of op, or of
+// the spec, reading the credentials from environment variables.
+func (o *openAPI) security(op map[string]interface{}, headers map[string]string) {
+    requirements, ok := op["security"].([]interface{})
+    if !ok {
+        requirements, _ = o.spec["security"].([]interface{})
+    }
+    if len(requirements) == 0 {
+        return
+    }
+    first, _ := requirements[0].(map[string]interface{})
+    for _, name := range sortedKeys(first) {
+        scheme := o.deref(lookup(o.spec, "components", "securitySchemes", name))
+        switch {
+        case scheme["type"] == "http" && strings.EqualFold(fmt.Sprint(scheme["scheme"]), "bearer"):
+            headers["Authorization"] = "Bearer ${env:API_TOKEN}"
+        case scheme["type"] == "apiKey" && scheme["in"] == "header":
+            header, _ := scheme["name"].(string)
+            headers[header] = "${env:" + envName(header) + "}"
+        }
+    }
+}
+
+func (o *openAPI) paramExample(param map[string]interface{}) interface{} {
+    if example, ok := param["example"]; ok {
+        return example
+    }
+    if examples, ok := param["examples"].(map[string]interface{}); ok {
+        for _, k := range sortedKeys(examples) {
+            if example := o.deref(examples[k]); example != nil {
+                return example["value"]
+            }
+        }
+    }
+    return o.example(param["schema"], 0, true)
+}
+
+// mediaExample returns the example of a media type, generated from its
+// schema when generate is set and the spec gives none.
+func (o *openAPI) mediaExample(media map[string]interface{}, generate bool) interface{} {
+    if example, ok := media["example"]; ok {
+        return example
+    }
+    if examples, ok := media["examples"].(map[string]interface{}); ok {
+        for _, k := range sortedKeys(examples) {
+            if example := o.deref(examples[k]); example != nil {
+                return example["value"]
+            }
+        }
+    }
+    if !generate {
+        return nil
+    }
+    return o.example(media["schema"], 0, true)
+}
+
+// example generates a value matching schema, leaving out the read only
+// properties when request is set.
+func (o *openAPI) example(v interface{}, depth int, request bool) interface{} {
+    s := o.deref(v)
+    if s == nil || depth > exampleDepth {
+        return nil
+    }
+    for _, k := range []string{"example", "default", "const"} {
+        if example, ok := s[k]; ok {
+            return example
+        }
+    }
+    if enum, ok := s["enum"].([]interface{}); ok && len(enum) > 0 {
+        return enum[0]
+    }
+    if all, ok := s["allOf"].([]interface{}); ok {
+        merged := make(map[string]interface{})
+        for _, sub := range all {
+            if fields, ok := o.example(sub, depth+1, request).(map[string]interface{}); ok {
+                for k, f := range fields {
+                    merged[k] = f
+                }
+            }
+        }
+        return merged
+    }
+    for _, k := range []string{"oneOf", "anyOf"} {
+        if list, ok := s[k].([]interface{}); ok && len(list) > 0 {
+            return o.example(list[0], depth+1, request)
+        }
+    }
+    
+    t, _ := s["type"].(string)
+    if types, ok := s["type"].([]interface{}); ok && len(types) > 0 {
+        t = fmt.Sprint(types[0])
+    }
+    if t == "" && s["properties"] != nil {
+        t = "object"
+    }
+    switch t {
+    case "object":
+        fields := make(map[string]interface{})
+        properties, _ := s["properties"].(map[string]interface{})
+        for _, k := range sortedKeys(properties) {
+            prop := o.deref(properties[k])
+            if readOnly, _ := prop["readOnly"].(bool); readOnly && request {
+                continue
+            }
+            fields[k] = o.example(prop, depth+1, request)
+        }
+        return fields
+    case "array":
+        if item := o.example(s["items"], depth+1, request); item != nil {
+            return []interface{}{item}
+        }
+        return []interface{}{}
+    case "integer", "number":
+        if min, ok := s["minimum"]; ok {
+            return min
+        }
+        return 1
+    case "boolean":
+        return true
+    case "string":
+        switch s["format"] {
+        case "date-time":
+            return "2020-01-01T00:00:00Z"
+        case "date":
+            return "2020-01-01"
+        case "uuid":
+            return "00000000-0000-0000-0000-000000000000"
+        case "email":
+            return "user@example.com"
+        case "uri":
+            return "https://example.com"
+        }
+        return "string"
+    }
+    return nil
+}
+
+// jsonSchema returns the JSON Schema of an OpenAPI schema, the components it
+// references copied as definitions so it stands alone.
+func (o *openAPI) jsonSchema(v interface{}) map[string]interface{} {
+    if v == nil {
+        return nil
+    }
+    definitions := make(map[string]interface{})
+    var pending []string
+    var convert func(v interface{}) interface{}
+    convert = func(v interface{}) interface{} {
+        switch val := v.(type) {
+        case map[string]interface{}:
+            m := make(map[string]interface{}, len(val))
+            for k, item := range val {
+                m[k] = convert(item)
+            }
+            if ref, ok := val["$ref"].(string); ok {
+                if c := openAPIComponent.FindStringSubmatch(ref); c != nil {
+                    name := strings.NewReplacer("~1", "/", "~0", "~").Replace(c[1])
+                    if _, seen := definitions[name]; !seen {
+                        definitions[name] = nil
+                        pending = append(pending, name)
+                    }
+                    m["$ref"] = "#/definitions/" + c[1]
+                }
+            }
+            // nullable is a type of OpenAPI 3.0
+            if nullable, _ := m["nullable"].(bool); nullable {
+                if t, ok := m["type"].(string); ok {
+                    m["type"] = []interface{}{t, "null"}
+                }
+                delete(m, "nullable")
+            }
+            return m
+        case []interface{}:
+            list := make([]interface{}, len(val))
+            for i, item := range val {
+                list[i] = convert(item)
+            }
+            return list
+        }
+        return v
+    }
+    root, ok := convert(v).(map[string]interface{})
+    if !ok {
+        return nil
+    }
+    for len(pending) > 0 {
+        name := pending[0]
+        pending = pending[1:]
+        definitions[name] = convert(lookup(o.spec, "components", "schemas", name))
+    }
+    if len(definitions) > 0 {
+        root["definitions"] = definitions
+    }
+    return root
+}
+
+// deref follows the $ref of v, within the spec.
+func (o *openAPI) deref(v interface{}) map[string]interface{} {
+    m, _ := v.(map[string]interface{})
+    for i := 0; i < exampleDepth && m != nil; i++ {
+        ref, ok := m["$ref"].(string)
+        if !ok {
+            break
+        }
+        m, _ = o.pointer(ref).(map[string]interface{})
+    }
+    return m
+}
+
+func (o *openAPI) pointer(ref string) interface{} {
+    if !strings.HasPrefix(ref, "#/") {
+        return nil
+    }
+    var keys []string
+    for _, token := range strings.Split(ref[2:], "/") {
+        keys = append(keys, strings.NewReplacer("~1", "/", "~0", "~").Replace(token))
+    }
+    return lookup(o.spec, keys...)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+func isJSON(mediaType string) bool {
+    return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// scalar returns v as a parameter value.
+func scalar(v interface{}) string {
+    switch val := v.(type) {
+    case nil:
+        return ""
+    case string:
+        return val
+    case float64:
+        return strconv.FormatFloat(val, 'f', -1, 64)
+    }
+    return fmt.Sprint(v)
+}
+
+// envName returns the environment variable holding the value of a header.
+func envName(header string) string {
+    return strings.Map(func(r rune) rune {
+        if r >= 'a' && r <= 'z' {
+            return r - 'a' + 'A'
+        }
+        if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+            return r
+        }
+        return '_'
+    }, header)
+}
+
+func lookup(v interface{}, keys ...string) interface{} {
+    for _, k := range keys {
+        m, ok := v.(map[string]interface{})
+        if !ok {
+            return nil
+        }
+        v = m[k]
+    }
+    return v
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+    if node.Kind != yaml.MappingNode {
+        return nil
+    }
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        if node.Content[i].Value == key {
+            return node.Content[i+1]
+        }
+    }
+    return nil
+}
+
+// normalize turns the maps with non string keys, the status codes of the
+// responses for instance, into maps with string keys.
+func normalize(v interface{}) interface{} {
+    switch val := v.(type) {
+    case map[string]interface{}:
+        for k, item := range val {
+            val[k] = normalize(item)
+        }
+    case map[interface{}]interface{}:
+        m := make(map[string]interface{}, len(val))
+        for k, item := range val {
+            m[fmt.Sprint(k)] = normalize(item)
+        }
+        return m
+    case []interface{}:
+        for i, item := range val {
+            val[i] = normalize(item)
+        }
+    }
+    return v
+}
@@ -0,0 +1,132 @@
+package importer
+
+import (
+    "encoding/json"
+    "os"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func readSpec(t *testing.T, base string) ([]Entry, map[string]interface{}) {
+    f, err := os.Open("testdata/petstore.yaml")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    entries, vars, err := OpenAPI(f, base)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return entries, vars
+}
+
+func TestOpenAPI(t *testing.T) {
+    entries, vars := readSpec(t, "")
+    tests := []struct {
+        name    string
+        method  string
+        url     string
+        status  int
+        headers map[string]string
+    }{
+        {"createPet", "POST", "https://pets.example.com/v1/pets", 201, map[string]string{
+            "Authorization": "Bearer ${env:API_TOKEN}", "Content-Type": "application/json",
+        }},
+        {"listPets", "GET", "https://pets.example.com/v1/pets?limit=5", 200, map[string]string{
+            "Authorization": "Bearer ${env:API_TOKEN}",
+        }},
+        {"deletePet", "DELETE", "https://pets.example.com/v1/pets/{{petId}}", 204, map[string]string{
+            "X-Api-Key": "${env:X_API_KEY}", "X-Request-Id": "00000000-0000-0000-0000-000000000000",
+        }},
+        {"", "GET", "https://pets.example.com/v1/owners/{{ownerId}}/pets/{{petId}}", 200, map[string]string{}},
+    }
+    if len(entries) != len(tests) {
+        t.Fatalf("%d entries, want %d", len(entries), len(tests))
+    }
+    for i, tt := range tests {
+        e := entries[i]
+        if e.Name != tt.name || e.Method != tt.method || e.URL != tt.url || e.Status != tt.status {
+            t.Errorf("entry %d = %s %s %s %d, want %s %s %s %d", i, e.Name, e.Method, e.URL, e.Status, tt.name, tt.method, tt.url, tt.status)
+        }
+        if !reflect.DeepEqual(e.Headers, tt.headers) {
+            t.Errorf("entry %d headers = %v, want %v", i, e.Headers, tt.headers)
+        }
+    }
+    
+    // The first operation declaring a path parameter gives its value
+    if want := map[string]interface{}{"petId": "7", "ownerId": "string"}; !reflect.DeepEqual(vars, want) {
+        t.Errorf("vars = %v, want %v", vars, want)
+    }
+    
+    var body map[string]interface{}
+    if err := json.Unmarshal([]byte(entries[0].Body), &body); err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := body["id"]; ok {
+        t.Errorf("read only id in the request body %s", entries[0].Body)
+    }
+    if body["name"] != "string" || body["category"] == nil {
+        t.Errorf("request body = %s", entries[0].Body)
+    }
+    if entries[3].Response != `{"id":1,"name":"rex"}` || entries[3].Schema != nil {
+        t.Errorf("example response = %s, schema %v", entries[3].Response, entries[3].Schema)
+    }
+}
+
+func TestOpenAPISchema(t *testing.T) {
+    entries, _ := readSpec(t, "")
+    raw, err := json.Marshal(entries[1].Schema)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := `{"definitions":{` +
+        `"Category":{"properties":{"name":{"type":"string"},"parent":{"$ref":"#/definitions/Category"}},"type":"object"},` +
+        `"Pet":{"properties":{"category":{"$ref":"#/definitions/Category"},"id":{"readOnly":true,"type":"integer"},"name":{"type":"string"},"tag":{"type":["string","null"]}},"required":["name"],"type":"object"}},` +
+        `"items":{"$ref":"#/definitions/Pet"},"type":"array"}`
+    if string(raw) != want {
+        t.Errorf("schema = %s\nwant %s", raw, want)
+    }
+}
+
+func TestOpenAPIServer(t *testing.T) {
+    relative := func(r string) string {
+        return strings.Replace(r, `"{scheme}://pets.example.com/v1"`, "/v1", 1)
+    }
+    spec, err := os.ReadFile("testdata/petstore.yaml")
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        name string
+        spec string
+        base string
+        url  string
+        err  string
+    }{
+        {"spec server", string(spec), "", "https://pets.example.com/v1/pets", ""},
+        {"url flag", string(spec), "http://localhost:8080/api", "http://localhost:8080/api/pets", ""},
+        {"relative with url flag", relative(string(spec)), "http://localhost:8080/v1/", "http://localhost:8080/v1/pets", ""},
+        {"relative", relative(string(spec)), "", "", `the server URL "/v1" is not absolute, give one`},
+        {"swagger", "swagger: '2.0'\n", "", "", "not an OpenAPI 3 spec"},
+        {"no paths", "openapi: 3.1.0\n", "http://a", "", "the spec has no paths"},
+        {"no success", "openapi: 3.1.0\npaths: {/a: {get: {responses: {'404': {description: x}}}}}\n", "http://a", "", "GET /a: no success response"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            entries, _, err := OpenAPI(strings.NewReader(tt.spec), tt.base)
+            if tt.err != "" {
+                if err == nil || err.Error() != tt.err {
+                    t.Fatalf("error = %v, want %s", err, tt.err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if entries[0].URL != tt.url {
+                t.Errorf("url = %s, want %s", entries[0].URL, tt.url)
+            }
+        })
+    }
+}
@@ -0,0 +1,237 @@
+package importer
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/url"
+    "regexp"
+    "strings"
+)
+
+type postmanCollection struct {
+    Info struct {
+        Name   string `json:"name"`
+        Schema string `json:"schema"`
+    } `json:"info"`
+    Item     []postmanItem `json:"item"`
+    Variable []postmanKV   `json:"variable"`
+    Auth     *postmanAuth  `json:"auth"`
+}
+
+type postmanItem struct {
+    Name     string            `json:"name"`
+    Item     []postmanItem     `json:"item"`
+    Auth     *postmanAuth      `json:"auth"`
+    Request  *postmanRequest   `json:"request"`
+    Response []postmanResponse `json:"response"`
+}
+
+type postmanRequest struct {
+    Method string       `json:"method"`
+    Header []postmanKV  `json:"header"`
+    URL    postmanURL   `json:"url"`
+    Auth   *postmanAuth `json:"auth"`
+    Body   *struct {
+        Mode       string      `json:"mode"`
+        Raw        string      `json:"raw"`
+        URLEncoded []postmanKV `json:"urlencoded"`
+    } `json:"body"`
+}
+
+type postmanResponse struct {
+    Code int    `json:"code"`
+    Body string `json:"body"`
+}
+
+type postmanKV struct {
+    Key      string      `json:"key"`
+    Value    interface{} `json:"value"`
+    Disabled bool        `json:"disabled"`
+}
+
+func (kv postmanKV) String() string {
+    if kv.Value == nil {
+        return ""
+    }
+    if s, ok := kv.Value.(string); ok {
+        return s
+    }
+    return fmt.Sprint(kv.Value)
+}
+
+type postmanAuth struct {
+    Type   string      `json:"type"`
+    Basic  []postmanKV `json:"basic"`
+    Bearer []postmanKV `json:"bearer"`
+    APIKey []postmanKV `json:"apikey"`
+}
+
+// postmanURL is either the raw URL or an object holding it with its path
+// variables.
+type postmanURL struct {
+    Raw      string      `json:"raw"`
+    Variable []postmanKV `json:"variable"`
+}
+
+func (u *postmanURL) UnmarshalJSON(raw []byte) error {
+    if len(raw) > 0 && raw[0] == '"' {
+        return json.Unmarshal(raw, &u.Raw)
+    }
+    type plain postmanURL
+    return json.Unmarshal(raw, (*plain)(u))
+}
+
+var (
+    postmanVar     = regexp.MustCompile(`{{([^{}]+)}}`)
+    postmanPathVar = regexp.MustCompile(`/:([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// Postman reads the requests of a Postman v2.1 collection, walking its
+// folders in order. The collection variables are replaced in the URLs,
+// headers and bodies, except in the paths where they stay {{name}}
+// parameters, returned as vars with their values. Path variables, :name, become
+// {{name}} parameters too. The first example response of a request gives
+// its expected status.
+func Postman(r io.Reader) (entries []Entry, vars map[string]interface{}, err error) {
+    var c postmanCollection
+    if err := json.NewDecoder(r).Decode(&c); err != nil {
+        return nil, nil, err
+    }
+    if c.Info.Schema != "" && !strings.Contains(c.Info.Schema, "v2.1") {
+        return nil, nil, fmt.Errorf("collection schema %s, only v2.1 is supported", c.Info.Schema)
+    }
+    values := make(map[string]string)
+    for _, v := range c.Variable {
+        values[v.Key] = v.String()
+    }
+    vars = make(map[string]interface{})
+    replace := func(s string) string {
+        return postmanVar.ReplaceAllStringFunc(s, func(m string) string {
+            if v, ok := values[m[2:len(m)-2]]; ok {
+                return v
+            }
+            return m
+        })
+    }
+    
+    var walk func(items []postmanItem, auth *postmanAuth) error
+    walk = func(items []postmanItem, auth *postmanAuth) error {
+        for _, item := range items {
+            itemAuth := auth
+            if item.Auth != nil {
+                itemAuth = item.Auth
+            }
+            if item.Request == nil {
+                if err := walk(item.Item, itemAuth); err != nil {
+                    return err
+                }
+                continue
+            }
+            req := item.Request
+            if req.Auth != nil {
+                itemAuth = req.Auth
+            }
+            e := Entry{Name: item.Name, Method: req.Method, Headers: make(map[string]string)}
+            
+            // Keep the path parameters and replace the variables elsewhere
+            raw := postmanPathVar.ReplaceAllString(req.URL.Raw, "/{{$1}}")
+            for _, v := range req.URL.Variable {
+                vars[v.Key] = v.String()
+            }
+            target, path := splitURL(raw)
+            for _, m := range postmanVar.FindAllStringSubmatch(path, -1) {
+                if v, ok := values[m[1]]; ok {
+                    vars[m[1]] = v
+                }
+            }
+            e.URL = replace(target) + path
+            if _, err := url.Parse(e.URL); err != nil {
+                return fmt.Errorf("%s: %s", item.Name, err.Error())
+            }
+            
+            for _, h := range req.Header {
+                if !h.Disabled {
+                    e.Headers[h.Key] = replace(h.String())
+                }
+            }
+            if err := postmanAuthHeader(itemAuth, e.Headers, replace); err != nil {
+                return fmt.Errorf("%s: %s", item.Name, err.Error())
+            }
+            if body := req.Body; body != nil {
+                switch body.Mode {
+                case "raw":
+                    e.Body = replace(body.Raw)
+                case "urlencoded":
+                    form := url.Values{}
+                    for _, kv := range body.URLEncoded {
+                        if !kv.Disabled {
+                            form.Add(kv.Key, replace(kv.String()))
+                        }
+                    }
+                    e.Body = form.Encode()
+                    e.Headers["Content-Type"] = "application/x-www-form-urlencoded"
+                case "":
+                default:
+                    return fmt.Errorf("%s: %s bodies are not supported", item.Name, body.Mode)
+                }
+            }
+            if len(item.Response) > 0 {
+                e.Status = item.Response[0].Code
+                e.Response = item.Response[0].Body
+            }
+            entries = append(entries, e)
+        }
+        return nil
+    }
+    if err := walk(c.Item, c.Auth); err != nil {
+        return nil, nil, err
+    }
+    if len(entries) == 0 {
+        return nil, nil, fmt.Errorf("the collection has no requests")
+    }
+    return entries, vars, nil
+}
+
+// splitURL splits raw into the scheme and host, and the path with the query.
+func splitURL(raw string) (string, string) {
+    start := 0
+    if i := strings.Index(raw, "://"); i >= 0 {
+        start = i + 3
+    }
+    if i := strings.IndexAny(raw[start:], "/?"); i >= 0 {
+        return raw[:start+i], raw[start+i:]
+    }
+    return raw, ""
+}
+
+func postmanAuthHeader(auth *postmanAuth, headers map[string]string, replace func(string) string) error {
+    if auth == nil {
+        return nil
+    }
+    param := func(kvs []postmanKV, key string) string {
+        for _, kv := range kvs {
+            if kv.Key == key {
+                return replace(kv.String())
+            }
+        }
+        return ""
+    }
+    switch auth.Type {
+    case "noauth", "":
+    case "basic":
+        creds := param(auth.Basic, "username") + ":" + param(auth.Basic, "password")
+        headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+    case "bearer":
+        headers["Authorization"] = "Bearer " + param(auth.Bearer, "token")
+    case "apikey":
+        if param(auth.APIKey, "in") == "query" {
+            return fmt.Errorf("api keys in the query are not supported")
+        }
+        headers[param(auth.APIKey, "key")] = param(auth.APIKey, "value")
+    default:
+        return fmt.Errorf("%s auth is not supported", auth.Type)
+    }
+    return nil
+}
@@ -0,0 +1,86 @@
+package importer
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+const postmanCollectionJSON = `{
+  "info": {"name": "shop", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+  "variable": [{"key": "base", "value": "http://shop"}, {"key": "tenant", "value": 42}, {"key": "token", "value": "t0k"}],
+  "auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}"}]},
+  "item": [
+    {"name": "Users", "item": [
+      {"name": "Get user", "request": {
+        "method": "GET",
+        "header": [{"key": "X-Tenant", "value": "{{tenant}}"}, {"key": "X-Off", "value": "1", "disabled": true}],
+        "url": {"raw": "{{base}}/tenants/{{tenant}}/users/:id", "variable": [{"key": "id", "value": "7"}]}
+      }, "response": [{"code": 200, "body": "{\"id\": 7}"}]},
+      {"name": "Admin", "auth": {"type": "basic", "basic": [{"key": "username", "value": "u"}, {"key": "password", "value": "p"}]}, "item": [
+        {"name": "Create user", "request": {
+          "method": "POST",
+          "url": "{{base}}/users?tenant={{tenant}}",
+          "body": {"mode": "raw", "raw": "{\"tenant\": {{tenant}}}"}
+        }, "response": [{"code": 201}]}
+      ]}
+    ]},
+    {"name": "Login", "request": {
+      "method": "POST",
+      "auth": {"type": "noauth"},
+      "url": "{{base}}/login",
+      "body": {"mode": "urlencoded", "urlencoded": [{"key": "user", "value": "u"}, {"key": "skip", "value": "x", "disabled": true}]}
+    }}
+  ]
+}`
+
+func TestPostman(t *testing.T) {
+    entries, vars, err := Postman(strings.NewReader(postmanCollectionJSON))
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []Entry{
+        {
+            Name: "Get user", Method: "GET", URL: "http://shop/tenants/{{tenant}}/users/{{id}}",
+            Headers: map[string]string{"X-Tenant": "42", "Authorization": "Bearer t0k"},
+            Status:  200, Response: `{"id": 7}`,
+        },
+        {
+            Name: "Create user", Method: "POST", URL: "http://shop/users?tenant={{tenant}}",
+            Headers: map[string]string{"Authorization": "Basic dTpw"},
+            Body:    `{"tenant": 42}`, Status: 201,
+        },
+        {
+            Name: "Login", Method: "POST", URL: "http://shop/login",
+            Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+            Body:    "user=u",
+        },
+    }
+    if !reflect.DeepEqual(entries, want) {
+        t.Errorf("entries = %+v\nwant %+v", entries, want)
+    }
+    wantVars := map[string]interface{}{"tenant": "42", "id": "7"}
+    if !reflect.DeepEqual(vars, wantVars) {
+        t.Errorf("vars = %v, want %v", vars, wantVars)
+    }
+}
+
+func TestPostmanErrors(t *testing.T) {
+    tests := []struct {
+        name, collection, err string
+    }{
+        {"old schema", `{"info": {"schema": "https://schema.getpostman.com/json/collection/v2.0.0/"}}`, "only v2.1 is supported"},
+        {"no requests", `{"item": [{"name": "empty", "item": []}]}`, "the collection has no requests"},
+        {"form data", `{"item": [{"name": "upload", "request": {"method": "POST", "url": "http://a", "body": {"mode": "formdata"}}}]}`, "upload: formdata bodies are not supported"},
+        {"query api key", `{"auth": {"type": "apikey", "apikey": [{"key": "in", "value": "query"}]}, "item": [{"name": "a", "request": {"url": "http://a"}}]}`, "a: api keys in the query are not supported"},
+        {"unknown auth", `{"item": [{"name": "a", "request": {"url": "http://a", "auth": {"type": "oauth2"}}}]}`, "a: oauth2 auth is not supported"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, _, err := Postman(strings.NewReader(tt.collection))
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Fatalf("error = %v, want %q", err, tt.err)
+            }
+        })
+    }
+}
@@ -0,0 +1,179 @@
+package importer
+
+import (
+    "bytes"
+    "fmt"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "net/http/httputil"
+    "net/url"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+const (
+    // maxRecordedBody bounds the response bodies kept to detect chaining.
+    maxRecordedBody = 1 << 20
+    // minThinkTime is the shortest gap between petitions taken as a pause
+    // of the user, the think times are rounded to it.
+    minThinkTime = 100 * time.Millisecond
+)
+
+// credentialHeaders are recorded as ${env:NAME} references, named as the
+// OpenAPI security headers, so the plan does not hold the session credentials.
+var credentialHeaders = map[string]bool{"Authorization": true, "Cookie": true}
+
+// Recorder is a proxy recording the petitions passing through it, safe for
+// concurrent use.
+type Recorder struct {
+    Logger  *log.Logger // Logs every petition recorded when set
+    target  *url.URL
+    proxy   *httputil.ReverseProxy
+    mu      sync.Mutex
+    entries []Entry
+    last    time.Time       // End of the last petition
+    secrets map[string]bool // Credential headers replaced by references
+}
+
+// NewRecorder returns a reverse proxy to target or, when target is empty, a
+// forward proxy sending the petitions to the URL they ask for. HTTPS through
+// the forward proxy (CONNECT) is refused, it cannot be recorded.
+func NewRecorder(target string) (*Recorder, error) {
+    rec := &Recorder{}
+    if target != "" {
+        u, err := url.Parse(target)
+        if err != nil || u.Scheme == "" || u.Host == "" {
+            return nil, fmt.Errorf("target %q must be an absolute URL", target)
+        }
+        rec.target = u
+    }
+    rec.proxy = &httputil.ReverseProxy{Director: rec.direct}
+    return rec, nil
+}
+
+// direct sends req to the target, asking for an uncompressed response so it
+// can be recorded.
+func (rec *Recorder) direct(req *http.Request) {
+    if rec.target != nil {
+        req.URL.Scheme = rec.target.Scheme
+        req.URL.Host = rec.target.Host
+        req.URL.Path = strings.TrimSuffix(rec.target.Path, "/") + req.URL.Path
+        req.URL.RawPath = ""
+        req.Host = rec.target.Host
+    }
+    req.Header.Del("Accept-Encoding")
+}
+
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if r.Method == http.MethodConnect {
+        http.Error(w, "HTTPS cannot be recorded through the proxy, record with a target instead", http.StatusNotImplemented)
+        return
+    }
+    if rec.target == nil && !r.URL.IsAbs() {
+        http.Error(w, "not a proxy request, record with a target to use it as a server", http.StatusBadRequest)
+        return
+    }
+    start := time.Now()
+    body, err := ioutil.ReadAll(r.Body)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    r.Body = ioutil.NopCloser(bytes.NewReader(body))
+    
+    c := &capture{ResponseWriter: w}
+    rec.proxy.ServeHTTP(c, r)
+    if static(c.Header().Get("Content-Type")) {
+        return
+    }
+    
+    e := Entry{
+        Method:   r.Method,
+        URL:      rec.targetURL(r),
+        Headers:  make(map[string]string),
+        Body:     string(body),
+        Status:   c.status,
+        Response: c.body.String(),
+    }
+    rec.mu.Lock()
+    defer rec.mu.Unlock()
+    for k, v := range r.Header {
+        e.Headers[k] = strings.Join(v, ", ")
+        if credentialHeaders[k] {
+            e.Headers[k] = "${env:" + envName(k) + "}"
+            if rec.secrets == nil {
+                rec.secrets = make(map[string]bool)
+            }
+            rec.secrets[k] = true
+        }
+    }
+    if gap := start.Sub(rec.last); !rec.last.IsZero() && gap >= minThinkTime {
+        e.Think = gap.Round(minThinkTime)
+    }
+    rec.last = time.Now()
+    rec.entries = append(rec.entries, e)
+    if rec.Logger != nil {
+        rec.Logger.Printf("%s %s %d", e.Method, e.URL, e.Status)
+    }
+}
+
+// targetURL returns the URL r was sent to.
+func (rec *Recorder) targetURL(r *http.Request) string {
+    if rec.target == nil {
+        return r.URL.String()
+    }
+    u := *r.URL
+    u.Scheme = rec.target.Scheme
+    u.Host = rec.target.Host
+    u.Path = strings.TrimSuffix(rec.target.Path, "/") + r.URL.Path
+    u.RawPath = ""
+    return u.String()
+}
+
+// Entries returns the petitions recorded so far, in order.
+func (rec *Recorder) Entries() []Entry {
+    rec.mu.Lock()
+    defer rec.mu.Unlock()
+    return append([]Entry{}, rec.entries...)
+}
+
+// Warnings returns a warning per credential header recorded as a reference,
+// telling the environment variable to set before running the plan.
+func (rec *Recorder) Warnings() []string {
+    rec.mu.Lock()
+    defer rec.mu.Unlock()
+    var warnings []string
+    for header := range rec.secrets {
+        warnings = append(warnings, fmt.Sprintf("%s headers recorded as ${env:%s}, set %s before running the plan", header, envName(header), envName(header)))
+    }
+    sort.Strings(warnings)
+    return warnings
+}
+
+// capture keeps the status and the start of the body of a response.
+type capture struct {
+    http.ResponseWriter
+    status int
+    body   bytes.Buffer
+}
+
+func (c *capture) WriteHeader(status int) {
+    c.status = status
+    c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *capture) Write(b []byte) (int, error) {
+    if c.status == 0 {
+        c.status = http.StatusOK
+    }
+    if room := maxRecordedBody - c.body.Len(); room > 0 {
+        if len(b) < room {
+            room = len(b)
+        }
+        c.body.Write(b[:room])
+    }
+    return c.ResponseWriter.Write(b)
+}
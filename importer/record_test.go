@@ -0,0 +1,167 @@
+package importer
+
+import (
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+)
+
+// backend answers with the path and the body it got, as JSON, and serves
+// the .css files as style sheets.
+func backend(t *testing.T) *httptest.Server {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if strings.HasSuffix(r.URL.Path, ".css") {
+            w.Header().Set("Content-Type", "text/css")
+            w.Write([]byte("body {}"))
+            return
+        }
+        body, _ := ioutil.ReadAll(r.Body)
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusCreated)
+        w.Write([]byte(`{"path": "` + r.URL.Path + `", "body": "` + string(body) + `"}`))
+    }))
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+func send(t *testing.T, client *http.Client, method, url, body string) *http.Response {
+    req, err := http.NewRequest(method, url, strings.NewReader(body))
+    if err != nil {
+        t.Fatal(err)
+    }
+    req.Header.Set("X-Client", "test")
+    resp, err := client.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    ioutil.ReadAll(resp.Body)
+    resp.Body.Close()
+    return resp
+}
+
+func TestRecorderReverse(t *testing.T) {
+    target := backend(t)
+    rec, err := NewRecorder(target.URL + "/api/")
+    if err != nil {
+        t.Fatal(err)
+    }
+    proxy := httptest.NewServer(rec)
+    defer proxy.Close()
+    
+    send(t, http.DefaultClient, "POST", proxy.URL+"/orders?x=1", "a=b")
+    send(t, http.DefaultClient, "GET", proxy.URL+"/style.css", "")
+    rec.mu.Lock()
+    rec.last = time.Now().Add(-time.Second)
+    rec.mu.Unlock()
+    send(t, http.DefaultClient, "GET", proxy.URL+"/orders/1", "")
+    
+    entries := rec.Entries()
+    if len(entries) != 2 {
+        t.Fatalf("entries = %+v", entries)
+    }
+    first, second := entries[0], entries[1]
+    if first.Method != "POST" || first.URL != target.URL+"/api/orders?x=1" || first.Body != "a=b" || first.Status != 201 {
+        t.Errorf("first = %+v", first)
+    }
+    if first.Response != `{"path": "/api/orders", "body": "a=b"}` || first.Headers["X-Client"] != "test" || first.Think != 0 {
+        t.Errorf("first = %+v", first)
+    }
+    if second.URL != target.URL+"/api/orders/1" || second.Think != time.Second {
+        t.Errorf("second = %+v", second)
+    }
+}
+
+func TestRecorderForward(t *testing.T) {
+    target := backend(t)
+    rec, err := NewRecorder("")
+    if err != nil {
+        t.Fatal(err)
+    }
+    proxy := httptest.NewServer(rec)
+    defer proxy.Close()
+    proxyURL, _ := url.Parse(proxy.URL)
+    client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+    
+    if resp := send(t, client, "PUT", target.URL+"/users/7", "x"); resp.StatusCode != 201 {
+        t.Errorf("proxied status = %d", resp.StatusCode)
+    }
+    send(t, client, "GET", target.URL+"/users/7", "")
+    if resp := send(t, http.DefaultClient, "GET", proxy.URL+"/users", ""); resp.StatusCode != http.StatusBadRequest {
+        t.Errorf("direct request status = %d, want 400", resp.StatusCode)
+    }
+    if resp := send(t, http.DefaultClient, "CONNECT", proxy.URL, ""); resp.StatusCode != http.StatusNotImplemented {
+        t.Errorf("CONNECT status = %d, want 501", resp.StatusCode)
+    }
+    
+    entries := rec.Entries()
+    if len(entries) != 2 {
+        t.Fatalf("entries = %+v", entries)
+    }
+    if e := entries[0]; e.Method != "PUT" || e.URL != target.URL+"/users/7" || e.Body != "x" || e.Response != `{"path": "/users/7", "body": "x"}` {
+        t.Errorf("entry = %+v", e)
+    }
+    if e := entries[1]; e.Think != 0 {
+        t.Errorf("think time %s between back to back petitions", e.Think)
+    }
+}
+
+func TestRecorderCredentials(t *testing.T) {
+    target := backend(t)
+    rec, err := NewRecorder(target.URL)
+    if err != nil {
+        t.Fatal(err)
+    }
+    proxy := httptest.NewServer(rec)
+    defer proxy.Close()
+    
+    req, _ := http.NewRequest("GET", proxy.URL+"/me", nil)
+    req.Header.Set("Authorization", "Bearer live-token")
+    req.Header.Set("Cookie", "session=live-session")
+    req.Header.Set("Accept", "application/json")
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    
+    entries := rec.Entries()
+    if len(entries) != 1 {
+        t.Fatalf("entries = %+v", entries)
+    }
+    want := map[string]string{"Authorization": "${env:AUTHORIZATION}", "Cookie": "${env:COOKIE}", "Accept": "application/json"}
+    for k, v := range want {
+        if got := entries[0].Headers[k]; got != v {
+            t.Errorf("%s = %q, want %q", k, got, v)
+        }
+    }
+    warnings := rec.Warnings()
+    if len(warnings) != 2 || !strings.Contains(warnings[0], "set AUTHORIZATION") || !strings.Contains(warnings[1], "set COOKIE") {
+        t.Errorf("warnings = %q", warnings)
+    }
+}
+
+func TestNewRecorder(t *testing.T) {
+    for _, target := range []string{"localhost:8080", "/api", "://"} {
+        if _, err := NewRecorder(target); err == nil {
+            t.Errorf("NewRecorder(%q) accepted a relative target", target)
+        }
+    }
+}
+
+func TestBuildThinkTime(t *testing.T) {
+    entries := []Entry{
+        {Method: "GET", URL: "http://a/first"},
+        {Method: "GET", URL: "http://a/second", Think: 1500 * time.Millisecond},
+    }
+    p, _, err := Build("p", entries, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if first, second := p.Steps[0].Tasks[0], p.Steps[0].Tasks[1]; first.Think != 0 || second.Think != 1500*time.Millisecond {
+        t.Errorf("think times = %s, %s", first.Think, second.Think)
+    }
+}
@@ -0,0 +1,712 @@
+// Package jsonschema validates JSON documents against JSON Schemas, drafts 7
+// and 2020-12. Formats are annotations only, the unevaluated and dynamic
+// keywords are not supported.
+package jsonschema
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "net/url"
+    "path"
+    "reflect"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "unicode/utf8"
+)
+
+// Error is a value of the document failing a keyword of the schema.
+type Error struct {
+    Path    string // JSON pointer of the value, empty for the document itself
+    Message string
+}
+
+func (e Error) Error() string {
+    return fmt.Sprintf("#%s: %s", e.Path, e.Message)
+}
+
+// Schema is a schema ready to validate documents, safe for concurrent use.
+type Schema struct {
+    root     interface{}
+    docs     map[string]interface{} // Documents by path, "" for an inline schema
+    patterns sync.Map
+}
+
+// Loader returns the decoded document at path.
+type Loader func(path string) (interface{}, error)
+
+// New returns the schema, given as decoded JSON or YAML, once its
+// references are checked. They can only point within the schema, and not
+// back to themselves.
+func New(schema interface{}) (*Schema, error) {
+    s := &Schema{docs: make(map[string]interface{})}
+    if err := s.add("", schema, nil); err != nil {
+        return nil, err
+    }
+    if err := s.checkCycles(); err != nil {
+        return nil, err
+    }
+    s.root = s.docs[""]
+    return s, nil
+}
+
+// Load returns the schema at path, read with load like the documents its
+// references point to, relative to the document holding them. References
+// are JSON pointers or anchors, $anchor in 2020-12 and #name $id in draft 7;
+// $id does not change the base of the references.
+func Load(path string, load Loader) (*Schema, error) {
+    doc, err := load(path)
+    if err != nil {
+        return nil, err
+    }
+    s := &Schema{docs: make(map[string]interface{})}
+    if err := s.add(path, doc, load); err != nil {
+        return nil, err
+    }
+    if err := s.checkCycles(); err != nil {
+        return nil, err
+    }
+    s.root = s.docs[path]
+    return s, nil
+}
+
+// add stores the document at path and links its references.
+func (s *Schema) add(path string, doc interface{}, load Loader) error {
+    raw, err := json.Marshal(doc)
+    if err != nil {
+        return err
+    }
+    var normalized interface{}
+    if err := json.Unmarshal(raw, &normalized); err != nil {
+        return err
+    }
+    switch normalized.(type) {
+    case map[string]interface{}, bool:
+    default:
+        return fmt.Errorf("a schema is an object or a boolean")
+    }
+    s.docs[path] = normalized
+    return s.link(path, normalized, load)
+}
+
+// link rewrites the references of the document at base as path#/pointer,
+// loading the documents they point to.
+func (s *Schema) link(base string, schema interface{}, load Loader) error {
+    switch v := schema.(type) {
+    case map[string]interface{}:
+        for k, item := range v {
+            if ref, ok := item.(string); ok && k == "$ref" {
+                canonical, err := s.canonical(base, ref, load)
+                if err != nil {
+                    return err
+                }
+                v[k] = canonical
+                continue
+            }
+            if err := s.link(base, item, load); err != nil {
+                return err
+            }
+        }
+    case []interface{}:
+        for _, item := range v {
+            if err := s.link(base, item, load); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+func (s *Schema) canonical(base, ref string, load Loader) (string, error) {
+    file, fragment := ref, ""
+    if i := strings.Index(ref, "#"); i >= 0 {
+        file, fragment = ref[:i], ref[i+1:]
+    }
+    doc := base
+    if file != "" {
+        if strings.Contains(file, "://") {
+            return "", fmt.Errorf("$ref %s: remote references are not supported", ref)
+        }
+        if load == nil {
+            return "", fmt.Errorf("$ref %s: references to files need a schema file", ref)
+        }
+        doc = path.Join(path.Dir(base), file)
+        if _, ok := s.docs[doc]; !ok {
+            loaded, err := load(doc)
+            if err != nil {
+                return "", fmt.Errorf("$ref %s: %s", ref, err.Error())
+            }
+            if err := s.add(doc, loaded, load); err != nil {
+                return "", fmt.Errorf("%s: %s", doc, err.Error())
+            }
+        }
+    }
+    fragment, err := url.PathUnescape(fragment)
+    if err != nil {
+        return "", fmt.Errorf("$ref %s: %s", ref, err.Error())
+    }
+    if fragment != "" && !strings.HasPrefix(fragment, "/") {
+        pointer, ok := anchor(s.docs[doc], fragment, "")
+        if !ok {
+            return "", fmt.Errorf("$ref %s: anchor not found", ref)
+        }
+        fragment = pointer
+    }
+    canonical := doc + "#" + fragment
+    if _, err := s.resolve(canonical); err != nil {
+        return "", fmt.Errorf("$ref %s not found", ref)
+    }
+    return canonical, nil
+}
+
+// anchor returns the JSON pointer of the schema called name in schema, at
+// pointer.
+func anchor(schema interface{}, name, pointer string) (string, bool) {
+    switch v := schema.(type) {
+    case map[string]interface{}:
+        if v["$anchor"] == name || v["$id"] == "#"+name {
+            return pointer, true
+        }
+        for k, item := range v {
+            if p, ok := anchor(item, name, pointer+"/"+escape(k)); ok {
+                return p, true
+            }
+        }
+    case []interface{}:
+        for i, item := range v {
+            if p, ok := anchor(item, name, pointer+"/"+strconv.Itoa(i)); ok {
+                return p, true
+            }
+        }
+    }
+    return "", false
+}
+
+// inPlace are the keywords whose schemas apply to the value itself rather
+// than to a value within it.
+var inPlace = []string{"allOf", "anyOf", "oneOf", "not", "if", "then", "else", "dependentSchemas", "dependencies"}
+
+// checkCycles checks no reference leads back to itself without going down
+// the document, validation would never end. Recursive schemas, a reference
+// to the root from a property for instance, are fine.
+func (s *Schema) checkCycles() error {
+    done := make(map[string]bool)
+    var walk func(node interface{}) error
+    walk = func(node interface{}) error {
+        switch v := node.(type) {
+        case map[string]interface{}:
+            if _, ok := v["$ref"].(string); ok {
+                if err := s.follow(v, make(map[string]bool), done); err != nil {
+                    return err
+                }
+            }
+            for _, item := range v {
+                if err := walk(item); err != nil {
+                    return err
+                }
+            }
+        case []interface{}:
+            for _, item := range v {
+                if err := walk(item); err != nil {
+                    return err
+                }
+            }
+        }
+        return nil
+    }
+    for _, doc := range s.docs {
+        if err := walk(doc); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// follow follows the references of schema applying to the same value, with
+// the ones followed so far in visiting and the ones known to end in done.
+func (s *Schema) follow(schema interface{}, visiting, done map[string]bool) error {
+    sc, ok := schema.(map[string]interface{})
+    if !ok {
+        return nil
+    }
+    if ref, ok := sc["$ref"].(string); ok && !done[ref] {
+        if visiting[ref] {
+            return fmt.Errorf("$ref %s is a cycle of references", ref)
+        }
+        visiting[ref] = true
+        target, err := s.resolve(ref)
+        if err != nil {
+            return err
+        }
+        if err := s.follow(target, visiting, done); err != nil {
+            return err
+        }
+        delete(visiting, ref)
+        done[ref] = true
+    }
+    for _, keyword := range inPlace {
+        switch v := sc[keyword].(type) {
+        case []interface{}:
+            for _, sub := range v {
+                if err := s.follow(sub, visiting, done); err != nil {
+                    return err
+                }
+            }
+        case map[string]interface{}:
+            if keyword == "dependentSchemas" || keyword == "dependencies" {
+                for _, sub := range v {
+                    if err := s.follow(sub, visiting, done); err != nil {
+                        return err
+                    }
+                }
+                continue
+            }
+            if err := s.follow(v, visiting, done); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// resolve returns the schema a linked reference, path#/pointer, points to.
+func (s *Schema) resolve(ref string) (interface{}, error) {
+    i := strings.Index(ref, "#")
+    node, ok := s.docs[ref[:i]]
+    if !ok {
+        return nil, fmt.Errorf("$ref %s not found", ref)
+    }
+    pointer := ref[i+1:]
+    if pointer == "" {
+        return node, nil
+    }
+    for _, token := range strings.Split(pointer[1:], "/") {
+        token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+        switch v := node.(type) {
+        case map[string]interface{}:
+            next, ok := v[token]
+            if !ok {
+                return nil, fmt.Errorf("$ref %s not found", ref)
+            }
+            node = next
+        case []interface{}:
+            i, err := strconv.Atoi(token)
+            if err != nil || i < 0 || i >= len(v) {
+                return nil, fmt.Errorf("$ref %s not found", ref)
+            }
+            node = v[i]
+        default:
+            return nil, fmt.Errorf("$ref %s not found", ref)
+        }
+    }
+    return node, nil
+}
+
+// Validate returns the errors of doc, decoded JSON, sorted by path. None
+// means doc is valid.
+func (s *Schema) Validate(doc interface{}) []Error {
+    errs := s.validate(s.root, doc, "")
+    sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+    return errs
+}
+
+// Valid tells whether doc is valid.
+func (s *Schema) Valid(doc interface{}) bool {
+    return len(s.validate(s.root, doc, "")) == 0
+}
+
+func (s *Schema) validate(schema, v interface{}, path string) []Error {
+    var errs []Error
+    fail := func(format string, args ...interface{}) {
+        errs = append(errs, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+    }
+    
+    if allowed, ok := schema.(bool); ok {
+        if !allowed {
+            fail("no value is allowed")
+        }
+        return errs
+    }
+    sc, ok := schema.(map[string]interface{})
+    if !ok {
+        return errs
+    }
+    
+    if ref, ok := sc["$ref"].(string); ok {
+        target, err := s.resolve(ref)
+        if err != nil {
+            fail("%s", err.Error())
+        } else {
+            errs = append(errs, s.validate(target, v, path)...)
+        }
+    }
+    
+    if t, ok := sc["type"]; ok && !matchesType(t, v) {
+        fail("%s is not of type %s", typeOf(v), typeNames(t))
+        return errs
+    }
+    if enum, ok := sc["enum"].([]interface{}); ok {
+        found := false
+        for _, e := range enum {
+            if equal(e, v) {
+                found = true
+                break
+            }
+        }
+        if !found {
+            fail("%s is not one of %s", short(v), short(enum))
+        }
+    }
+    if c, ok := sc["const"]; ok && !equal(c, v) {
+        fail("%s is not %s", short(v), short(c))
+    }
+    
+    switch val := v.(type) {
+    case float64:
+        errs = append(errs, s.validateNumber(sc, val, path)...)
+    case string:
+        errs = append(errs, s.validateString(sc, val, path)...)
+    case []interface{}:
+        errs = append(errs, s.validateArray(sc, val, path)...)
+    case map[string]interface{}:
+        errs = append(errs, s.validateObject(sc, val, path)...)
+    }
+    
+    if all, ok := sc["allOf"].([]interface{}); ok {
+        for _, sub := range all {
+            errs = append(errs, s.validate(sub, v, path)...)
+        }
+    }
+    if anyOf, ok := sc["anyOf"].([]interface{}); ok {
+        matched := false
+        for _, sub := range anyOf {
+            if len(s.validate(sub, v, path)) == 0 {
+                matched = true
+                break
+            }
+        }
+        if !matched {
+            fail("does not match any schema of anyOf")
+        }
+    }
+    if oneOf, ok := sc["oneOf"].([]interface{}); ok {
+        matched := 0
+        for _, sub := range oneOf {
+            if len(s.validate(sub, v, path)) == 0 {
+                matched++
+            }
+        }
+        if matched != 1 {
+            fail("matches %d schemas of oneOf instead of 1", matched)
+        }
+    }
+    if not, ok := sc["not"]; ok && len(s.validate(not, v, path)) == 0 {
+        fail("matches the schema of not")
+    }
+    if cond, ok := sc["if"]; ok {
+        if len(s.validate(cond, v, path)) == 0 {
+            if then, ok := sc["then"]; ok {
+                errs = append(errs, s.validate(then, v, path)...)
+            }
+        } else if els, ok := sc["else"]; ok {
+            errs = append(errs, s.validate(els, v, path)...)
+        }
+    }
+    return errs
+}
+
+func (s *Schema) validateNumber(sc map[string]interface{}, n float64, path string) []Error {
+    var errs []Error
+    fail := func(format string, args ...interface{}) {
+        errs = append(errs, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+    }
+    if m, ok := number(sc["multipleOf"]); ok && m > 0 {
+        if q := n / m; math.Abs(q-math.Round(q)) > 1e-9 {
+            fail("%v is not a multiple of %v", n, m)
+        }
+    }
+    if max, ok := number(sc["maximum"]); ok {
+        if exclusive, _ := sc["exclusiveMaximum"].(bool); exclusive && n >= max {
+            fail("%v is not below %v", n, max)
+        } else if n > max {
+            fail("%v is above the maximum %v", n, max)
+        }
+    }
+    if max, ok := number(sc["exclusiveMaximum"]); ok && n >= max {
+        fail("%v is not below %v", n, max)
+    }
+    if min, ok := number(sc["minimum"]); ok {
+        if exclusive, _ := sc["exclusiveMinimum"].(bool); exclusive && n <= min {
+            fail("%v is not above %v", n, min)
+        } else if n < min {
+            fail("%v is below the minimum %v", n, min)
+        }
+    }
+    if min, ok := number(sc["exclusiveMinimum"]); ok && n <= min {
+        fail("%v is not above %v", n, min)
+    }
+    return errs
+}
+
+func (s *Schema) validateString(sc map[string]interface{}, str string, path string) []Error {
+    var errs []Error
+    fail := func(format string, args ...interface{}) {
+        errs = append(errs, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+    }
+    length := utf8.RuneCountInString(str)
+    if max, ok := number(sc["maxLength"]); ok && float64(length) > max {
+        fail("%s is longer than %v", short(str), max)
+    }
+    if min, ok := number(sc["minLength"]); ok && float64(length) < min {
+        fail("%s is shorter than %v", short(str), min)
+    }
+    if pattern, ok := sc["pattern"].(string); ok {
+        re, err := s.pattern(pattern)
+        if err != nil {
+            fail("%s", err.Error())
+        } else if !re.MatchString(str) {
+            fail("%s does not match %s", short(str), pattern)
+        }
+    }
+    return errs
+}
+
+func (s *Schema) validateArray(sc map[string]interface{}, items []interface{}, path string) []Error {
+    var errs []Error
+    fail := func(format string, args ...interface{}) {
+        errs = append(errs, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+    }
+    if max, ok := number(sc["maxItems"]); ok && float64(len(items)) > max {
+        fail("has %d items, more than %v", len(items), max)
+    }
+    if min, ok := number(sc["minItems"]); ok && float64(len(items)) < min {
+        fail("has %d items, less than %v", len(items), min)
+    }
+    if unique, _ := sc["uniqueItems"].(bool); unique {
+        for i := range items {
+            for j := i + 1; j < len(items); j++ {
+                if equal(items[i], items[j]) {
+                    fail("items %d and %d are equal", i, j)
+                }
+            }
+        }
+    }
+    
+    // prefixItems in 2020-12, an array of items in draft 7
+    tuple, _ := sc["prefixItems"].([]interface{})
+    rest, hasRest := sc["items"]
+    if t, ok := rest.([]interface{}); ok {
+        tuple = t
+        rest, hasRest = sc["additionalItems"]
+    }
+    for i, item := range items {
+        itemPath := path + "/" + strconv.Itoa(i)
+        if i < len(tuple) {
+            errs = append(errs, s.validate(tuple[i], item, itemPath)...)
+        } else if hasRest {
+            errs = append(errs, s.validate(rest, item, itemPath)...)
+        }
+    }
+    
+    if contains, ok := sc["contains"]; ok {
+        count := 0
+        for _, item := range items {
+            if len(s.validate(contains, item, path)) == 0 {
+                count++
+            }
+        }
+        min := 1.0
+        if m, ok := number(sc["minContains"]); ok {
+            min = m
+        }
+        if float64(count) < min {
+            fail("contains %d matching items, less than %v", count, min)
+        }
+        if max, ok := number(sc["maxContains"]); ok && float64(count) > max {
+            fail("contains %d matching items, more than %v", count, max)
+        }
+    }
+    return errs
+}
+
+func (s *Schema) validateObject(sc map[string]interface{}, obj map[string]interface{}, path string) []Error {
+    var errs []Error
+    fail := func(format string, args ...interface{}) {
+        errs = append(errs, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+    }
+    if max, ok := number(sc["maxProperties"]); ok && float64(len(obj)) > max {
+        fail("has %d properties, more than %v", len(obj), max)
+    }
+    if min, ok := number(sc["minProperties"]); ok && float64(len(obj)) < min {
+        fail("has %d properties, less than %v", len(obj), min)
+    }
+    if required, ok := sc["required"].([]interface{}); ok {
+        for _, r := range required {
+            if name, ok := r.(string); ok {
+                if _, present := obj[name]; !present {
+                    fail("missing required property %s", name)
+                }
+            }
+        }
+    }
+    required := func(deps map[string]interface{}) {
+        for name, dep := range deps {
+            if _, present := obj[name]; !present {
+                continue
+            }
+            switch d := dep.(type) {
+            case []interface{}:
+                for _, r := range d {
+                    if other, ok := r.(string); ok {
+                        if _, present := obj[other]; !present {
+                            fail("missing property %s, required by %s", other, name)
+                        }
+                    }
+                }
+            default:
+                errs = append(errs, s.validate(d, obj, path)...)
+            }
+        }
+    }
+    for _, keyword := range []string{"dependentRequired", "dependentSchemas", "dependencies"} {
+        if deps, ok := sc[keyword].(map[string]interface{}); ok {
+            required(deps)
+        }
+    }
+    
+    properties, _ := sc["properties"].(map[string]interface{})
+    patterns, _ := sc["patternProperties"].(map[string]interface{})
+    additional, hasAdditional := sc["additionalProperties"]
+    names, hasNames := sc["propertyNames"]
+    keys := make([]string, 0, len(obj))
+    for k := range obj {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    for _, k := range keys {
+        propPath := path + "/" + escape(k)
+        if hasNames {
+            for _, e := range s.validate(names, k, path) {
+                fail("property name %s: %s", k, e.Message)
+            }
+        }
+        known := false
+        if sub, ok := properties[k]; ok {
+            known = true
+            errs = append(errs, s.validate(sub, obj[k], propPath)...)
+        }
+        for pattern, sub := range patterns {
+            re, err := s.pattern(pattern)
+            if err != nil {
+                fail("%s", err.Error())
+                continue
+            }
+            if re.MatchString(k) {
+                known = true
+                errs = append(errs, s.validate(sub, obj[k], propPath)...)
+            }
+        }
+        if !known && hasAdditional {
+            if allowed, ok := additional.(bool); ok && !allowed {
+                fail("property %s is not allowed", k)
+            } else {
+                errs = append(errs, s.validate(additional, obj[k], propPath)...)
+            }
+        }
+    }
+    return errs
+}
+
+func (s *Schema) pattern(pattern string) (*regexp.Regexp, error) {
+    if re, ok := s.patterns.Load(pattern); ok {
+        return re.(*regexp.Regexp), nil
+    }
+    re, err := regexp.Compile(pattern)
+    if err != nil {
+        return nil, fmt.Errorf("invalid pattern %s", pattern)
+    }
+    s.patterns.Store(pattern, re)
+    return re, nil
+}
+
+func matchesType(t, v interface{}) bool {
+    switch tt := t.(type) {
+    case string:
+        return isType(tt, v)
+    case []interface{}:
+        for _, name := range tt {
+            if n, ok := name.(string); ok && isType(n, v) {
+                return true
+            }
+        }
+        return false
+    }
+    return true
+}
+
+func isType(name string, v interface{}) bool {
+    switch name {
+    case "integer":
+        n, ok := v.(float64)
+        return ok && n == math.Trunc(n)
+    case "number":
+        _, ok := v.(float64)
+        return ok
+    }
+    return typeOf(v) == name
+}
+
+func typeOf(v interface{}) string {
+    switch v.(type) {
+    case nil:
+        return "null"
+    case bool:
+        return "boolean"
+    case float64:
+        return "number"
+    case string:
+        return "string"
+    case []interface{}:
+        return "array"
+    case map[string]interface{}:
+        return "object"
+    }
+    return fmt.Sprintf("%T", v)
+}
+
+func typeNames(t interface{}) string {
+    if list, ok := t.([]interface{}); ok {
+        names := make([]string, len(list))
+        for i, n := range list {
+            names[i] = fmt.Sprint(n)
+        }
+        return strings.Join(names, " or ")
+    }
+    return fmt.Sprint(t)
+}
+
+func number(v interface{}) (float64, bool) {
+    n, ok := v.(float64)
+    return n, ok
+}
+
+func equal(a, b interface{}) bool {
+    return reflect.DeepEqual(a, b)
+}
+
+// short returns v as JSON, cut when too long for a message.
+func short(v interface{}) string {
+    raw, _ := json.Marshal(v)
+    if len(raw) > 40 {
+        return string(raw[:37]) + "..."
+    }
+    return string(raw)
+}
+
+// escape escapes a property name as a JSON pointer token.
+func escape(token string) string {
+    return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
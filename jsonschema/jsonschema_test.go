@@ -0,0 +1,187 @@
+package jsonschema
+
+import (
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func decode(t *testing.T, raw string) interface{} {
+    t.Helper()
+    var v interface{}
+    if err := json.Unmarshal([]byte(raw), &v); err != nil {
+        t.Fatalf("%s: %s", raw, err)
+    }
+    return v
+}
+
+// paths returns the paths of errs, to compare them with the expected ones.
+func paths(errs []Error) []string {
+    out := []string{}
+    for _, e := range errs {
+        out = append(out, e.Path)
+    }
+    return out
+}
+
+func TestValidate(t *testing.T) {
+    tests := []struct {
+        name   string
+        schema string
+        doc    string
+        errs   []string // Paths of the errors
+    }{
+        {"true", `true`, `{"a": 1}`, []string{}},
+        {"false", `false`, `1`, []string{""}},
+        {"type", `{"type": "string"}`, `1`, []string{""}},
+        {"integer", `{"type": "integer"}`, `1.0`, []string{}},
+        {"not integer", `{"type": "integer"}`, `1.5`, []string{""}},
+        {"type list", `{"type": ["string", "null"]}`, `null`, []string{}},
+        {"enum", `{"enum": ["a", "b"]}`, `"c"`, []string{""}},
+        {"const", `{"const": {"a": [1]}}`, `{"a": [1]}`, []string{}},
+        {"minimum", `{"minimum": 1}`, `0`, []string{""}},
+        {"minimum met", `{"minimum": 1}`, `1`, []string{}},
+        {"exclusiveMinimum", `{"exclusiveMinimum": 1}`, `1`, []string{""}},
+        {"draft 4 exclusiveMinimum", `{"minimum": 1, "exclusiveMinimum": true}`, `1`, []string{""}},
+        {"maximum", `{"maximum": 10}`, `11`, []string{""}},
+        {"multipleOf", `{"multipleOf": 0.1}`, `0.3`, []string{}},
+        {"not a multiple", `{"multipleOf": 2}`, `3`, []string{""}},
+        {"maxLength in runes", `{"maxLength": 2}`, `"éé"`, []string{}},
+        {"minLength", `{"minLength": 2}`, `"a"`, []string{""}},
+        {"pattern", `{"pattern": "^[a-z]+$"}`, `"abC"`, []string{""}},
+        {"required", `{"required": ["id", "name"]}`, `{"id": 1}`, []string{""}},
+        {"properties", `{"properties": {"id": {"type": "integer"}}}`, `{"id": "1"}`, []string{"/id"}},
+        {"escaped property", `{"properties": {"a/b": {"type": "integer"}}}`, `{"a/b": "1"}`, []string{"/a~1b"}},
+        {"additionalProperties", `{"properties": {"id": {}}, "additionalProperties": false}`, `{"id": 1, "x": 2}`, []string{""}},
+        {"additionalProperties schema", `{"additionalProperties": {"type": "string"}}`, `{"x": 2}`, []string{"/x"}},
+        {"patternProperties", `{"patternProperties": {"^n_": {"type": "number"}}, "additionalProperties": false}`, `{"n_a": 1, "s": "x"}`, []string{""}},
+        {"propertyNames", `{"propertyNames": {"maxLength": 3}}`, `{"long": 1}`, []string{""}},
+        {"dependentRequired", `{"dependentRequired": {"card": ["cvv"]}}`, `{"card": "1"}`, []string{""}},
+        {"items", `{"items": {"type": "integer"}}`, `[1, "2", 3]`, []string{"/1"}},
+        {"prefixItems", `{"prefixItems": [{"type": "string"}], "items": {"type": "integer"}}`, `["a", 1, "b"]`, []string{"/2"}},
+        {"draft 7 tuple", `{"items": [{"type": "string"}], "additionalItems": false}`, `["a", 1]`, []string{"/1"}},
+        {"uniqueItems", `{"uniqueItems": true}`, `[1, 2, 1]`, []string{""}},
+        {"contains", `{"contains": {"type": "string"}}`, `[1, 2]`, []string{""}},
+        {"maxContains", `{"contains": {"type": "string"}, "maxContains": 1}`, `["a", "b"]`, []string{""}},
+        {"minItems", `{"minItems": 1}`, `[]`, []string{""}},
+        {"allOf", `{"allOf": [{"type": "integer"}, {"minimum": 2}]}`, `1`, []string{""}},
+        {"anyOf", `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, `true`, []string{""}},
+        {"oneOf twice", `{"oneOf": [{"type": "integer"}, {"minimum": 0}]}`, `1`, []string{""}},
+        {"oneOf", `{"oneOf": [{"type": "integer"}, {"type": "string"}]}`, `1`, []string{}},
+        {"not", `{"not": {"type": "null"}}`, `null`, []string{""}},
+        {"if then", `{"if": {"properties": {"kind": {"const": "a"}}}, "then": {"required": ["a"]}, "else": {"required": ["b"]}}`, `{"kind": "a"}`, []string{""}},
+        {"if else", `{"if": {"properties": {"kind": {"const": "a"}}}, "then": {"required": ["a"]}, "else": {"required": ["b"]}}`, `{"kind": "x", "b": 1}`, []string{}},
+        {"$ref", `{"$defs": {"id": {"type": "integer"}}, "properties": {"id": {"$ref": "#/$defs/id"}}}`, `{"id": "x"}`, []string{"/id"}},
+        {"draft 7 $ref", `{"definitions": {"id": {"type": "integer"}}, "items": {"$ref": "#/definitions/id"}}`, `[1, "x"]`, []string{"/1"}},
+        {"$anchor", `{"$defs": {"id": {"$anchor": "id", "type": "integer"}}, "items": {"$ref": "#id"}}`, `["x"]`, []string{"/0"}},
+        {"recursive $ref", `{"properties": {"child": {"$ref": "#"}}, "required": ["name"]}`, `{"name": "a", "child": {"name": "b", "child": {}}}`, []string{"/child/child"}},
+        {"sorted paths", `{"properties": {"b": {"type": "string"}, "a": {"type": "string"}}, "required": ["c"]}`, `{"a": 1, "b": 2}`, []string{"", "/a", "/b"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, err := New(decode(t, tt.schema))
+            if err != nil {
+                t.Fatal(err)
+            }
+            errs := s.Validate(decode(t, tt.doc))
+            if got := paths(errs); !reflect.DeepEqual(got, tt.errs) {
+                t.Fatalf("errors at %q, want %q: %v", got, tt.errs, errs)
+            }
+            if s.Valid(decode(t, tt.doc)) != (len(tt.errs) == 0) {
+                t.Errorf("Valid() disagrees with Validate()")
+            }
+        })
+    }
+}
+
+func TestNew(t *testing.T) {
+    tests := []struct {
+        name   string
+        schema string
+        err    string
+    }{
+        {"object", `{"type": "object"}`, ""},
+        {"boolean", `false`, ""},
+        {"not a schema", `[1]`, "a schema is an object or a boolean"},
+        {"missing pointer", `{"$ref": "#/$defs/nope"}`, "$ref #/$defs/nope not found"},
+        {"missing anchor", `{"$ref": "#nope"}`, "anchor not found"},
+        {"file", `{"$ref": "other.json"}`, "need a schema file"},
+        {"remote", `{"$ref": "https://example.com/s.json"}`, "remote references are not supported"},
+        {"self $ref", `{"$ref": "#"}`, "$ref # is a cycle of references"},
+        {"$ref cycle", `{"$defs": {"a": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`, "$ref #/$defs/a is a cycle of references"},
+        {"unused $ref cycle", `{"$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}}`, "is a cycle of references"},
+        {"cycle through allOf", `{"$defs": {"a": {"allOf": [{"type": "object"}, {"$ref": "#/$defs/a"}]}}, "$ref": "#/$defs/a"}`, "$ref #/$defs/a is a cycle of references"},
+        {"cycle through not", `{"$defs": {"a": {"not": {"$ref": "#/$defs/b"}}, "b": {"anyOf": [{"$ref": "#/$defs/a"}]}}}`, "is a cycle of references"},
+        {"recursive", `{"properties": {"child": {"$ref": "#"}}, "items": {"$ref": "#/properties/child"}}`, ""},
+        {"shared definition", `{"$defs": {"id": {"type": "integer"}}, "allOf": [{"$ref": "#/$defs/id"}, {"not": {"$ref": "#/$defs/id"}}]}`, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := New(decode(t, tt.schema))
+            if tt.err == "" {
+                if err != nil {
+                    t.Fatalf("unexpected error: %s", err)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Fatalf("error = %v, want it to contain %q", err, tt.err)
+            }
+        })
+    }
+}
+
+func TestLoad(t *testing.T) {
+    files := map[string]string{
+        "schemas/order.json":       `{"properties": {"id": {"$ref": "common/id.json"}, "items": {"items": {"$ref": "item.json#/$defs/item"}}}}`,
+        "schemas/common/id.json":   `{"type": "integer", "minimum": 1}`,
+        "schemas/item.json":        `{"$defs": {"item": {"required": ["sku"], "properties": {"qty": {"$ref": "#/$defs/qty"}}}, "qty": {"minimum": 1}}}`,
+        "schemas/broken.json":      `{"$ref": "missing.json"}`,
+        "schemas/bad-pointer.json": `{"$ref": "item.json#/$defs/nope"}`,
+        "schemas/loop.json":        `{"$ref": "common/loop.json"}`,
+        "schemas/common/loop.json": `{"allOf": [{"$ref": "../loop.json"}]}`,
+    }
+    var loads []string
+    load := func(path string) (interface{}, error) {
+        loads = append(loads, path)
+        raw, ok := files[path]
+        if !ok {
+            return nil, fmt.Errorf("%s not found", path)
+        }
+        var v interface{}
+        return v, json.Unmarshal([]byte(raw), &v)
+    }
+    
+    s, err := Load("schemas/order.json", load)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(loads, []string{"schemas/order.json", "schemas/common/id.json", "schemas/item.json"}) &&
+        !reflect.DeepEqual(loads, []string{"schemas/order.json", "schemas/item.json", "schemas/common/id.json"}) {
+        t.Errorf("loaded %q", loads)
+    }
+    tests := []struct {
+        doc  string
+        errs []string
+    }{
+        {`{"id": 1, "items": [{"sku": "a", "qty": 2}]}`, []string{}},
+        {`{"id": 0}`, []string{"/id"}},
+        {`{"items": [{"qty": 0}]}`, []string{"/items/0", "/items/0/qty"}},
+    }
+    for _, tt := range tests {
+        if got := paths(s.Validate(decode(t, tt.doc))); !reflect.DeepEqual(got, tt.errs) {
+            t.Errorf("%s: errors at %q, want %q", tt.doc, got, tt.errs)
+        }
+    }
+    
+    for _, path := range []string{"schemas/broken.json", "schemas/bad-pointer.json"} {
+        if _, err := Load(path, load); err == nil {
+            t.Errorf("Load(%s) did not fail", path)
+        }
+    }
+    if _, err := Load("schemas/loop.json", load); err == nil || !strings.Contains(err.Error(), "cycle of references") {
+        t.Errorf("Load(schemas/loop.json) = %v, want a cycle of references", err)
+    }
+}
@@ -0,0 +1,206 @@
+// Package logging is the leveled, structured logger of the executions. The
+// messages carry key/value fields, plan, step, user, iteration and task, and
+// are written as text or JSON lines with the secret values masked.
+package logging
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "log"
+    "os"
+    "strings"
+    "sync"
+    "time"
+    
+    "github.com/jarlex/gommander/secret"
+)
+
+// Level is the severity of a message.
+type Level int
+
+// Levels, a logger writes the messages at or above its level.
+const (
+    LevelDebug Level = iota
+    LevelInfo
+    LevelWarn
+    LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+    if l < LevelDebug || l > LevelError {
+        return fmt.Sprintf("level(%d)", int(l))
+    }
+    return levelNames[l]
+}
+
+// ParseLevel returns the level called name: debug, info, warn or error.
+func ParseLevel(name string) (Level, error) {
+    for i, n := range levelNames {
+        if strings.EqualFold(name, n) {
+            return Level(i), nil
+        }
+    }
+    return LevelInfo, fmt.Errorf("unknown log level %s, use %s", name, strings.Join(levelNames, ", "))
+}
+
+// Formats of the messages.
+const (
+    FormatText = "text"
+    FormatJSON = "json"
+)
+
+// Logger writes leveled messages with fields, safe for concurrent use. The
+// loggers derived with With share the output.
+type Logger struct {
+    mu     *sync.Mutex
+    w      io.Writer
+    level  Level
+    json   bool
+    fields []interface{}
+}
+
+// New returns a logger writing the messages at or above level to w, in
+// format text or json.
+func New(w io.Writer, level Level, format string) (*Logger, error) {
+    if format != FormatText && format != FormatJSON {
+        return nil, fmt.Errorf("unknown log format %s, use %s or %s", format, FormatText, FormatJSON)
+    }
+    return &Logger{mu: &sync.Mutex{}, w: secret.Writer(w), level: level, json: format == FormatJSON}, nil
+}
+
+// FromStd returns a text logger writing the messages at or above info to l.
+func FromStd(l *log.Logger) *Logger {
+    return &Logger{mu: &sync.Mutex{}, w: secret.Writer(l.Writer()), level: LevelInfo}
+}
+
+// Discard returns a logger dropping every message.
+func Discard() *Logger {
+    return &Logger{mu: &sync.Mutex{}, w: ioutil.Discard, level: LevelError + 1}
+}
+
+// With returns a logger adding the key/value pairs kv to every message.
+func (l *Logger) With(kv ...interface{}) *Logger {
+    c := *l
+    c.fields = append(append([]interface{}{}, l.fields...), kv...)
+    return &c
+}
+
+// Enabled reports whether the messages of level are written.
+func (l *Logger) Enabled(level Level) bool {
+    return level >= l.level
+}
+
+// Debug writes msg with the key/value pairs kv at debug level.
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.log(LevelDebug, msg, kv) }
+
+// Info writes msg with the key/value pairs kv at info level.
+func (l *Logger) Info(msg string, kv ...interface{}) { l.log(LevelInfo, msg, kv) }
+
+// Warn writes msg with the key/value pairs kv at warn level.
+func (l *Logger) Warn(msg string, kv ...interface{}) { l.log(LevelWarn, msg, kv) }
+
+// Error writes msg with the key/value pairs kv at error level.
+func (l *Logger) Error(msg string, kv ...interface{}) { l.log(LevelError, msg, kv) }
+
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+    if !l.Enabled(level) {
+        return
+    }
+    fields := append(append([]interface{}{}, l.fields...), kv...)
+    var line string
+    if l.json {
+        line = jsonLine(level, msg, fields)
+    } else {
+        line = textLine(level, msg, fields)
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    io.WriteString(l.w, line+"\n")
+}
+
+// textLine formats the message as "LEVEL msg key=value ...".
+func textLine(level Level, msg string, fields []interface{}) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "%-5s %s", strings.ToUpper(level.String()), msg)
+    for i := 0; i < len(fields); i += 2 {
+        key, value := pair(fields, i)
+        text := fmt.Sprint(value)
+        if text == "" || strings.ContainsAny(text, " =\"\n") {
+            text = fmt.Sprintf("%q", text)
+        }
+        fmt.Fprintf(&b, " %s=%s", key, text)
+    }
+    return b.String()
+}
+
+// jsonLine formats the message as a JSON object with time, level, msg and
+// the fields.
+func jsonLine(level Level, msg string, fields []interface{}) string {
+    var b strings.Builder
+    b.WriteString("{")
+    write := func(key string, value interface{}) {
+        raw, err := json.Marshal(value)
+        if err != nil {
+            raw, _ = json.Marshal(fmt.Sprint(value))
+        }
+        k, _ := json.Marshal(key)
+        if b.Len() > 1 {
+            b.WriteString(",")
+        }
+        b.Write(k)
+        b.WriteString(":")
+        b.Write(raw)
+    }
+    write("time", time.Now().Format(time.RFC3339Nano))
+    write("level", level.String())
+    write("msg", msg)
+    for i := 0; i < len(fields); i += 2 {
+        key, value := pair(fields, i)
+        switch v := value.(type) {
+        case error:
+            value = v.Error()
+        case time.Duration:
+            value = v.String()
+        }
+        write(key, value)
+    }
+    b.WriteString("}")
+    return b.String()
+}
+
+// pair returns the key and the value at i of fields, a lone value is keyed
+// !BADKEY.
+func pair(fields []interface{}, i int) (string, interface{}) {
+    if i+1 == len(fields) {
+        return "!BADKEY", fields[i]
+    }
+    key, ok := fields[i].(string)
+    if !ok {
+        key = fmt.Sprint(fields[i])
+    }
+    return key, fields[i+1]
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx whose execution messages go to l.
+func WithLogger(ctx context.Context, l *Logger) context.Context {
+    return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger of ctx, a stderr text logger at info level
+// when none was set.
+func FromContext(ctx context.Context) *Logger {
+    if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+        return l
+    }
+    return &Logger{mu: stderrMu, w: secret.Writer(os.Stderr), level: LevelInfo}
+}
+
+// stderrMu serializes the default loggers.
+var stderrMu = &sync.Mutex{}
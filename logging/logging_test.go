@@ -0,0 +1,111 @@
+package logging
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+    
+    "github.com/jarlex/gommander/secret"
+)
+
+func TestLogger(t *testing.T) {
+    secret.Register("hunter2")
+    tests := []struct {
+        name   string
+        level  Level
+        format string
+        log    func(l *Logger)
+        want   string
+    }{
+        {"levels", LevelWarn, FormatText, func(l *Logger) {
+            l.Debug("debug")
+            l.Info("info")
+            l.Warn("warn")
+            l.Error("error")
+        }, "WARN  warn\nERROR error\n"},
+        {"fields", LevelDebug, FormatText, func(l *Logger) {
+            l.With("plan", "shop").With("step", "browse").Debug("task done", "user", 1, "task", "list", "duration", 1500*time.Microsecond)
+        }, "DEBUG task done plan=shop step=browse user=1 task=list duration=1.5ms\n"},
+        {"quoting", LevelInfo, FormatText, func(l *Logger) {
+            l.Info("failed", "err", errors.New(`Status "500"`), "empty", "", "eq", "a=b", "lone")
+        }, `INFO  failed err="Status \"500\"" empty="" eq="a=b" !BADKEY=lone` + "\n"},
+        {"masking", LevelInfo, FormatText, func(l *Logger) {
+            l.Info("login", "pass", "hunter2")
+        }, "INFO  login pass=****\n"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var out strings.Builder
+            l, err := New(&out, tt.level, tt.format)
+            if err != nil {
+                t.Fatal(err)
+            }
+            tt.log(l)
+            if out.String() != tt.want {
+                t.Errorf("logged %q, want %q", out.String(), tt.want)
+            }
+        })
+    }
+}
+
+func TestLoggerJSON(t *testing.T) {
+    var out strings.Builder
+    l, err := New(&out, LevelDebug, FormatJSON)
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.With("plan", "shop").Error("task failed", "user", 2, "err", errors.New("Status not expected"), "duration", time.Second, "tags", []string{"a"})
+    var line map[string]interface{}
+    if err := json.Unmarshal([]byte(out.String()), &line); err != nil {
+        t.Fatalf("%s: %s", out.String(), err)
+    }
+    if _, err := time.Parse(time.RFC3339Nano, line["time"].(string)); err != nil {
+        t.Errorf("time: %s", err)
+    }
+    delete(line, "time")
+    want := map[string]interface{}{
+        "level": "error", "msg": "task failed", "plan": "shop", "user": 2.0,
+        "err": "Status not expected", "duration": "1s", "tags": []interface{}{"a"},
+    }
+    for k, v := range want {
+        if got, _ := json.Marshal(line[k]); string(got) != mustJSON(v) {
+            t.Errorf("%s = %s, want %s", k, got, mustJSON(v))
+        }
+    }
+    if len(line) != len(want) {
+        t.Errorf("line = %v", line)
+    }
+}
+
+func mustJSON(v interface{}) string {
+    raw, _ := json.Marshal(v)
+    return string(raw)
+}
+
+func TestParseLevel(t *testing.T) {
+    for name, want := range map[string]Level{"debug": LevelDebug, "INFO": LevelInfo, "Warn": LevelWarn, "error": LevelError} {
+        if got, err := ParseLevel(name); err != nil || got != want {
+            t.Errorf("ParseLevel(%s) = %s, %v", name, got, err)
+        }
+    }
+    if _, err := ParseLevel("trace"); err == nil || err.Error() != "unknown log level trace, use debug, info, warn, error" {
+        t.Errorf("error = %v", err)
+    }
+    if _, err := New(&strings.Builder{}, LevelInfo, "xml"); err == nil {
+        t.Error("no error for an unknown format")
+    }
+}
+
+func TestContext(t *testing.T) {
+    var out strings.Builder
+    l, _ := New(&out, LevelInfo, FormatText)
+    ctx := WithLogger(context.Background(), l.With("run", 1))
+    FromContext(ctx).Info("hello")
+    Discard().Error("dropped")
+    if out.String() != "INFO  hello run=1\n" {
+        t.Errorf("logged %q", out.String())
+    }
+}
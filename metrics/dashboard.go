@@ -0,0 +1,279 @@
+package metrics
+
+import (
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+    
+    "github.com/jarlex/gommander/secret"
+)
+
+const (
+    // window is the span of the rolling rate and percentiles.
+    window = 10 * time.Second
+    // topErrors is the number of errors shown by step.
+    topErrors = 3
+)
+
+// Dashboard is a reporter drawing the progress of the running steps: active
+// users, elapsed and remaining time, rate, rolling percentiles, error rate
+// and top errors. On a terminal the view is redrawn in place every interval,
+// otherwise a summary line by step is written every interval.
+type Dashboard struct {
+    mu       sync.Mutex
+    w        io.Writer
+    tty      bool
+    interval time.Duration
+    start    time.Time
+    steps    map[string]*progress
+    order    []string
+    lines    int // Lines of the last view drawn in place
+    stop     chan struct{}
+    done     chan struct{}
+}
+
+type progress struct {
+    start     time.Time
+    end       time.Time
+    users     int
+    active    int
+    planned   int
+    petitions int
+    failed    int
+    recent    []timed // Petitions of the last window
+    errors    map[string]int
+}
+
+type timed struct {
+    end      time.Time
+    duration time.Duration
+    failed   bool
+}
+
+// NewDashboard returns a dashboard drawing on w every interval, in place
+// when tty is set. Close stops it.
+func NewDashboard(w io.Writer, tty bool, interval time.Duration) *Dashboard {
+    d := &Dashboard{
+        w:        w,
+        tty:      tty,
+        interval: interval,
+        start:    time.Now(),
+        steps:    make(map[string]*progress),
+        stop:     make(chan struct{}),
+        done:     make(chan struct{}),
+    }
+    go d.loop()
+    return d
+}
+
+func (d *Dashboard) loop() {
+    defer close(d.done)
+    ticker := time.NewTicker(d.interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            d.draw(false)
+        case <-d.stop:
+            d.draw(true)
+            return
+        }
+    }
+}
+
+// Close draws the last view and stops the dashboard.
+func (d *Dashboard) Close() {
+    close(d.stop)
+    <-d.done
+}
+
+func (d *Dashboard) step(name string) *progress {
+    p := d.steps[name]
+    if p == nil {
+        p = &progress{start: time.Now(), errors: make(map[string]int)}
+        d.steps[name] = p
+        d.order = append(d.order, name)
+    }
+    return p
+}
+
+func (d *Dashboard) StepStarted(step string, users, petitions int) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    p := d.step(step)
+    p.start = time.Now()
+    p.users = users
+    p.active = users
+    p.planned = petitions
+}
+
+func (d *Dashboard) UserDone(step string) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    p := d.step(step)
+    p.active--
+    if p.active == 0 {
+        p.end = time.Now()
+    }
+}
+
+func (d *Dashboard) Report(s Sample) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    p := d.step(s.Step)
+    if s.Task != "" {
+        if s.Err != nil {
+            p.errors[secret.Mask(s.Err.Error())]++
+        }
+        return
+    }
+    p.petitions++
+    if s.Err != nil {
+        p.failed++
+    }
+    p.recent = append(p.recent, timed{end: s.Time.Add(s.Duration), duration: s.Duration, failed: s.Err != nil})
+}
+
+// draw writes the view, the whole one when final.
+func (d *Dashboard) draw(final bool) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    now := time.Now()
+    var lines []string
+    if d.tty || final {
+        lines = append(lines, fmt.Sprintf("elapsed %s", now.Sub(d.start).Round(time.Second)))
+    }
+    for _, name := range d.order {
+        p := d.steps[name]
+        p.prune(now)
+        if d.tty || final {
+            lines = append(lines, p.view(name, now)...)
+        } else if p.end.IsZero() || now.Sub(p.end) < d.interval {
+            lines = append(lines, p.line(name, now))
+        }
+    }
+    
+    var out strings.Builder
+    if d.tty && d.lines > 0 {
+        // Back to the first line of the previous view and clear it
+        fmt.Fprintf(&out, "\x1b[%dA\x1b[J", d.lines)
+    }
+    for _, l := range lines {
+        out.WriteString(l + "\n")
+    }
+    if d.tty {
+        d.lines = len(lines)
+    }
+    io.WriteString(d.w, secret.Mask(out.String()))
+}
+
+// prune drops the petitions older than the window.
+func (p *progress) prune(now time.Time) {
+    i := 0
+    for i < len(p.recent) && now.Sub(p.recent[i].end) > window {
+        i++
+    }
+    p.recent = p.recent[i:]
+}
+
+// stats returns the rate of the last window, the rolling percentiles of the
+// successful petitions and the error rate of the step.
+func (p *progress) stats(now time.Time) (rate float64, p50, p95, p99 time.Duration, errRate float64) {
+    span := window
+    if elapsed := now.Sub(p.start); elapsed < span {
+        span = elapsed
+    }
+    if span > 0 {
+        rate = float64(len(p.recent)) / span.Seconds()
+    }
+    var durations []time.Duration
+    for _, t := range p.recent {
+        if !t.failed {
+            durations = append(durations, t.duration)
+        }
+    }
+    sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+    if p.petitions > 0 {
+        errRate = float64(p.failed) / float64(p.petitions) * 100
+    }
+    return rate, Percentile(durations, 50), Percentile(durations, 95), Percentile(durations, 99), errRate
+}
+
+// remaining estimates the time left from the pace so far.
+func (p *progress) remaining(now time.Time) string {
+    if !p.end.IsZero() {
+        return "done"
+    }
+    if p.petitions == 0 || p.planned <= p.petitions {
+        return "?"
+    }
+    elapsed := now.Sub(p.start)
+    left := time.Duration(float64(elapsed) * float64(p.planned-p.petitions) / float64(p.petitions))
+    return "~" + left.Round(time.Second).String() + " left"
+}
+
+func (p *progress) elapsed(now time.Time) time.Duration {
+    if !p.end.IsZero() {
+        now = p.end
+    }
+    return now.Sub(p.start).Round(100 * time.Millisecond)
+}
+
+// view returns the lines of the step on a terminal.
+func (p *progress) view(name string, now time.Time) []string {
+    rate, p50, p95, p99, errRate := p.stats(now)
+    lines := []string{
+        fmt.Sprintf("step %s  users %d/%d  %s %s  %d/%d petitions", name, p.active, p.users, p.elapsed(now), p.remaining(now), p.petitions, p.planned),
+        fmt.Sprintf("  rps %.1f  p50 %s  p95 %s  p99 %s  errors %.1f%%", rate, round(p50), round(p95), round(p99), errRate),
+    }
+    for _, e := range p.top() {
+        lines = append(lines, "  "+e)
+    }
+    return lines
+}
+
+// line returns the summary line of the step.
+func (p *progress) line(name string, now time.Time) string {
+    rate, p50, p95, p99, errRate := p.stats(now)
+    line := fmt.Sprintf("P|%s|users %d/%d|%s|%s|%d/%d petitions|%.1f rps|p50 %s|p95 %s|p99 %s|%.1f%% errors",
+        name, p.active, p.users, p.elapsed(now), p.remaining(now), p.petitions, p.planned, rate, round(p50), round(p95), round(p99), errRate)
+    if top := p.top(); len(top) > 0 {
+        line += "|" + strings.Join(top, "; ")
+    }
+    return line
+}
+
+// top returns the most frequent errors of the step as "count× message".
+func (p *progress) top() []string {
+    msgs := make([]string, 0, len(p.errors))
+    for msg := range p.errors {
+        msgs = append(msgs, msg)
+    }
+    sort.Slice(msgs, func(i, j int) bool {
+        if p.errors[msgs[i]] != p.errors[msgs[j]] {
+            return p.errors[msgs[i]] > p.errors[msgs[j]]
+        }
+        return msgs[i] < msgs[j]
+    })
+    if len(msgs) > topErrors {
+        msgs = msgs[:topErrors]
+    }
+    for i, msg := range msgs {
+        msgs[i] = fmt.Sprintf("%d× %s", p.errors[msg], msg)
+    }
+    return msgs
+}
+
+// round rounds d for display.
+func round(d time.Duration) time.Duration {
+    switch {
+    case d >= time.Second:
+        return d.Round(10 * time.Millisecond)
+    case d >= time.Millisecond:
+        return d.Round(100 * time.Microsecond)
+    }
+    return d.Round(time.Microsecond)
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+    "errors"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestProgressLine(t *testing.T) {
+    t0 := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+    at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+    p := &progress{
+        start:     t0,
+        users:     2,
+        active:    1,
+        planned:   40,
+        petitions: 10,
+        failed:    2,
+        recent: []timed{
+            {end: at(5), duration: time.Millisecond},
+            {end: at(12), duration: 10 * time.Millisecond},
+            {end: at(14), duration: 30 * time.Millisecond},
+            {end: at(15), duration: 20 * time.Millisecond},
+            {end: at(16), duration: 5 * time.Millisecond, failed: true},
+        },
+        errors: map[string]int{"Status not expected": 3, "timeout": 1, "EOF": 1, "reset": 2},
+    }
+    now := at(20)
+    p.prune(now)
+    if len(p.recent) != 4 {
+        t.Fatalf("recent = %d, want 4", len(p.recent))
+    }
+    want := "P|browse|users 1/2|20s|~1m0s left|10/40 petitions|0.4 rps|p50 20ms|p95 30ms|p99 30ms|20.0% errors|3× Status not expected; 2× reset; 1× EOF"
+    if got := p.line("browse", now); got != want {
+        t.Errorf("line:\n%s\nwant:\n%s", got, want)
+    }
+    
+    if got := (&progress{planned: 5}).remaining(now); got != "?" {
+        t.Errorf("remaining without petitions = %q", got)
+    }
+    p.end = at(18)
+    if got := p.remaining(now); got != "done" {
+        t.Errorf("remaining when done = %q", got)
+    }
+    if got := p.elapsed(now); got != 18*time.Second {
+        t.Errorf("elapsed when done = %s", got)
+    }
+}
+
+func TestDashboard(t *testing.T) {
+    var b strings.Builder
+    d := NewDashboard(&b, false, time.Hour)
+    d.StepStarted("browse", 2, 4)
+    d.Report(Sample{Step: "browse", Task: "item", Err: errors.New("Status not expected")})
+    d.Report(Sample{Step: "browse", Time: time.Now(), Duration: 10 * time.Millisecond, Err: errors.New("Status not expected")})
+    d.Report(Sample{Step: "browse", Time: time.Now(), Duration: 10 * time.Millisecond})
+    d.UserDone("browse")
+    d.UserDone("browse")
+    d.Close()
+    
+    lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+    if len(lines) != 4 {
+        t.Fatalf("view:\n%s", b.String())
+    }
+    if lines[0] != "elapsed 0s" {
+        t.Errorf("first line = %q", lines[0])
+    }
+    if want := "step browse  users 0/2  0s done  2/4 petitions"; lines[1] != want {
+        t.Errorf("step line = %q, want %q", lines[1], want)
+    }
+    if !strings.HasSuffix(lines[2], "errors 50.0%") {
+        t.Errorf("stats line = %q", lines[2])
+    }
+    if want := "  1× Status not expected"; lines[3] != want {
+        t.Errorf("error line = %q, want %q", lines[3], want)
+    }
+}
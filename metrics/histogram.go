@@ -0,0 +1,113 @@
+package metrics
+
+import (
+    "math"
+    "math/bits"
+    "sort"
+    "time"
+)
+
+// subBits sets the precision of the histograms: each power of two is split
+// in 1<<subBits linear buckets, so a bucket is under 1% of its values.
+const subBits = 7
+
+// latencyHistogram counts durations in log-linear buckets, HDR style: its
+// size depends on the range of the values, not on how many were added.
+type latencyHistogram struct {
+    counts map[int]int64 // By bucket index
+    total  int64
+    min    time.Duration
+    max    time.Duration
+}
+
+func newLatencyHistogram() *latencyHistogram {
+    return &latencyHistogram{counts: make(map[int]int64)}
+}
+
+func (h *latencyHistogram) add(d time.Duration) {
+    if d < 0 {
+        d = 0
+    }
+    h.counts[bucket(d)]++
+    if h.total == 0 || d < h.min {
+        h.min = d
+    }
+    if d > h.max {
+        h.max = d
+    }
+    h.total++
+}
+
+// merge adds the values of o to h.
+func (h *latencyHistogram) merge(o *latencyHistogram) {
+    if o.total == 0 {
+        return
+    }
+    for i, n := range o.counts {
+        h.counts[i] += n
+    }
+    if h.total == 0 || o.min < h.min {
+        h.min = o.min
+    }
+    if o.max > h.max {
+        h.max = o.max
+    }
+    h.total += o.total
+}
+
+// percentile returns the p-th percentile by nearest rank, as Percentile,
+// within the precision of the buckets. The lowest and highest ranks are the
+// exact min and max.
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+    if h.total == 0 {
+        return 0
+    }
+    rank := int64(math.Ceil(p * float64(h.total) / 100))
+    if rank <= 1 {
+        return h.min
+    }
+    if rank >= h.total {
+        return h.max
+    }
+    indexes := make([]int, 0, len(h.counts))
+    for i := range h.counts {
+        indexes = append(indexes, i)
+    }
+    sort.Ints(indexes)
+    var seen int64
+    for _, i := range indexes {
+        seen += h.counts[i]
+        if seen >= rank {
+            d := middle(i)
+            if d < h.min {
+                d = h.min
+            }
+            if d > h.max {
+                d = h.max
+            }
+            return d
+        }
+    }
+    return h.max
+}
+
+// bucket returns the index of the bucket of d: the values below 1<<subBits
+// get a bucket each, then every power of two gets 1<<subBits buckets.
+func bucket(d time.Duration) int {
+    v := uint64(d)
+    if v < 1<<subBits {
+        return int(v)
+    }
+    shift := bits.Len64(v) - subBits - 1
+    return (shift+1)<<subBits + int(v>>uint(shift)) - 1<<subBits
+}
+
+// middle returns the middle value of the bucket i.
+func middle(i int) time.Duration {
+    if i < 1<<subBits {
+        return time.Duration(i)
+    }
+    shift := uint(i>>subBits - 1)
+    low := uint64(i&(1<<subBits-1)|1<<subBits) << shift
+    return time.Duration(low + (uint64(1)<<shift)/2)
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+    "math/rand"
+    "sort"
+    "testing"
+    "time"
+)
+
+func TestBucketMiddle(t *testing.T) {
+    values := []time.Duration{0, 1, 127, 128, 255, 256, 1000, time.Microsecond, 37 * time.Millisecond, time.Second, time.Hour}
+    for _, v := range values {
+        m := middle(bucket(v))
+        if diff := float64(m-v) / float64(v+1); diff > 0.01 || diff < -0.01 {
+            t.Errorf("middle(bucket(%d)) = %d, more than 1%% away", v, m)
+        }
+        if bucket(v) > bucket(v+1) {
+            t.Errorf("bucket(%d) > bucket(%d)", v, v+1)
+        }
+    }
+}
+
+func TestHistogramPercentile(t *testing.T) {
+    r := rand.New(rand.NewSource(1))
+    tests := []struct {
+        name string
+        gen  func() time.Duration
+        n    int
+    }{
+        {"uniform", func() time.Duration { return time.Duration(r.Int63n(int64(100 * time.Millisecond))) }, 10000},
+        {"exponential", func() time.Duration { return time.Duration(r.ExpFloat64() * float64(20*time.Millisecond)) }, 10000},
+        {"few", func() time.Duration { return time.Duration(r.Int63n(int64(time.Second))) }, 7},
+        {"constant", func() time.Duration { return 42 * time.Millisecond }, 100},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            h := newLatencyHistogram()
+            sorted := make([]time.Duration, tt.n)
+            for i := range sorted {
+                sorted[i] = tt.gen()
+                h.add(sorted[i])
+            }
+            sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+            for _, p := range []float64{0, 50, 90, 95, 99, 100} {
+                want := Percentile(sorted, p)
+                got := h.percentile(p)
+                if diff := float64(got-want) / float64(want); diff > 0.01 || diff < -0.01 {
+                    t.Errorf("p%v = %s, want %s within 1%%", p, got, want)
+                }
+            }
+            if h.min != sorted[0] || h.max != sorted[len(sorted)-1] {
+                t.Errorf("min, max = %s, %s, want %s, %s", h.min, h.max, sorted[0], sorted[len(sorted)-1])
+            }
+        })
+    }
+}
+
+func TestHistogramMerge(t *testing.T) {
+    a, b, all := newLatencyHistogram(), newLatencyHistogram(), newLatencyHistogram()
+    for i := 1; i <= 100; i++ {
+        d := time.Duration(i) * time.Millisecond
+        if i%2 == 0 {
+            a.add(d)
+        } else {
+            b.add(d)
+        }
+        all.add(d)
+    }
+    a.merge(b)
+    if a.total != all.total || a.min != all.min || a.max != all.max {
+        t.Fatalf("merged total, min, max = %d, %s, %s, want %d, %s, %s", a.total, a.min, a.max, all.total, all.min, all.max)
+    }
+    for _, p := range []float64{50, 95, 99} {
+        if a.percentile(p) != all.percentile(p) {
+            t.Errorf("merged p%v = %s, want %s", p, a.percentile(p), all.percentile(p))
+        }
+    }
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+    "fmt"
+    "io"
+    "sync"
+    
+    "github.com/jarlex/gommander/secret"
+)
+
+// LineReporter writes one pipe separated line per sample and per step, the
+// classic gommander output, with the secret values masked.
+type LineReporter struct {
+    mu     sync.Mutex
+    w      io.Writer
+    done   map[string]int // Petitions done by step
+    failed map[string]int // Petitions failed by step
+}
+
+func NewLineReporter(w io.Writer) *LineReporter {
+    return &LineReporter{w: w, done: make(map[string]int), failed: make(map[string]int)}
+}
+
+func (l *LineReporter) Report(s Sample) {
+    var line string
+    switch {
+    case s.Task == "":
+        l.mu.Lock()
+        l.done[s.Step]++
+        if s.Err != nil {
+            l.failed[s.Step]++
+        }
+        l.mu.Unlock()
+        line = fmt.Sprintf("T|%s|U%d|%d ns|%d", s.Step, s.User, s.Duration.Nanoseconds(), s.Petition)
+    case s.Err != nil:
+        line = fmt.Sprintf("%s|U%d|FAIL|%s|%d|%s", s.Step, s.User, s.Task, s.Petition, s.Err.Error())
+    default:
+        line = fmt.Sprintf("%s|U%d|%d ns|T%s|%d", s.Step, s.User, s.Duration.Nanoseconds(), s.Task, s.Petition)
+    }
+    l.println(line)
+}
+
+func (l *LineReporter) ReportStep(r StepReport) {
+    l.mu.Lock()
+    done, failed := l.done[r.Name], l.failed[r.Name]
+    l.mu.Unlock()
+    l.println(fmt.Sprintf("S|%s|%d/%d petitions|%d failed", r.Name, done, r.Petitions, failed))
+    l.println(fmt.Sprintf("Timeline|%s|%s|%d ns|%d ns|%d ns", r.Name, r.Status, r.Start, r.End, r.End-r.Start))
+}
+
+func (l *LineReporter) println(line string) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    fmt.Fprintln(l.w, secret.Mask(line))
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import (
+    "errors"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestLineReporter(t *testing.T) {
+    var b strings.Builder
+    l := NewLineReporter(&b)
+    l.Report(Sample{Step: "browse", User: 1, Petition: 2, Task: "list", Duration: 1500})
+    l.Report(Sample{Step: "browse", User: 1, Petition: 2, Task: "item", Err: errors.New("Status not expected")})
+    l.Report(Sample{Step: "browse", User: 1, Petition: 2, Duration: 2000})
+    l.ReportStep(StepReport{Name: "browse", Status: "OK", Start: time.Second, End: 3 * time.Second, Petitions: 1})
+    want := strings.Join([]string{
+        "browse|U1|1500 ns|Tlist|2",
+        "browse|U1|FAIL|item|2|Status not expected",
+        "T|browse|U1|2000 ns|2",
+        "S|browse|1/1 petitions|0 failed",
+        "Timeline|browse|OK|1000000000 ns|3000000000 ns|2000000000 ns",
+        "",
+    }, "\n")
+    if b.String() != want {
+        t.Errorf("lines:\n%s\nwant:\n%s", b.String(), want)
+    }
+}
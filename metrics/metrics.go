@@ -0,0 +1,121 @@
+package metrics
+
+import (
+    "context"
+    "time"
+)
+
+// Sample is the outcome of a task run by a user, or of a whole petition when
+// Task is empty.
+type Sample struct {
+    Time     time.Time     // When the task or petition started
+    Step     string        // Step Name
+    User     int           // User number inside the step
+    Petition int           // Petition number of the user
+    Task     string        // Task name, empty for the whole petition
+    Duration time.Duration // Time spent waiting for the responses
+    Err      error         // Failure, nil when the task succeeded
+}
+
+// StepReport tells how a step of a plan ran, times are relative to the plan
+// start.
+type StepReport struct {
+    Name      string
+    Status    string // OK, FAIL, SKIPPED or STOPPED
+    Start     time.Duration
+    End       time.Duration
+    Petitions int // Petitions planned
+}
+
+// Reporter receives the samples of a run. Implementations must be safe for
+// concurrent use, every user reports from its own goroutine.
+type Reporter interface {
+    Report(s Sample)
+}
+
+// StepReporter is implemented by the reporters that also want to know how
+// each step ran.
+type StepReporter interface {
+    ReportStep(r StepReport)
+}
+
+// ProgressReporter is implemented by the reporters following the users of
+// the steps while they run.
+type ProgressReporter interface {
+    StepStarted(step string, users, petitions int)
+    UserDone(step string)
+}
+
+type reporterKey struct{}
+
+// WithReporter returns a copy of ctx whose samples go to r.
+func WithReporter(ctx context.Context, r Reporter) context.Context {
+    return context.WithValue(ctx, reporterKey{}, r)
+}
+
+// Report sends s to the reporter of ctx, if any.
+func Report(ctx context.Context, s Sample) {
+    if r, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+        r.Report(s)
+    }
+}
+
+// ReportStep sends r to the reporter of ctx, if it is a StepReporter.
+func ReportStep(ctx context.Context, r StepReport) {
+    if sr, ok := ctx.Value(reporterKey{}).(StepReporter); ok {
+        sr.ReportStep(r)
+    }
+}
+
+// StepStarted tells the reporter of ctx, if it is a ProgressReporter, that
+// the users of step start, to run petitions petitions.
+func StepStarted(ctx context.Context, step string, users, petitions int) {
+    if pr, ok := ctx.Value(reporterKey{}).(ProgressReporter); ok {
+        pr.StepStarted(step, users, petitions)
+    }
+}
+
+// UserDone tells the reporter of ctx, if it is a ProgressReporter, that a
+// user of step finished.
+func UserDone(ctx context.Context, step string) {
+    if pr, ok := ctx.Value(reporterKey{}).(ProgressReporter); ok {
+        pr.UserDone(step)
+    }
+}
+
+// Multi returns a reporter that forwards to every reporter given.
+func Multi(reporters ...Reporter) Reporter {
+    return multi(reporters)
+}
+
+type multi []Reporter
+
+func (m multi) Report(s Sample) {
+    for _, r := range m {
+        r.Report(s)
+    }
+}
+
+func (m multi) ReportStep(sr StepReport) {
+    for _, r := range m {
+        if r, ok := r.(StepReporter); ok {
+            r.ReportStep(sr)
+        }
+    }
+}
+
+func (m multi) StepStarted(step string, users, petitions int) {
+    for _, r := range m {
+        if r, ok := r.(ProgressReporter); ok {
+            r.StepStarted(step, users, petitions)
+        }
+    }
+}
+
+func (m multi) UserDone(step string) {
+    for _, r := range m {
+        if r, ok := r.(ProgressReporter); ok {
+            r.UserDone(step)
+        }
+    }
+}
@@ -0,0 +1,203 @@
+package metrics
+
+import (
+    "fmt"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// Buckets are the upper bounds, in seconds, of the latency histograms.
+var Buckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Prometheus is a reporter exposing the samples in the Prometheus text
+// format as they arrive: requests, petitions and errors counters, latency
+// histograms and active users, labeled by plan, step and task.
+type Prometheus struct {
+    mu        sync.Mutex
+    plan      string
+    requests  map[[3]string]float64 // step, task, status
+    errors    map[[3]string]float64 // step, task, reason
+    petitions map[[2]string]float64 // step, status
+    latencies map[[2]string]*histogram
+    users     map[string]float64
+}
+
+type histogram struct {
+    counts []float64 // By bucket, not cumulative
+    sum    float64
+    count  float64
+}
+
+// NewPrometheus returns the reporter of the run of plan.
+func NewPrometheus(plan string) *Prometheus {
+    return &Prometheus{
+        plan:      plan,
+        requests:  make(map[[3]string]float64),
+        errors:    make(map[[3]string]float64),
+        petitions: make(map[[2]string]float64),
+        latencies: make(map[[2]string]*histogram),
+        users:     make(map[string]float64),
+    }
+}
+
+func (p *Prometheus) Report(s Sample) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    status := "ok"
+    if s.Err != nil {
+        status = "fail"
+    }
+    if s.Task == "" {
+        p.petitions[[2]string{s.Step, status}]++
+        return
+    }
+    p.requests[[3]string{s.Step, s.Task, status}]++
+    if s.Err != nil {
+        p.errors[[3]string{s.Step, s.Task, reason(s.Err)}]++
+        return
+    }
+    h := p.latencies[[2]string{s.Step, s.Task}]
+    if h == nil {
+        h = &histogram{counts: make([]float64, len(Buckets))}
+        p.latencies[[2]string{s.Step, s.Task}] = h
+    }
+    v := s.Duration.Seconds()
+    for i, le := range Buckets {
+        if v <= le {
+            h.counts[i]++
+            break
+        }
+    }
+    h.sum += v
+    h.count++
+}
+
+func (p *Prometheus) StepStarted(step string, users, petitions int) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.users[step] = float64(users)
+}
+
+func (p *Prometheus) UserDone(step string) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.users[step]--
+}
+
+// reason returns the kind of err, one of a fixed set so neither the values
+// in the messages nor new messages multiply the series: timeout, connection,
+// status, extract, assertion or other.
+func reason(err error) string {
+    msg := err.Error()
+    switch {
+    case strings.Contains(msg, "Architecture Error"):
+        lower := strings.ToLower(msg)
+        if strings.Contains(lower, "timeout") || strings.Contains(lower, "deadline exceeded") {
+            return "timeout"
+        }
+        return "connection"
+    case strings.Contains(msg, "Status not expected"):
+        return "status"
+    case strings.Contains(msg, "mandatory and not present"), strings.Contains(msg, "Necesary param not present"):
+        return "extract"
+    case strings.Contains(msg, "response body is not JSON"), strings.Contains(msg, "response does not match the schema"):
+        return "assertion"
+    }
+    return "other"
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    var b strings.Builder
+    p.mu.Lock()
+    p.write(&b)
+    p.mu.Unlock()
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+    w.Write([]byte(b.String()))
+}
+
+func (p *Prometheus) write(b *strings.Builder) {
+    header(b, "gommander_requests_total", "counter", "Tasks run, by outcome.")
+    for _, k := range sortedKeys3(p.requests) {
+        sample(b, "gommander_requests_total", p.labels("step", k[0], "task", k[1], "status", k[2]), p.requests[k])
+    }
+    header(b, "gommander_errors_total", "counter", "Failed tasks, by reason.")
+    for _, k := range sortedKeys3(p.errors) {
+        sample(b, "gommander_errors_total", p.labels("step", k[0], "task", k[1], "reason", k[2]), p.errors[k])
+    }
+    header(b, "gommander_petitions_total", "counter", "Petitions, the tasks of a user in a row, by outcome.")
+    keys2 := make([][2]string, 0, len(p.petitions))
+    for k := range p.petitions {
+        keys2 = append(keys2, k)
+    }
+    sort.Slice(keys2, func(i, j int) bool { return keys2[i][0]+"\x00"+keys2[i][1] < keys2[j][0]+"\x00"+keys2[j][1] })
+    for _, k := range keys2 {
+        sample(b, "gommander_petitions_total", p.labels("step", k[0], "status", k[1]), p.petitions[k])
+    }
+    
+    header(b, "gommander_request_duration_seconds", "histogram", "Latency of the successful tasks.")
+    keys2 = keys2[:0]
+    for k := range p.latencies {
+        keys2 = append(keys2, k)
+    }
+    sort.Slice(keys2, func(i, j int) bool { return keys2[i][0]+"\x00"+keys2[i][1] < keys2[j][0]+"\x00"+keys2[j][1] })
+    for _, k := range keys2 {
+        h := p.latencies[k]
+        var cumulative float64
+        for i, le := range Buckets {
+            cumulative += h.counts[i]
+            sample(b, "gommander_request_duration_seconds_bucket", p.labels("step", k[0], "task", k[1], "le", strconv.FormatFloat(le, 'g', -1, 64)), cumulative)
+        }
+        sample(b, "gommander_request_duration_seconds_bucket", p.labels("step", k[0], "task", k[1], "le", "+Inf"), h.count)
+        sample(b, "gommander_request_duration_seconds_sum", p.labels("step", k[0], "task", k[1]), h.sum)
+        sample(b, "gommander_request_duration_seconds_count", p.labels("step", k[0], "task", k[1]), h.count)
+    }
+    
+    header(b, "gommander_active_users", "gauge", "Users of the step running.")
+    steps := make([]string, 0, len(p.users))
+    for step := range p.users {
+        steps = append(steps, step)
+    }
+    sort.Strings(steps)
+    for _, step := range steps {
+        sample(b, "gommander_active_users", p.labels("step", step), p.users[step])
+    }
+}
+
+// labels formats the plan label and the name/value pairs kv.
+func (p *Prometheus) labels(kv ...string) string {
+    var b strings.Builder
+    b.WriteString(`{plan="` + escape(p.plan) + `"`)
+    for i := 0; i+1 < len(kv); i += 2 {
+        b.WriteString("," + kv[i] + `="` + escape(kv[i+1]) + `"`)
+    }
+    b.WriteString("}")
+    return b.String()
+}
+
+func header(b *strings.Builder, name, kind, help string) {
+    fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sample(b *strings.Builder, name, labels string, v float64) {
+    fmt.Fprintf(b, "%s%s %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+// escape escapes a label value.
+func escape(s string) string {
+    return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func sortedKeys3(m map[[3]string]float64) [][3]string {
+    keys := make([][3]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Slice(keys, func(i, j int) bool {
+        return strings.Join(keys[i][:], "\x00") < strings.Join(keys[j][:], "\x00")
+    })
+    return keys
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+    "errors"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestPrometheus(t *testing.T) {
+    p := NewPrometheus(`shop "eu"`)
+    p.StepStarted("browse", 3, 30)
+    p.Report(Sample{Step: "browse", Task: "list", Duration: 3 * time.Millisecond})
+    p.Report(Sample{Step: "browse", Task: "list", Duration: 40 * time.Millisecond})
+    p.Report(Sample{Step: "browse", Task: "list", Duration: 20 * time.Second})
+    p.Report(Sample{Step: "browse", Task: "item", Err: errors.New("Status not expected")})
+    p.Report(Sample{Step: "browse", Duration: 50 * time.Millisecond})
+    p.Report(Sample{Step: "browse", Err: errors.New("item: Status not expected")})
+    p.UserDone("browse")
+    
+    srv := httptest.NewServer(p)
+    defer srv.Close()
+    resp, err := http.Get(srv.URL + "/metrics")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer resp.Body.Close()
+    if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+        t.Errorf("Content-Type = %s", ct)
+    }
+    b, _ := io.ReadAll(resp.Body)
+    body := string(b)
+    
+    plan := `plan="shop \"eu\""`
+    for _, want := range []string{
+        "# TYPE gommander_requests_total counter\n",
+        `gommander_requests_total{` + plan + `,step="browse",task="item",status="fail"} 1` + "\n",
+        `gommander_requests_total{` + plan + `,step="browse",task="list",status="ok"} 3` + "\n",
+        `gommander_errors_total{` + plan + `,step="browse",task="item",reason="status"} 1` + "\n",
+        `gommander_petitions_total{` + plan + `,step="browse",status="fail"} 1` + "\n",
+        `gommander_petitions_total{` + plan + `,step="browse",status="ok"} 1` + "\n",
+        "# TYPE gommander_request_duration_seconds histogram\n",
+        `gommander_request_duration_seconds_bucket{` + plan + `,step="browse",task="list",le="0.005"} 1` + "\n",
+        `gommander_request_duration_seconds_bucket{` + plan + `,step="browse",task="list",le="0.05"} 2` + "\n",
+        `gommander_request_duration_seconds_bucket{` + plan + `,step="browse",task="list",le="10"} 2` + "\n",
+        `gommander_request_duration_seconds_bucket{` + plan + `,step="browse",task="list",le="+Inf"} 3` + "\n",
+        `gommander_request_duration_seconds_sum{` + plan + `,step="browse",task="list"} 20.043` + "\n",
+        `gommander_request_duration_seconds_count{` + plan + `,step="browse",task="list"} 3` + "\n",
+        `gommander_active_users{` + plan + `,step="browse"} 2` + "\n",
+    } {
+        if !strings.Contains(body, want) {
+            t.Errorf("metrics miss %s", want)
+        }
+    }
+    if t.Failed() {
+        t.Log(body)
+    }
+}
+
+func TestReason(t *testing.T) {
+    tests := []struct {
+        err  string
+        want string
+    }{
+        {"Architecture Error: Get \"http://x/items\": context deadline exceeded (Client.Timeout exceeded while awaiting headers)", "timeout"},
+        {"Architecture Error: read tcp 127.0.0.1:1->127.0.0.1:2: i/o timeout", "timeout"},
+        {"Architecture Error: dial tcp 127.0.0.1:1: connect: connection refused", "connection"},
+        {"Status not expected", "status"},
+        {"token mandatory and not present in nextData", "extract"},
+        {"id mandatory and not present in previousData", "extract"},
+        {"Necesary param not present", "extract"},
+        {"response body is not JSON", "assertion"},
+        {"response does not match the schema: /id: expected integer", "assertion"},
+        {"login: Status not expected", "status"},
+        {"something new: with a value", "other"},
+    }
+    for _, tt := range tests {
+        if got := reason(errors.New(tt.err)); got != tt.want {
+            t.Errorf("reason(%q) = %s, want %s", tt.err, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,366 @@
+package metrics
+
+import (
+    "math"
+    "math/rand"
+    "sync"
+    "time"
+    
+    "github.com/jarlex/gommander/secret"
+)
+
+// Result gathers the metrics of a run.
+type Result struct {
+    Plan        string            `json:"plan"`
+    Type        string            `json:"type,omitempty"`
+    URL         string            `json:"url,omitempty"` // Secrets masked
+    Start       time.Time         `json:"start"`
+    Duration    time.Duration     `json:"duration"`
+    Interrupted bool              `json:"interrupted"`
+    Steps       []*StepResult     `json:"steps"`
+    Thresholds  []ThresholdResult `json:"thresholds,omitempty"` // Outcomes of the plan thresholds, see CheckThresholds
+}
+
+// StepResult gathers the metrics of a step.
+type StepResult struct {
+    Name      string        `json:"name"`
+    Status    string        `json:"status"`
+    Start     time.Duration `json:"start"`
+    End       time.Duration `json:"end"`
+    Users     int           `json:"users,omitempty"`     // Concurrent users configured
+    Planned   int           `json:"planned,omitempty"`   // Petitions configured
+    DependsOn []string      `json:"dependsOn,omitempty"` // Steps it waited for
+    Petitions int           `json:"petitions"`
+    Failed    int           `json:"failed"`
+    Tasks     []*TaskResult `json:"tasks"`
+}
+
+// TaskResult gathers the metrics of a task inside a step. Latencies only
+// account for the successful samples.
+type TaskResult struct {
+    Name       string          `json:"name"`
+    Count      int             `json:"count"`
+    Failures   int             `json:"failures"`
+    Min        time.Duration   `json:"min"`
+    Max        time.Duration   `json:"max"`
+    Mean       time.Duration   `json:"mean"`
+    P50        time.Duration   `json:"p50"`
+    P90        time.Duration   `json:"p90"`
+    P95        time.Duration   `json:"p95"`
+    P99        time.Duration   `json:"p99"`
+    Throughput float64         `json:"throughput"`          // Samples per second over the step duration
+    Errors     map[string]int  `json:"errors"`              // Failures count by error message, secrets masked
+    Series     []Point         `json:"series,omitempty"`    // Samples over time
+    Latencies  []time.Duration `json:"latencies,omitempty"` // Uniform random sample of up to 1000 successful latencies, for comparisons
+}
+
+// Point gathers the samples of a task started within an interval of the
+// time series.
+type Point struct {
+    Time     time.Time     `json:"time"` // Start of the interval
+    Count    int           `json:"count"`
+    Failures int           `json:"failures"`
+    Rate     float64       `json:"rate"` // Samples per second
+    Mean     time.Duration `json:"mean"` // Latencies of the successful samples
+    P95      time.Duration `json:"p95"`
+}
+
+const (
+    // maxPoints bounds the points of a time series, the interval grows with
+    // the duration of the step.
+    maxPoints = 300
+    // maxLatencies bounds the latencies sampled by task for the result.
+    maxLatencies = 1000
+)
+
+// Failed reports whether any step, petition or threshold of the run failed.
+func (r *Result) Failed() bool {
+    for _, th := range r.Thresholds {
+        if !th.Passed {
+            return true
+        }
+    }
+    for _, s := range r.Steps {
+        if s.Failed > 0 || s.Status == "FAIL" || s.Status == "SKIPPED" {
+            return true
+        }
+    }
+    return false
+}
+
+// Step returns the result of the step called name, nil if it did not run.
+func (r *Result) Step(name string) *StepResult {
+    for _, s := range r.Steps {
+        if s.Name == name {
+            return s
+        }
+    }
+    return nil
+}
+
+// Task returns the result of the task called name, nil if it did not run.
+func (s *StepResult) Task(name string) *TaskResult {
+    for _, t := range s.Tasks {
+        if t.Name == name {
+            return t
+        }
+    }
+    return nil
+}
+
+// Collector is a Reporter aggregating the samples into a Result as they
+// arrive, in a memory bounded by the range of the latencies rather than by
+// the number of samples, so it can follow long runs.
+type Collector struct {
+    mu    sync.Mutex
+    steps map[string]*stepSamples
+    order []string
+    rand  *rand.Rand // Picks the latencies kept
+}
+
+type stepSamples struct {
+    report    *StepReport
+    petitions int
+    failed    int
+    first     time.Time
+    last      time.Time
+    tasks     map[string]*taskSamples
+    order     []string
+}
+
+type taskSamples struct {
+    latencies *latencyHistogram
+    total     time.Duration
+    failures  int
+    errors    map[string]int
+    series    timeSeries
+    reservoir []time.Duration // Uniform sample of the latencies
+}
+
+// timeSeries counts the samples by interval of their start. The interval
+// doubles whenever the points would exceed maxPoints.
+type timeSeries struct {
+    start    time.Time
+    interval time.Duration
+    points   []interval
+}
+
+type interval struct {
+    failures  int
+    total     time.Duration
+    latencies *latencyHistogram
+}
+
+func NewCollector() *Collector {
+    return &Collector{
+        steps: make(map[string]*stepSamples),
+        rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+    }
+}
+
+func (c *Collector) step(name string) *stepSamples {
+    ss := c.steps[name]
+    if ss == nil {
+        ss = &stepSamples{tasks: make(map[string]*taskSamples)}
+        c.steps[name] = ss
+        c.order = append(c.order, name)
+    }
+    return ss
+}
+
+func (c *Collector) Report(s Sample) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    ss := c.step(s.Step)
+    if ss.first.IsZero() || s.Time.Before(ss.first) {
+        ss.first = s.Time
+    }
+    if end := s.Time.Add(s.Duration); end.After(ss.last) {
+        ss.last = end
+    }
+    
+    if s.Task == "" {
+        ss.petitions++
+        if s.Err != nil {
+            ss.failed++
+        }
+        return
+    }
+    
+    ts := ss.tasks[s.Task]
+    if ts == nil {
+        ts = &taskSamples{latencies: newLatencyHistogram(), errors: make(map[string]int)}
+        ss.tasks[s.Task] = ts
+        ss.order = append(ss.order, s.Task)
+    }
+    p := ts.series.at(s.Time)
+    if s.Err != nil {
+        ts.failures++
+        ts.errors[secret.Mask(s.Err.Error())]++
+        p.failures++
+        return
+    }
+    ts.latencies.add(s.Duration)
+    ts.total += s.Duration
+    p.latencies.add(s.Duration)
+    p.total += s.Duration
+    // Reservoir sampling, every latency has the same chance to be kept
+    if n := ts.latencies.total; n <= maxLatencies {
+        ts.reservoir = append(ts.reservoir, s.Duration)
+    } else if i := c.rand.Int63n(n); i < maxLatencies {
+        ts.reservoir[i] = s.Duration
+    }
+}
+
+func (c *Collector) ReportStep(r StepReport) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.step(r.Name).report = &r
+}
+
+// Result returns the metrics gathered so far.
+func (c *Collector) Result() *Result {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    res := &Result{}
+    for _, name := range c.order {
+        ss := c.steps[name]
+        sr := &StepResult{Name: name, Petitions: ss.petitions, Failed: ss.failed}
+        if ss.report != nil {
+            sr.Status = ss.report.Status
+            sr.Start = ss.report.Start
+            sr.End = ss.report.End
+        }
+        elapsed := ss.last.Sub(ss.first)
+        for _, task := range ss.order {
+            tr := ss.tasks[task].result(task, elapsed)
+            tr.Series = ss.tasks[task].series.result(ss.last)
+            sr.Tasks = append(sr.Tasks, tr)
+        }
+        res.Steps = append(res.Steps, sr)
+    }
+    return res
+}
+
+func (ts *taskSamples) result(name string, elapsed time.Duration) *TaskResult {
+    successes := int(ts.latencies.total)
+    tr := &TaskResult{
+        Name:     name,
+        Count:    successes + ts.failures,
+        Failures: ts.failures,
+        Errors:   make(map[string]int, len(ts.errors)),
+    }
+    for e, n := range ts.errors {
+        tr.Errors[e] = n
+    }
+    if elapsed > 0 {
+        tr.Throughput = float64(tr.Count) / elapsed.Seconds()
+    }
+    if successes == 0 {
+        return tr
+    }
+    
+    tr.Latencies = append([]time.Duration(nil), ts.reservoir...)
+    tr.Min = ts.latencies.min
+    tr.Max = ts.latencies.max
+    tr.Mean = ts.total / time.Duration(successes)
+    tr.P50 = ts.latencies.percentile(50)
+    tr.P90 = ts.latencies.percentile(90)
+    tr.P95 = ts.latencies.percentile(95)
+    tr.P99 = ts.latencies.percentile(99)
+    return tr
+}
+
+// at returns the interval of a sample started at t, the first one for the
+// samples started before the first reported.
+func (s *timeSeries) at(t time.Time) *interval {
+    if s.interval == 0 {
+        s.start = t
+        s.interval = time.Second
+    }
+    i := s.index(t)
+    for i >= maxPoints {
+        s.widen()
+        i = s.index(t)
+    }
+    for len(s.points) <= i {
+        s.points = append(s.points, interval{latencies: newLatencyHistogram()})
+    }
+    return &s.points[i]
+}
+
+func (s *timeSeries) index(t time.Time) int {
+    if t.Before(s.start) {
+        return 0
+    }
+    return int(t.Sub(s.start) / s.interval)
+}
+
+// widen doubles the interval, merging the points by pairs.
+func (s *timeSeries) widen() {
+    merged := make([]interval, (len(s.points)+1)/2)
+    for i, p := range s.points {
+        m := &merged[i/2]
+        if m.latencies == nil {
+            m.latencies = newLatencyHistogram()
+        }
+        m.failures += p.failures
+        m.total += p.total
+        m.latencies.merge(p.latencies)
+    }
+    s.points = merged
+    s.interval *= 2
+}
+
+// result returns the points of the series up to end, in intervals of a
+// second or more.
+func (s *timeSeries) result(end time.Time) []Point {
+    if s.interval == 0 {
+        return nil
+    }
+    n := len(s.points)
+    if last := s.index(end.Add(-1)) + 1; last > n && last <= maxPoints {
+        // Intervals without samples up to the end of the step
+        n = last
+    }
+    points := make([]Point, n)
+    for i := range points {
+        p := &points[i]
+        p.Time = s.start.Add(time.Duration(i) * s.interval)
+        span := s.interval
+        if rest := end.Sub(p.Time); rest < span && rest > 0 {
+            // The last interval is cut by the end of the step
+            span = rest
+        }
+        if i >= len(s.points) {
+            continue
+        }
+        in := s.points[i]
+        successes := int(in.latencies.total)
+        p.Count = successes + in.failures
+        p.Failures = in.failures
+        p.Rate = float64(p.Count) / span.Seconds()
+        if successes == 0 {
+            continue
+        }
+        p.Mean = in.total / time.Duration(successes)
+        p.P95 = in.latencies.percentile(95)
+    }
+    return points
+}
+
+// Percentile returns the p-th percentile of sorted durations, nearest rank:
+// the smallest value with at least p percent of the values at or below it.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+    if len(sorted) == 0 {
+        return 0
+    }
+    rank := int(math.Ceil(p*float64(len(sorted))/100)) - 1
+    if rank < 0 {
+        rank = 0
+    }
+    if rank >= len(sorted) {
+        rank = len(sorted) - 1
+    }
+    return sorted[rank]
+}
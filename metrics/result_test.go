@@ -0,0 +1,242 @@
+package metrics
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+    "time"
+)
+
+func TestCollector(t *testing.T) {
+    start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+    ms := time.Millisecond
+    c := NewCollector()
+    samples := []Sample{
+        {Time: start, Step: "browse", Task: "list", Duration: 10 * ms},
+        {Time: start.Add(100 * ms), Step: "browse", Task: "list", Duration: 30 * ms},
+        {Time: start.Add(200 * ms), Step: "browse", Task: "list", Duration: 20 * ms},
+        {Time: start.Add(300 * ms), Step: "browse", Task: "list", Duration: 5 * ms, Err: errors.New("Status not expected")},
+        {Time: start.Add(300 * ms), Step: "browse", Task: "item", Duration: 40 * ms},
+        {Time: start, Step: "browse", Duration: 50 * ms},
+        {Time: start.Add(300 * ms), Step: "browse", Duration: 700 * ms, Err: errors.New("list: Status not expected")},
+        {Time: start, Step: "buy", Task: "order", Duration: 15 * ms, Err: errors.New("Status not expected")},
+        {Time: start, Step: "buy", Duration: 15 * ms, Err: errors.New("order: Status not expected")},
+    }
+    for _, s := range samples {
+        c.Report(s)
+    }
+    c.ReportStep(StepReport{Name: "browse", Status: "OK", Start: 0, End: time.Second})
+    c.ReportStep(StepReport{Name: "idle", Status: "SKIPPED"})
+    res := c.Result()
+    
+    var names []string
+    for _, s := range res.Steps {
+        names = append(names, s.Name)
+    }
+    if want := []string{"browse", "buy", "idle"}; !reflect.DeepEqual(names, want) {
+        t.Fatalf("steps %q, want %q", names, want)
+    }
+    browse := res.Step("browse")
+    if browse.Status != "OK" || browse.End != time.Second || browse.Petitions != 2 || browse.Failed != 1 {
+        t.Errorf("browse = %+v", browse)
+    }
+    if len(browse.Tasks) != 2 || browse.Tasks[0].Name != "list" || browse.Tasks[1].Name != "item" {
+        t.Fatalf("browse tasks out of order: %+v", browse.Tasks)
+    }
+    
+    list := browse.Task("list")
+    want := &TaskResult{
+        Name:     "list",
+        Count:    4,
+        Failures: 1,
+        Min:      10 * ms,
+        Max:      30 * ms,
+        Mean:     20 * ms,
+        P50:      20 * ms,
+        P90:      30 * ms,
+        P95:      30 * ms,
+        P99:      30 * ms,
+        // 4 samples between the start of the first and the end of the last
+        // petition, a second
+        Throughput: 4,
+        Errors:     map[string]int{"Status not expected": 1},
+        Series:     []Point{{Time: start, Count: 4, Failures: 1, Rate: 4, Mean: 20 * ms, P95: 30 * ms}},
+        Latencies:  []time.Duration{10 * ms, 30 * ms, 20 * ms},
+    }
+    // The histogram percentiles are within 1% of the exact ones, min and
+    // max are exact
+    near := func(got, want time.Duration) bool {
+        d := got - want
+        if d < 0 {
+            d = -d
+        }
+        return d <= want/100
+    }
+    if !near(list.P50, 20*ms) || !near(list.P90, 30*ms) || !near(list.P95, 30*ms) || !near(list.P99, 30*ms) {
+        t.Errorf("percentiles %s %s %s %s, want 20ms 30ms 30ms 30ms", list.P50, list.P90, list.P95, list.P99)
+    }
+    list.P50, list.P90, list.P95, list.P99 = 20*ms, 30*ms, 30*ms, 30*ms
+    if !reflect.DeepEqual(list, want) {
+        t.Errorf("list = %+v\nwant %+v", list, want)
+    }
+    if order := res.Step("buy").Task("order"); order.Count != 1 || order.Failures != 1 || order.Min != 0 {
+        t.Errorf("order = %+v", order)
+    }
+    if res.Step("nope") != nil || browse.Task("nope") != nil {
+        t.Error("found a step or task that did not run")
+    }
+}
+
+func TestResultFailed(t *testing.T) {
+    tests := []struct {
+        name  string
+        steps []*StepResult
+        want  bool
+    }{
+        {"none", nil, false},
+        {"ok", []*StepResult{{Status: "OK", Petitions: 3}}, false},
+        {"failed petition", []*StepResult{{Status: "OK", Petitions: 3, Failed: 1}}, true},
+        {"failed step", []*StepResult{{Status: "OK"}, {Status: "FAIL"}}, true},
+        {"skipped step", []*StepResult{{Status: "SKIPPED"}}, true},
+        {"stopped step", []*StepResult{{Status: "STOPPED"}}, false},
+    }
+    for _, tt := range tests {
+        if got := (&Result{Steps: tt.steps}).Failed(); got != tt.want {
+            t.Errorf("%s: Failed() = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestSeries(t *testing.T) {
+    start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+    ms := time.Millisecond
+    c := NewCollector()
+    for _, s := range []Sample{
+        {Time: start, Step: "browse", Task: "list", Duration: 10 * ms},
+        {Time: start.Add(1200 * ms), Step: "browse", Task: "list", Duration: 20 * ms},
+        {Time: start.Add(1500 * ms), Step: "browse", Task: "list", Err: errors.New("Status not expected")},
+        {Time: start.Add(2200 * ms), Step: "browse", Task: "list", Duration: 40 * ms},
+        {Time: start.Add(2000 * ms), Step: "browse", Duration: 500 * ms},
+    } {
+        c.Report(s)
+    }
+    got := c.Result().Step("browse").Task("list").Series
+    // 2.5s of step, the last interval is cut to half a second
+    want := []Point{
+        {Time: start, Count: 1, Rate: 1, Mean: 10 * ms, P95: 10 * ms},
+        {Time: start.Add(time.Second), Count: 2, Failures: 1, Rate: 2, Mean: 20 * ms, P95: 20 * ms},
+        {Time: start.Add(2 * time.Second), Count: 1, Rate: 2, Mean: 40 * ms, P95: 40 * ms},
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("series = %+v\nwant %+v", got, want)
+    }
+    
+    // Long steps get wider intervals, up to maxPoints
+    c = NewCollector()
+    c.Report(Sample{Time: start, Step: "soak", Task: "list", Duration: ms})
+    c.Report(Sample{Time: start.Add(900 * time.Second), Step: "soak", Task: "list", Duration: ms})
+    got = c.Result().Step("soak").Task("list").Series
+    if len(got) > maxPoints || got[1].Time.Sub(got[0].Time) != 4*time.Second {
+        t.Errorf("soak series of %d points, interval %s", len(got), got[1].Time.Sub(got[0].Time))
+    }
+    if last := got[len(got)-1]; last.Count != 1 {
+        t.Errorf("last point = %+v", last)
+    }
+}
+
+func TestPercentile(t *testing.T) {
+    seq := func(n int) []time.Duration {
+        out := make([]time.Duration, n)
+        for i := range out {
+            out[i] = time.Duration(i + 1)
+        }
+        return out
+    }
+    tests := []struct {
+        name   string
+        sorted []time.Duration
+        p      float64
+        want   time.Duration
+    }{
+        {"empty", nil, 50, 0},
+        {"single", seq(1), 99, 1},
+        {"median of 3", seq(3), 50, 2},
+        {"median of 4", seq(4), 50, 2},
+        {"p95 of 10", seq(10), 95, 10},
+        {"p93 of 10", seq(10), 93, 10},
+        {"p90 of 10", seq(10), 90, 9},
+        {"p95 of 20", seq(20), 95, 19},
+        {"p91 of 20", seq(20), 91, 19},
+        {"p99 of 100", seq(100), 99, 99},
+        {"p0", seq(10), 0, 1},
+        {"p100", seq(10), 100, 10},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := Percentile(tt.sorted, tt.p); got != tt.want {
+                t.Errorf("Percentile(%d values, %v) = %d, want %d", len(tt.sorted), tt.p, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestCollectorResult(t *testing.T) {
+    start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+    tests := []struct {
+        name       string
+        samples    int
+        every      time.Duration
+        wantPoints int
+        wantEvery  time.Duration
+    }{
+        {"short", 10, 100 * time.Millisecond, 1, time.Second},
+        {"minutes", 600, time.Second, 300, 2 * time.Second},
+        {"soak", 20000, 500 * time.Millisecond, 157, 64 * time.Second},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            c := NewCollector()
+            for i := 0; i < tt.samples; i++ {
+                s := Sample{Time: start.Add(time.Duration(i) * tt.every), Step: "s", Task: "t", Duration: time.Duration(i%100+1) * time.Millisecond}
+                if i%10 == 0 {
+                    s.Err = errors.New("Status not expected")
+                }
+                c.Report(s)
+            }
+            res := c.Result()
+            task := res.Step("s").Task("t")
+            if task.Count != tt.samples || task.Failures != tt.samples/10 {
+                t.Errorf("count, failures = %d, %d, want %d, %d", task.Count, task.Failures, tt.samples, tt.samples/10)
+            }
+            if task.Errors["Status not expected"] != tt.samples/10 {
+                t.Errorf("errors = %v", task.Errors)
+            }
+            if len(task.Latencies) > maxLatencies || len(task.Latencies) != min(tt.samples-tt.samples/10, maxLatencies) {
+                t.Errorf("%d latencies kept", len(task.Latencies))
+            }
+            if len(task.Series) != tt.wantPoints {
+                t.Fatalf("%d points, want %d", len(task.Series), tt.wantPoints)
+            }
+            if len(task.Series) > 1 {
+                if every := task.Series[1].Time.Sub(task.Series[0].Time); every != tt.wantEvery {
+                    t.Errorf("points every %s, want %s", every, tt.wantEvery)
+                }
+            }
+            var count, failures int
+            for _, p := range task.Series {
+                count += p.Count
+                failures += p.Failures
+            }
+            if count != task.Count || failures != task.Failures {
+                t.Errorf("series count, failures = %d, %d, want %d, %d", count, failures, task.Count, task.Failures)
+            }
+        })
+    }
+}
+
+func min(a, b int) int {
+    if a < b {
+        return a
+    }
+    return b
+}
@@ -0,0 +1,357 @@
+package metrics
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "net/url"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "time"
+    
+    "github.com/jarlex/gommander/secret"
+)
+
+// Formats of a Stream.
+const (
+    FormatStatsD = "statsd"
+    FormatInflux = "influx"
+)
+
+const (
+    // maxDatagram bounds the UDP packets, below the usual MTU.
+    maxDatagram = 1400
+    // maxBatch is the size sent without waiting for the interval.
+    maxBatch = 64 << 10
+)
+
+// StreamOptions configures a Stream.
+type StreamOptions struct {
+    Format   string            // statsd (DogStatsD tags) or influx (line protocol)
+    Target   string            // udp://host:port, http(s)://url or a file path
+    Tags     map[string]string // Added to every metric
+    Interval time.Duration     // Flush and aggregate interval, 10s when 0
+    Buffer   int               // Samples waiting to be sent, 10000 when 0
+}
+
+// Stream is a reporter pushing every sample, and per task aggregates every
+// interval, to a StatsD or InfluxDB target while the run goes on. Samples
+// are buffered: when the buffer is full they are dropped and counted instead
+// of blocking the users.
+type Stream struct {
+    opts    StreamOptions
+    influx  bool
+    tags    [][2]string
+    send    func([]byte) error
+    closer  io.Closer
+    samples chan Sample
+    dropped uint64
+    done    chan error
+}
+
+type aggregate struct {
+    count    int
+    failures int
+    sum      time.Duration
+    max      time.Duration
+}
+
+// ParseStream parses "format=target", statsd or influx, into the options
+// of a stream.
+func ParseStream(s string) (StreamOptions, error) {
+    eq := strings.Index(s, "=")
+    if eq <= 0 || eq == len(s)-1 {
+        return StreamOptions{}, fmt.Errorf("--stream %s must be format=target", s)
+    }
+    opts := StreamOptions{Format: s[:eq], Target: s[eq+1:]}
+    if opts.Format != FormatStatsD && opts.Format != FormatInflux {
+        return StreamOptions{}, fmt.Errorf("unknown stream format %s, use %s or %s", opts.Format, FormatStatsD, FormatInflux)
+    }
+    return opts, nil
+}
+
+// NewStream returns a stream sending to the target of opts. Close flushes
+// it.
+func NewStream(opts StreamOptions) (*Stream, error) {
+    if opts.Format != FormatStatsD && opts.Format != FormatInflux {
+        return nil, fmt.Errorf("unknown stream format %s, use %s or %s", opts.Format, FormatStatsD, FormatInflux)
+    }
+    if opts.Interval <= 0 {
+        opts.Interval = 10 * time.Second
+    }
+    if opts.Buffer <= 0 {
+        opts.Buffer = 10000
+    }
+    s := &Stream{
+        opts:    opts,
+        influx:  opts.Format == FormatInflux,
+        samples: make(chan Sample, opts.Buffer),
+        done:    make(chan error, 1),
+    }
+    for k, v := range opts.Tags {
+        s.tags = append(s.tags, [2]string{k, v})
+    }
+    sort.Slice(s.tags, func(i, j int) bool { return s.tags[i][0] < s.tags[j][0] })
+    if err := s.open(); err != nil {
+        return nil, err
+    }
+    go s.loop()
+    return s, nil
+}
+
+// open sets how the batches are sent to the target.
+func (s *Stream) open() error {
+    u, err := url.Parse(s.opts.Target)
+    if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+        // A path, a windows drive letter is no scheme
+        u = &url.URL{Scheme: "file", Path: s.opts.Target}
+    }
+    switch u.Scheme {
+    case "udp":
+        conn, err := net.Dial("udp", u.Host)
+        if err != nil {
+            return err
+        }
+        s.closer = conn
+        s.send = func(batch []byte) error { return sendDatagrams(conn, batch) }
+    case "http", "https":
+        if !s.influx {
+            return fmt.Errorf("statsd cannot be sent over %s, use udp:// or a file", u.Scheme)
+        }
+        client := &http.Client{Timeout: 10 * time.Second}
+        s.send = func(batch []byte) error {
+            resp, err := client.Post(s.opts.Target, "text/plain; charset=utf-8", bytes.NewReader(batch))
+            if err != nil {
+                return err
+            }
+            defer resp.Body.Close()
+            io.Copy(ioutil.Discard, resp.Body)
+            if resp.StatusCode >= 300 {
+                return fmt.Errorf("%s answered %s", u.Host, resp.Status)
+            }
+            return nil
+        }
+    case "file":
+        path := u.Path
+        if u.Host != "" {
+            path = u.Host + path
+        }
+        f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+        if err != nil {
+            return err
+        }
+        s.closer = f
+        s.send = func(batch []byte) error {
+            _, err := f.Write(batch)
+            return err
+        }
+    default:
+        return fmt.Errorf("unknown stream target %s, use udp://, http(s):// or a file", s.opts.Target)
+    }
+    return nil
+}
+
+// sendDatagrams sends the lines of batch in packets of maxDatagram bytes at
+// most.
+func sendDatagrams(conn net.Conn, batch []byte) error {
+    for len(batch) > 0 {
+        n := len(batch)
+        if n > maxDatagram {
+            n = bytes.LastIndexByte(batch[:maxDatagram], '\n') + 1
+            if n <= 0 {
+                n = bytes.IndexByte(batch, '\n') + 1
+            }
+        }
+        if _, err := conn.Write(batch[:n]); err != nil {
+            return err
+        }
+        batch = batch[n:]
+    }
+    return nil
+}
+
+// Report queues sample, dropping it when the buffer is full.
+func (s *Stream) Report(sample Sample) {
+    select {
+    case s.samples <- sample:
+    default:
+        atomic.AddUint64(&s.dropped, 1)
+    }
+}
+
+// Dropped returns the number of samples dropped so far.
+func (s *Stream) Dropped() uint64 {
+    return atomic.LoadUint64(&s.dropped)
+}
+
+// Close sends the samples left and the last aggregates, and returns the
+// first error met while sending.
+func (s *Stream) Close() error {
+    close(s.samples)
+    err := <-s.done
+    if s.closer != nil {
+        if cerr := s.closer.Close(); err == nil {
+            err = cerr
+        }
+    }
+    return err
+}
+
+func (s *Stream) loop() {
+    var firstErr error
+    var batch bytes.Buffer
+    aggregates := make(map[[2]string]*aggregate)
+    ticker := time.NewTicker(s.opts.Interval)
+    defer ticker.Stop()
+    last := time.Now()
+    flush := func() {
+        now := time.Now()
+        span := now.Sub(last)
+        last = now
+        keys := make([][2]string, 0, len(aggregates))
+        for k := range aggregates {
+            keys = append(keys, k)
+        }
+        sort.Slice(keys, func(i, j int) bool { return keys[i][0]+"\x00"+keys[i][1] < keys[j][0]+"\x00"+keys[j][1] })
+        for _, k := range keys {
+            s.writeAggregate(&batch, k[0], k[1], aggregates[k], now, span)
+        }
+        s.writeDropped(&batch, now)
+        aggregates = make(map[[2]string]*aggregate)
+        if err := s.send(batch.Bytes()); err != nil && firstErr == nil {
+            firstErr = err
+        }
+        batch.Reset()
+    }
+    for {
+        select {
+        case sample, ok := <-s.samples:
+            if !ok {
+                flush()
+                s.done <- firstErr
+                return
+            }
+            if sample.Task == "" {
+                continue
+            }
+            s.writeSample(&batch, sample)
+            if batch.Len() >= maxBatch {
+                if err := s.send(batch.Bytes()); err != nil && firstErr == nil {
+                    firstErr = err
+                }
+                batch.Reset()
+            }
+            key := [2]string{sample.Step, sample.Task}
+            a := aggregates[key]
+            if a == nil {
+                a = &aggregate{}
+                aggregates[key] = a
+            }
+            a.count++
+            if sample.Err != nil {
+                a.failures++
+                continue
+            }
+            a.sum += sample.Duration
+            if sample.Duration > a.max {
+                a.max = sample.Duration
+            }
+        case <-ticker.C:
+            flush()
+        }
+    }
+}
+
+func (s *Stream) writeSample(b *bytes.Buffer, sample Sample) {
+    status := "ok"
+    if sample.Err != nil {
+        status = "fail"
+    }
+    tags := [][2]string{{"step", sample.Step}, {"task", sample.Task}, {"status", status}}
+    ms := float64(sample.Duration) / float64(time.Millisecond)
+    if s.influx {
+        fmt.Fprintf(b, "gommander_request%s duration_ms=%s,user=%di", s.influxTags(tags), fmtFloat(ms), sample.User)
+        if sample.Err != nil {
+            fmt.Fprintf(b, ",error=%s", influxString(secret.Mask(sample.Err.Error())))
+        }
+        fmt.Fprintf(b, " %d\n", sample.Time.UnixNano())
+        return
+    }
+    fmt.Fprintf(b, "gommander.requests:1|c%s\n", s.statsdTags(tags))
+    if sample.Err != nil {
+        fmt.Fprintf(b, "gommander.errors:1|c%s\n", s.statsdTags(append(tags, [2]string{"reason", reason(sample.Err)})))
+        return
+    }
+    fmt.Fprintf(b, "gommander.request.duration:%s|ms%s\n", fmtFloat(ms), s.statsdTags(tags[:2]))
+}
+
+func (s *Stream) writeAggregate(b *bytes.Buffer, step, task string, a *aggregate, now time.Time, span time.Duration) {
+    tags := [][2]string{{"step", step}, {"task", task}}
+    var mean float64
+    if ok := a.count - a.failures; ok > 0 {
+        mean = float64(a.sum) / float64(ok) / float64(time.Millisecond)
+    }
+    max := float64(a.max) / float64(time.Millisecond)
+    rate := float64(a.count) / span.Seconds()
+    if s.influx {
+        fmt.Fprintf(b, "gommander_aggregate%s count=%di,failures=%di,rate=%s,mean_ms=%s,max_ms=%s %d\n",
+            s.influxTags(tags), a.count, a.failures, fmtFloat(rate), fmtFloat(mean), fmtFloat(max), now.UnixNano())
+        return
+    }
+    t := s.statsdTags(tags)
+    fmt.Fprintf(b, "gommander.aggregate.count:%d|g%s\n", a.count, t)
+    fmt.Fprintf(b, "gommander.aggregate.failures:%d|g%s\n", a.failures, t)
+    fmt.Fprintf(b, "gommander.aggregate.rate:%s|g%s\n", fmtFloat(rate), t)
+    fmt.Fprintf(b, "gommander.aggregate.mean:%s|g%s\n", fmtFloat(mean), t)
+    fmt.Fprintf(b, "gommander.aggregate.max:%s|g%s\n", fmtFloat(max), t)
+}
+
+func (s *Stream) writeDropped(b *bytes.Buffer, now time.Time) {
+    if s.influx {
+        fmt.Fprintf(b, "gommander_stream%s dropped=%di %d\n", s.influxTags(nil), s.Dropped(), now.UnixNano())
+        return
+    }
+    fmt.Fprintf(b, "gommander.stream.dropped:%d|g%s\n", s.Dropped(), s.statsdTags(nil))
+}
+
+// statsdTags formats the configured tags and tags as DogStatsD tags.
+func (s *Stream) statsdTags(tags [][2]string) string {
+    all := append(append([][2]string{}, s.tags...), tags...)
+    if len(all) == 0 {
+        return ""
+    }
+    parts := make([]string, len(all))
+    r := strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+    for i, t := range all {
+        parts[i] = r.Replace(t[0]) + ":" + r.Replace(t[1])
+    }
+    return "|#" + strings.Join(parts, ",")
+}
+
+// influxTags formats the configured tags and tags as line protocol tags.
+func (s *Stream) influxTags(tags [][2]string) string {
+    var b strings.Builder
+    r := strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`, "\n", `\ `)
+    for _, t := range append(append([][2]string{}, s.tags...), tags...) {
+        if t[1] == "" {
+            continue
+        }
+        b.WriteString("," + r.Replace(t[0]) + "=" + r.Replace(t[1]))
+    }
+    return b.String()
+}
+
+// influxString quotes a line protocol string field.
+func influxString(v string) string {
+    return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(v) + `"`
+}
+
+func fmtFloat(v float64) string {
+    return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,221 @@
+package metrics
+
+import (
+    "bytes"
+    "errors"
+    "net"
+    "strings"
+    "testing"
+    "time"
+)
+
+// listen returns a UDP listener and a function reading the datagrams it
+// received until none arrive for a while.
+func listen(t *testing.T) (net.PacketConn, func() []string) {
+    pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { pc.Close() })
+    return pc, func() []string {
+        var packets []string
+        buf := make([]byte, 64<<10)
+        for {
+            pc.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+            n, _, err := pc.ReadFrom(buf)
+            if err != nil {
+                return packets
+            }
+            packets = append(packets, string(buf[:n]))
+        }
+    }
+}
+
+func TestStreamUDP(t *testing.T) {
+    at := time.Unix(1700000000, 0)
+    samples := []Sample{
+        {Time: at, Step: "browse", User: 1, Task: "list", Duration: 12500 * time.Microsecond},
+        {Time: at, Step: "browse", User: 2, Task: "item", Duration: time.Millisecond, Err: errors.New("Status not expected")},
+        {Time: at, Step: "browse", User: 1, Duration: 20 * time.Millisecond},
+    }
+    tests := []struct {
+        format string
+        lines  []string // Exact lines
+        starts []string // Lines with a timestamp or a rate, by prefix
+    }{
+        {
+            format: FormatStatsD,
+            lines: []string{
+                "gommander.requests:1|c|#plan:shop,step:browse,task:list,status:ok",
+                "gommander.request.duration:12.5|ms|#plan:shop,step:browse,task:list",
+                "gommander.requests:1|c|#plan:shop,step:browse,task:item,status:fail",
+                "gommander.errors:1|c|#plan:shop,step:browse,task:item,status:fail,reason:status",
+                "gommander.aggregate.count:1|g|#plan:shop,step:browse,task:list",
+                "gommander.aggregate.failures:0|g|#plan:shop,step:browse,task:list",
+                "gommander.aggregate.mean:12.5|g|#plan:shop,step:browse,task:list",
+                "gommander.aggregate.max:12.5|g|#plan:shop,step:browse,task:list",
+                "gommander.aggregate.failures:1|g|#plan:shop,step:browse,task:item",
+                "gommander.stream.dropped:0|g|#plan:shop",
+            },
+            starts: []string{"gommander.aggregate.rate:"},
+        },
+        {
+            format: FormatInflux,
+            lines: []string{
+                "gommander_request,plan=shop,step=browse,task=list,status=ok duration_ms=12.5,user=1i 1700000000000000000",
+                `gommander_request,plan=shop,step=browse,task=item,status=fail duration_ms=1,user=2i,error="Status not expected" 1700000000000000000`,
+            },
+            starts: []string{
+                "gommander_aggregate,plan=shop,step=browse,task=list count=1i,failures=0i,rate=",
+                "gommander_aggregate,plan=shop,step=browse,task=item count=1i,failures=1i,rate=",
+                "gommander_stream,plan=shop dropped=0i ",
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.format, func(t *testing.T) {
+            pc, read := listen(t)
+            s, err := NewStream(StreamOptions{
+                Format:   tt.format,
+                Target:   "udp://" + pc.LocalAddr().String(),
+                Tags:     map[string]string{"plan": "shop"},
+                Interval: time.Hour,
+            })
+            if err != nil {
+                t.Fatal(err)
+            }
+            for _, sample := range samples {
+                s.Report(sample)
+            }
+            if err := s.Close(); err != nil {
+                t.Fatal(err)
+            }
+            got := strings.Split(strings.TrimSuffix(strings.Join(read(), ""), "\n"), "\n")
+            
+            have := make(map[string]bool)
+            for _, l := range got {
+                have[l] = true
+            }
+            for _, want := range tt.lines {
+                if !have[want] {
+                    t.Errorf("missing %s", want)
+                }
+            }
+            for _, prefix := range tt.starts {
+                found := false
+                for _, l := range got {
+                    found = found || strings.HasPrefix(l, prefix)
+                }
+                if !found {
+                    t.Errorf("missing a line starting with %s", prefix)
+                }
+            }
+            if t.Failed() {
+                t.Logf("received:\n%s", strings.Join(got, "\n"))
+            }
+        })
+    }
+}
+
+func TestSendDatagrams(t *testing.T) {
+    pc, read := listen(t)
+    conn, err := net.Dial("udp", pc.LocalAddr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+    var batch bytes.Buffer
+    for i := 0; i < 50; i++ {
+        batch.WriteString(strings.Repeat("x", 99) + "\n")
+    }
+    if err := sendDatagrams(conn, batch.Bytes()); err != nil {
+        t.Fatal(err)
+    }
+    packets := read()
+    if len(packets) != 4 {
+        t.Errorf("%d packets, want 4", len(packets))
+    }
+    for _, p := range packets {
+        if len(p) > maxDatagram || !strings.HasSuffix(p, "\n") {
+            t.Errorf("packet of %d bytes cuts a line", len(p))
+        }
+    }
+    if strings.Join(packets, "") != batch.String() {
+        t.Error("packets do not add up to the batch")
+    }
+}
+
+func TestStreamDropped(t *testing.T) {
+    // No loop reads the samples, the second one does not fit
+    s := &Stream{samples: make(chan Sample, 1)}
+    s.Report(Sample{Step: "browse", Task: "list"})
+    s.Report(Sample{Step: "browse", Task: "list"})
+    s.Report(Sample{Step: "browse", Task: "item"})
+    if got := s.Dropped(); got != 2 {
+        t.Errorf("Dropped() = %d, want 2", got)
+    }
+    var b bytes.Buffer
+    s.writeDropped(&b, time.Unix(1700000000, 0))
+    if got, want := b.String(), "gommander.stream.dropped:2|g\n"; got != want {
+        t.Errorf("dropped line = %q, want %q", got, want)
+    }
+    b.Reset()
+    s.influx = true
+    s.writeDropped(&b, time.Unix(1700000000, 0))
+    if got, want := b.String(), "gommander_stream dropped=2i 1700000000000000000\n"; got != want {
+        t.Errorf("dropped line = %q, want %q", got, want)
+    }
+}
+
+func TestStreamTags(t *testing.T) {
+    tests := []struct {
+        name   string
+        tags   [][2]string
+        statsd string
+        influx string
+    }{
+        {"none", nil, "", ""},
+        {"plain", [][2]string{{"plan", "shop"}, {"step", "browse"}}, "|#plan:shop,step:browse", ",plan=shop,step=browse"},
+        {"separators", [][2]string{{"task", "a,b c=d"}}, "|#task:a_b c=d", `,task=a\,b\ c\=d`},
+        {"statsd markers", [][2]string{{"task", "a|b#c"}}, "|#task:a_b_c", ",task=a|b#c"},
+        {"newline", [][2]string{{"reason", "bad\nline"}}, "|#reason:bad_line", `,reason=bad\ line`},
+        {"empty value", [][2]string{{"plan", "shop"}, {"reason", ""}}, "|#plan:shop,reason:", ",plan=shop"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := &Stream{}
+            if got := s.statsdTags(tt.tags); got != tt.statsd {
+                t.Errorf("statsdTags() = %q, want %q", got, tt.statsd)
+            }
+            if got := s.influxTags(tt.tags); got != tt.influx {
+                t.Errorf("influxTags() = %q, want %q", got, tt.influx)
+            }
+        })
+    }
+}
+
+func TestParseStream(t *testing.T) {
+    tests := []struct {
+        in     string
+        format string
+        target string
+        err    bool
+    }{
+        {"statsd=udp://localhost:8125", FormatStatsD, "udp://localhost:8125", false},
+        {"influx=http://localhost:8086/write?db=x", FormatInflux, "http://localhost:8086/write?db=x", false},
+        {"influx=out.lp", FormatInflux, "out.lp", false},
+        {"graphite=udp://localhost:2003", "", "", true},
+        {"statsd=", "", "", true},
+        {"udp://localhost:8125", "", "", true},
+    }
+    for _, tt := range tests {
+        opts, err := ParseStream(tt.in)
+        if (err != nil) != tt.err {
+            t.Errorf("ParseStream(%q) error = %v", tt.in, err)
+            continue
+        }
+        if opts.Format != tt.format || opts.Target != tt.target {
+            t.Errorf("ParseStream(%q) = %s, %s, want %s, %s", tt.in, opts.Format, opts.Target, tt.format, tt.target)
+        }
+    }
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Threshold is a bound a metric of the tasks must stay within for the run to
+// pass, checked on every task of the run or only on the tasks of Step and
+// called Task when set.
+type Threshold struct {
+    Metric string `json:"metric"`         // mean, p50, p90, p95, p99 or max latency, errorRate or throughput
+    Step   string `json:"step,omitempty"` // Only the tasks of this step
+    Task   string `json:"task,omitempty"` // Only the tasks called so
+    Max    string `json:"max,omitempty"`  // Highest value passing: a duration for the latencies, a ratio (0.01) or a percentage (1%) for errorRate, petitions per second for throughput
+    Min    string `json:"min,omitempty"`  // Lowest value passing, same units
+}
+
+// ThresholdResult is the outcome of a threshold on a task, Value is empty
+// when no task matched the threshold.
+type ThresholdResult struct {
+    Threshold string `json:"threshold"` // p95 <= 500ms for instance
+    Step      string `json:"step,omitempty"`
+    Task      string `json:"task,omitempty"`
+    Value     string `json:"value"`
+    Passed    bool   `json:"passed"`
+}
+
+// latencyMetrics read the latencies of a task result.
+var latencyMetrics = map[string]func(t *TaskResult) time.Duration{
+    "mean": func(t *TaskResult) time.Duration { return t.Mean },
+    "p50":  func(t *TaskResult) time.Duration { return t.P50 },
+    "p90":  func(t *TaskResult) time.Duration { return t.P90 },
+    "p95":  func(t *TaskResult) time.Duration { return t.P95 },
+    "p99":  func(t *TaskResult) time.Duration { return t.P99 },
+    "max":  func(t *TaskResult) time.Duration { return t.Max },
+}
+
+// Validate checks the metric and the bounds of th.
+func (th Threshold) Validate() error {
+    if th.Min == "" && th.Max == "" {
+        return fmt.Errorf("threshold %s needs a min or a max", th.Metric)
+    }
+    for _, bound := range []string{th.Min, th.Max} {
+        if bound == "" {
+            continue
+        }
+        if _, err := th.parse(bound); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// parse returns the value of bound in the unit of the metric: nanoseconds
+// for the latencies, a ratio for errorRate, per second for throughput.
+func (th Threshold) parse(bound string) (float64, error) {
+    switch {
+    case latencyMetrics[th.Metric] != nil:
+        d, err := time.ParseDuration(bound)
+        if err != nil {
+            return 0, fmt.Errorf("threshold %s: %s is not a duration", th.Metric, bound)
+        }
+        return float64(d), nil
+    case th.Metric == "errorRate":
+        ratio, err := strconv.ParseFloat(strings.TrimSuffix(bound, "%"), 64)
+        if err != nil {
+            return 0, fmt.Errorf("threshold %s: %s is not a ratio or a percentage", th.Metric, bound)
+        }
+        if strings.HasSuffix(bound, "%") {
+            ratio /= 100
+        }
+        return ratio, nil
+    case th.Metric == "throughput":
+        rate, err := strconv.ParseFloat(strings.TrimSuffix(bound, "/s"), 64)
+        if err != nil {
+            return 0, fmt.Errorf("threshold %s: %s is not a rate", th.Metric, bound)
+        }
+        return rate, nil
+    }
+    return 0, fmt.Errorf("unknown threshold metric %s, use mean, p50, p90, p95, p99, max, errorRate or throughput", th.Metric)
+}
+
+// value returns the metric of t, in the unit of parse, and formatted.
+func (th Threshold) value(t *TaskResult) (float64, string) {
+    if latency := latencyMetrics[th.Metric]; latency != nil {
+        d := latency(t)
+        return float64(d), d.Round(time.Microsecond).String()
+    }
+    if th.Metric == "errorRate" {
+        rate := 0.0
+        if t.Count > 0 {
+            rate = float64(t.Failures) / float64(t.Count)
+        }
+        return rate, strconv.FormatFloat(rate*100, 'f', 2, 64) + "%"
+    }
+    return t.Throughput, strconv.FormatFloat(t.Throughput, 'f', 2, 64) + "/s"
+}
+
+func (th Threshold) String() string {
+    var bounds []string
+    if th.Min != "" {
+        bounds = append(bounds, th.Metric+" >= "+th.Min)
+    }
+    if th.Max != "" {
+        bounds = append(bounds, th.Metric+" <= "+th.Max)
+    }
+    return strings.Join(bounds, " and ")
+}
+
+// CheckThresholds sets the outcome of each threshold on each task it
+// applies to in r.Thresholds. A threshold no task matched fails, it names a
+// step or a task that did not run.
+func (r *Result) CheckThresholds(thresholds []Threshold) error {
+    r.Thresholds = nil
+    for _, th := range thresholds {
+        if err := th.Validate(); err != nil {
+            return err
+        }
+        var min, max float64
+        if th.Min != "" {
+            min, _ = th.parse(th.Min)
+        }
+        if th.Max != "" {
+            max, _ = th.parse(th.Max)
+        }
+        matched := false
+        for _, s := range r.Steps {
+            if th.Step != "" && s.Name != th.Step {
+                continue
+            }
+            for _, t := range s.Tasks {
+                if th.Task != "" && t.Name != th.Task {
+                    continue
+                }
+                matched = true
+                v, text := th.value(t)
+                passed := (th.Min == "" || v >= min) && (th.Max == "" || v <= max)
+                r.Thresholds = append(r.Thresholds, ThresholdResult{Threshold: th.String(), Step: s.Name, Task: t.Name, Value: text, Passed: passed})
+            }
+        }
+        if !matched {
+            r.Thresholds = append(r.Thresholds, ThresholdResult{Threshold: th.String(), Step: th.Step, Task: th.Task})
+        }
+    }
+    return nil
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestCheckThresholds(t *testing.T) {
+    res := &Result{Steps: []*StepResult{
+        {Name: "browse", Tasks: []*TaskResult{
+            {Name: "list", Count: 100, Failures: 1, P95: 180 * time.Millisecond, Throughput: 40},
+            {Name: "get", Count: 50, P95: 320 * time.Millisecond, Throughput: 20},
+        }},
+        {Name: "buy", Tasks: []*TaskResult{
+            {Name: "order", Count: 10, Failures: 5, P95: time.Second, Throughput: 2},
+        }},
+    }}
+    tests := []struct {
+        name string
+        th   Threshold
+        want []ThresholdResult
+    }{
+        {"every task", Threshold{Metric: "p95", Max: "500ms"}, []ThresholdResult{
+            {"p95 <= 500ms", "browse", "list", "180ms", true},
+            {"p95 <= 500ms", "browse", "get", "320ms", true},
+            {"p95 <= 500ms", "buy", "order", "1s", false},
+        }},
+        {"step", Threshold{Metric: "errorRate", Step: "browse", Max: "1%"}, []ThresholdResult{
+            {"errorRate <= 1%", "browse", "list", "1.00%", true},
+            {"errorRate <= 1%", "browse", "get", "0.00%", true},
+        }},
+        {"task", Threshold{Metric: "errorRate", Task: "order", Max: "0.1"}, []ThresholdResult{
+            {"errorRate <= 0.1", "buy", "order", "50.00%", false},
+        }},
+        {"min and max", Threshold{Metric: "throughput", Task: "list", Min: "30/s", Max: "35"}, []ThresholdResult{
+            {"throughput >= 30/s and throughput <= 35", "browse", "list", "40.00/s", false},
+        }},
+        {"not run", Threshold{Metric: "p99", Step: "checkout", Max: "1s"}, []ThresholdResult{
+            {"p99 <= 1s", "checkout", "", "", false},
+        }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if err := res.CheckThresholds([]Threshold{tt.th}); err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(res.Thresholds, tt.want) {
+                t.Errorf("thresholds = %+v\nwant %+v", res.Thresholds, tt.want)
+            }
+            passed := true
+            for _, r := range tt.want {
+                passed = passed && r.Passed
+            }
+            if res.Failed() == passed {
+                t.Errorf("Failed() = %v with the thresholds %+v", res.Failed(), res.Thresholds)
+            }
+        })
+    }
+}
+
+func TestThresholdValidate(t *testing.T) {
+    tests := []struct {
+        th  Threshold
+        err string
+    }{
+        {Threshold{Metric: "mean", Max: "1.5s"}, ""},
+        {Threshold{Metric: "errorRate", Max: "0.5%"}, ""},
+        {Threshold{Metric: "throughput", Min: "10"}, ""},
+        {Threshold{Metric: "p95"}, "threshold p95 needs a min or a max"},
+        {Threshold{Metric: "p95", Max: "500"}, "threshold p95: 500 is not a duration"},
+        {Threshold{Metric: "errorRate", Max: "low"}, "threshold errorRate: low is not a ratio or a percentage"},
+        {Threshold{Metric: "throughput", Min: "fast"}, "threshold throughput: fast is not a rate"},
+        {Threshold{Metric: "p42", Max: "1s"}, "unknown threshold metric p42"},
+    }
+    for _, tt := range tests {
+        err := tt.th.Validate()
+        if tt.err == "" && err != nil {
+            t.Errorf("%+v: unexpected error %s", tt.th, err)
+        }
+        if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+            t.Errorf("%+v: error = %v, want %q", tt.th, err, tt.err)
+        }
+    }
+}
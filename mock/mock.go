@@ -0,0 +1,371 @@
+// Package mock serves the requests of a plan with the responses described
+// by their mock, so plans can be developed and tested offline.
+//
+// The mock body, raw body and header values are text/template templates
+// executed with the petition:
+//
+//	.Method, .Path      the method and the path of the petition
+//	.Params             the {{name}} parameters of the request path
+//	.Query, .Header     the first value of each query parameter and header
+//	.Body               the JSON body of the petition, decoded
+//
+// and the functions uuid, randInt min max, seq (a counter) and now (RFC 3339).
+package mock
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "log"
+    mathrand "math/rand"
+    "net/http"
+    "net/url"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "text/template"
+    "time"
+    
+    "github.com/jarlex/gommander/config"
+    "github.com/jarlex/gommander/request"
+)
+
+// pathParam matches the parameters of a request path.
+var pathParam = regexp.MustCompile(`{{([^{}]+)}}`)
+
+type route struct {
+    method  string
+    path    string
+    pattern *regexp.Regexp
+    params  []string
+    request *request.Request
+    mock    *request.Mock
+}
+
+// Server answers the petitions matching the requests of a plan, safe for
+// concurrent use.
+type Server struct {
+    Logger    *log.Logger // Logs every petition when set
+    routes    []*route
+    templates map[string]*template.Template
+    mu        sync.Mutex
+    rnd       *mathrand.Rand
+    seq       int64
+}
+
+// New returns the server of the requests of conf. A request without mock
+// answers the status expected by the tasks using it, with a JSON object
+// holding a generated id for every field they extract.
+func New(conf *config.Config) (*Server, error) {
+    s := &Server{
+        templates: make(map[string]*template.Template),
+        rnd:       mathrand.New(mathrand.NewSource(time.Now().UnixNano())),
+    }
+    base, err := url.Parse(conf.Plan.URL)
+    if err != nil {
+        return nil, fmt.Errorf("plan url: %s", err.Error())
+    }
+    if conf.Plan.Path != "" {
+        base = base.ResolveReference(&url.URL{Path: conf.Plan.Path})
+    }
+    
+    names := make([]string, 0, len(conf.Requests))
+    for name := range conf.Requests {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        r := conf.Requests[name]
+        m := r.Mock
+        if m == nil {
+            m = defaultMock(conf, r)
+        }
+        rt, err := newRoute(base, r, m)
+        if err != nil {
+            return nil, fmt.Errorf("request %s: %s", name, err.Error())
+        }
+        if err := s.compile(m); err != nil {
+            return nil, fmt.Errorf("mock of request %s: %s", name, err.Error())
+        }
+        s.routes = append(s.routes, rt)
+    }
+    // Literal paths first, /orders/new before /orders/{{id}}
+    sort.SliceStable(s.routes, func(i, j int) bool { return len(s.routes[i].params) < len(s.routes[j].params) })
+    return s, nil
+}
+
+func defaultMock(conf *config.Config, r *request.Request) *request.Mock {
+    m := &request.Mock{}
+    fields := make(map[string]interface{})
+    names := make([]string, 0, len(conf.Tasks))
+    for name := range conf.Tasks {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        t := conf.Tasks[name]
+        if t.Request != r {
+            continue
+        }
+        if m.Status == 0 {
+            m.Status = t.ExpectedStatus
+        }
+        for _, f := range t.NextData {
+            fields[f] = "{{uuid}}"
+        }
+    }
+    m.Body = fields
+    return m
+}
+
+func newRoute(base *url.URL, r *request.Request, m *request.Mock) (*route, error) {
+    if r.URL != "" {
+        u, err := url.Parse(r.URL)
+        if err != nil {
+            return nil, err
+        }
+        base = u
+    }
+    // Keep the parameters out of the URL parsing
+    raw := r.Path
+    if i := strings.IndexAny(raw, "?#"); i >= 0 {
+        raw = raw[:i]
+    }
+    path := base.ResolveReference(&url.URL{Path: pathParam.ReplaceAllString(raw, "\x00$1\x00")}).Path
+    if path == "" {
+        path = "/"
+    }
+    
+    rt := &route{method: strings.ToUpper(r.Method), request: r, mock: m}
+    if rt.method == "" {
+        rt.method = http.MethodGet
+    }
+    var expr, display strings.Builder
+    expr.WriteString("^")
+    for i, part := range strings.Split(path, "\x00") {
+        if i%2 == 1 {
+            rt.params = append(rt.params, part)
+            expr.WriteString("([^/]+)")
+            display.WriteString("{{" + part + "}}")
+            continue
+        }
+        expr.WriteString(regexp.QuoteMeta(part))
+        display.WriteString(part)
+    }
+    expr.WriteString("/?$")
+    rt.path = display.String()
+    var err error
+    if rt.pattern, err = regexp.Compile(expr.String()); err != nil {
+        return nil, err
+    }
+    return rt, nil
+}
+
+// compile parses the templates of m.
+func (s *Server) compile(m *request.Mock) error {
+    var texts []string
+    var walk func(v interface{})
+    walk = func(v interface{}) {
+        switch val := v.(type) {
+        case string:
+            texts = append(texts, val)
+        case map[string]interface{}:
+            for _, item := range val {
+                walk(item)
+            }
+        case []interface{}:
+            for _, item := range val {
+                walk(item)
+            }
+        }
+    }
+    walk(m.Body)
+    texts = append(texts, m.RawBody)
+    for _, h := range m.Headers {
+        texts = append(texts, h)
+    }
+    for _, text := range texts {
+        if _, ok := s.templates[text]; ok || !strings.Contains(text, "{{") {
+            continue
+        }
+        t, err := template.New("mock").Funcs(s.funcs()).Option("missingkey=zero").Parse(text)
+        if err != nil {
+            return err
+        }
+        s.templates[text] = t
+    }
+    return nil
+}
+
+func (s *Server) funcs() template.FuncMap {
+    return template.FuncMap{
+        "uuid": func() string {
+            var b [16]byte
+            rand.Read(b[:])
+            b[6] = b[6]&0x0f | 0x40
+            b[8] = b[8]&0x3f | 0x80
+            return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+        },
+        "randInt": func(min, max int) int {
+            if max <= min {
+                return min
+            }
+            s.mu.Lock()
+            defer s.mu.Unlock()
+            return min + s.rnd.Intn(max-min+1)
+        },
+        "seq": func() int64 { return atomic.AddInt64(&s.seq, 1) },
+        "now": func() string { return time.Now().UTC().Format(time.RFC3339) },
+    }
+}
+
+// Routes returns the method and path of every request served, the
+// parameters as {{name}}.
+func (s *Server) Routes() []string {
+    routes := make([]string, len(s.routes))
+    for i, rt := range s.routes {
+        routes[i] = rt.method + " " + rt.path + " -> " + rt.request.Name
+    }
+    return routes
+}
+
+// data is what the templates are executed with.
+type data struct {
+    Method string
+    Path   string
+    Params map[string]string
+    Query  map[string]string
+    Header map[string]string
+    Body   interface{}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    rt, params := s.match(r)
+    if rt == nil {
+        s.log(r, http.StatusNotFound)
+        writeJSON(w, http.StatusNotFound, map[string]interface{}{"error": fmt.Sprintf("no request of the plan matches %s %s", r.Method, r.URL.Path)})
+        return
+    }
+    m := rt.mock
+    if m.Latency != nil {
+        s.mu.Lock()
+        delay := m.Latency.Delay(s.rnd)
+        s.mu.Unlock()
+        timer := time.NewTimer(delay)
+        select {
+        case <-timer.C:
+        case <-r.Context().Done():
+            timer.Stop()
+            return
+        }
+    }
+    s.mu.Lock()
+    failing := m.ErrorRate > 0 && s.rnd.Float64() < m.ErrorRate
+    s.mu.Unlock()
+    if failing {
+        status := m.ErrorStatus
+        if status == 0 {
+            status = http.StatusInternalServerError
+        }
+        s.log(r, status)
+        writeJSON(w, status, map[string]interface{}{"error": "injected by gommander mock"})
+        return
+    }
+    
+    d := data{Method: r.Method, Path: r.URL.Path, Params: params, Query: map[string]string{}, Header: map[string]string{}}
+    for k := range r.URL.Query() {
+        d.Query[k] = r.URL.Query().Get(k)
+    }
+    for k := range r.Header {
+        d.Header[k] = r.Header.Get(k)
+    }
+    if raw, err := ioutil.ReadAll(r.Body); err == nil && len(raw) > 0 {
+        json.Unmarshal(raw, &d.Body)
+    }
+    
+    status := m.Status
+    if status == 0 {
+        status = http.StatusOK
+    }
+    for k, v := range m.Headers {
+        w.Header().Set(k, s.render(v, d))
+    }
+    s.log(r, status)
+    if m.RawBody != "" {
+        w.WriteHeader(status)
+        w.Write([]byte(s.render(m.RawBody, d)))
+        return
+    }
+    writeJSON(w, status, s.renderAll(m.Body, d))
+}
+
+func (s *Server) match(r *http.Request) (*route, map[string]string) {
+    for _, rt := range s.routes {
+        if rt.method != r.Method {
+            continue
+        }
+        m := rt.pattern.FindStringSubmatch(r.URL.Path)
+        if m == nil {
+            continue
+        }
+        params := make(map[string]string, len(rt.params))
+        for i, p := range rt.params {
+            params[p] = m[i+1]
+        }
+        return rt, params
+    }
+    return nil, nil
+}
+
+func (s *Server) render(text string, d data) string {
+    t, ok := s.templates[text]
+    if !ok {
+        return text
+    }
+    var out bytes.Buffer
+    if err := t.Execute(&out, d); err != nil {
+        return err.Error()
+    }
+    return out.String()
+}
+
+// renderAll renders the strings of a JSON body.
+func (s *Server) renderAll(v interface{}, d data) interface{} {
+    switch val := v.(type) {
+    case string:
+        return s.render(val, d)
+    case map[string]interface{}:
+        out := make(map[string]interface{}, len(val))
+        for k, item := range val {
+            out[k] = s.renderAll(item, d)
+        }
+        return out
+    case []interface{}:
+        out := make([]interface{}, len(val))
+        for i, item := range val {
+            out[i] = s.renderAll(item, d)
+        }
+        return out
+    }
+    return v
+}
+
+func (s *Server) log(r *http.Request, status int) {
+    if s.Logger != nil {
+        s.Logger.Printf("%s %s %d", r.Method, r.URL.RequestURI(), status)
+    }
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    if w.Header().Get("Content-Type") == "" {
+        w.Header().Set("Content-Type", "application/json")
+    }
+    w.WriteHeader(status)
+    if v != nil {
+        json.NewEncoder(w).Encode(v)
+    }
+}
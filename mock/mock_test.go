@@ -0,0 +1,184 @@
+package mock
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "strings"
+    "testing"
+    "testing/fstest"
+    "time"
+    
+    "github.com/jarlex/gommander/config"
+)
+
+const shop = `
+name: shop
+url: http://localhost:8080
+requests:
+  - name: getOrder
+    method: GET
+    path: /orders/{{id}}
+    mock:
+      headers: {X-Order: "{{.Params.id}}"}
+      body:
+        id: "{{.Params.id}}"
+        page: "{{.Query.page}}"
+        client: '{{index .Header "X-Client"}}'
+        seq: "{{seq}}"
+        items: ["{{.Method}} {{.Path}}"]
+  - {name: newOrder, method: GET, path: /orders/new, mock: {rawBody: "new", headers: {Content-Type: text/plain}}}
+  - name: itemOfOrder
+    method: GET
+    path: /orders/{{id}}/items/{{item}}
+    mock: {body: {item: "{{.Params.item}}"}}
+  - name: createOrder
+    method: POST
+    path: /orders
+    mock: {status: 201, body: {name: "{{.Body.name}}", total: 3}}
+  - {name: broken, method: DELETE, path: "/orders/{{id}}", mock: {errorRate: 1, errorStatus: 503}}
+  - {name: slow, method: GET, path: /slow, mock: {latency: {mean: 50ms}}}
+  - {name: login, method: POST, path: /login, url: http://auth:9000/v1}
+tasks:
+  - {name: login, expectedStatus: 202, nextData: [token], request: login}
+steps: []
+`
+
+func newServer(t *testing.T) *httptest.Server {
+    conf, err := config.Load(fstest.MapFS{"plan.yaml": {Data: []byte(shop)}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    s, err := New(conf)
+    if err != nil {
+        t.Fatal(err)
+    }
+    srv := httptest.NewServer(s)
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+func TestRoutes(t *testing.T) {
+    conf, err := config.Load(fstest.MapFS{"plan.yaml": {Data: []byte(shop)}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    s, err := New(conf)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []string{
+        "POST /orders -> createOrder",
+        "POST /login -> login",
+        "GET /orders/new -> newOrder",
+        "GET /slow -> slow",
+        "DELETE /orders/{{id}} -> broken",
+        "GET /orders/{{id}} -> getOrder",
+        "GET /orders/{{id}}/items/{{item}} -> itemOfOrder",
+    }
+    if got := s.Routes(); !reflect.DeepEqual(got, want) {
+        t.Errorf("routes = %q\nwant %q", got, want)
+    }
+}
+
+func TestServeHTTP(t *testing.T) {
+    srv := newServer(t)
+    tests := []struct {
+        name   string
+        method string
+        path   string
+        body   string
+        status int
+        header [2]string
+        want   string
+    }{
+        {"template", "GET", "/orders/42?page=2", "", 200, [2]string{"X-Order", "42"},
+            `{"client":"test","id":"42","items":["GET /orders/42"],"page":"2","seq":"1"}`},
+        {"counter", "GET", "/orders/43/", "", 200, [2]string{"X-Order", "43"},
+            `{"client":"test","id":"43","items":["GET /orders/43/"],"page":"","seq":"2"}`},
+        {"literal first", "GET", "/orders/new", "", 200, [2]string{"Content-Type", "text/plain"}, "new"},
+        {"two params", "GET", "/orders/1/items/2", "", 200, [2]string{}, `{"item":"2"}`},
+        {"request body", "POST", "/orders", `{"name": "book"}`, 201, [2]string{}, `{"name":"book","total":3}`},
+        {"injected error", "DELETE", "/orders/1", "", 503, [2]string{}, `{"error":"injected by gommander mock"}`},
+        {"unmatched path", "GET", "/users", "", 404, [2]string{}, `{"error":"no request of the plan matches GET /users"}`},
+        {"unmatched method", "PUT", "/orders/1", "", 404, [2]string{}, `{"error":"no request of the plan matches PUT /orders/1"}`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+            if err != nil {
+                t.Fatal(err)
+            }
+            req.Header.Set("X-Client", "test")
+            resp, err := http.DefaultClient.Do(req)
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer resp.Body.Close()
+            raw, _ := ioutil.ReadAll(resp.Body)
+            if resp.StatusCode != tt.status {
+                t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+            }
+            if tt.header[0] != "" && resp.Header.Get(tt.header[0]) != tt.header[1] {
+                t.Errorf("%s = %q, want %q", tt.header[0], resp.Header.Get(tt.header[0]), tt.header[1])
+            }
+            if got := strings.TrimSpace(string(raw)); got != tt.want {
+                t.Errorf("body = %s\nwant %s", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestDefaultMock(t *testing.T) {
+    srv := newServer(t)
+    resp, err := http.Post(srv.URL+"/login", "application/json", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer resp.Body.Close()
+    var body map[string]string
+    if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+        t.Fatal(err)
+    }
+    if resp.StatusCode != 202 || len(body) != 1 || len(body["token"]) != 36 {
+        t.Errorf("default mock answered %d %v", resp.StatusCode, body)
+    }
+}
+
+func TestLatency(t *testing.T) {
+    srv := newServer(t)
+    start := time.Now()
+    resp, err := http.Get(srv.URL + "/slow")
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+        t.Errorf("answered after %s, want at least 50ms", elapsed)
+    }
+}
+
+func TestNewErrors(t *testing.T) {
+    tests := []struct {
+        name, request, err string
+    }{
+        {"template", `{name: r, method: GET, path: /, mock: {body: {a: "{{.Params"}}}`, "mock of request r: "},
+        {"error rate", `{name: r, method: GET, path: /, mock: {errorRate: 2}}`, "errorRate 2 is not between 0 and 1"},
+        {"distribution", `{name: r, method: GET, path: /, mock: {latency: {distribution: pareto}}}`, "unknown latency distribution pareto"},
+        {"bounds", `{name: r, method: GET, path: /, mock: {latency: {distribution: uniform, min: 2s, max: 1s}}}`, "latency max 1s is below min 2s"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            plan := "name: p\nurl: http://localhost\nrequests: [" + tt.request + "]\nsteps: []\n"
+            conf, err := config.Load(fstest.MapFS{"plan.yaml": {Data: []byte(plan)}})
+            if err == nil {
+                _, err = New(conf)
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Fatalf("error = %v, want %q", err, tt.err)
+            }
+        })
+    }
+}
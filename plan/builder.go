@@ -0,0 +1,364 @@
+package plan
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "time"
+    
+    "github.com/jarlex/gommander/feeder"
+    "github.com/jarlex/gommander/jsonschema"
+    "github.com/jarlex/gommander/metrics"
+    "github.com/jarlex/gommander/request"
+    "github.com/jarlex/gommander/step"
+    "github.com/jarlex/gommander/task"
+)
+
+// Builder builds a plan from Go code. Its methods apply to the element
+// added last: Task adds a task to the last step, Request sets the request of
+// the last task, and so on.
+//
+//	p, err := plan.New("orders").URL("http://localhost:8080").
+//		Step("browse", 10, 1000).
+//		Task("list", 200).Request("listOrders", "GET", "/orders").
+//		Task("get", 200).Needs("id").Request("getOrder", "GET", "/orders/{{id}}").
+//		Build()
+//
+// Tasks and requests are shared by name: adding a task or a request whose
+// name is already known reuses the existing one when no new definition is
+// given, see UseTask and UseRequest.
+type Builder struct {
+    plan     *Plan
+    step     *step.Step
+    task     *task.Task
+    request  *request.Request
+    tasks    map[string]*task.Task
+    requests map[string]*request.Request
+    err      error
+}
+
+// New starts the plan called name.
+func New(name string) *Builder {
+    return &Builder{
+        plan:     &Plan{Name: name},
+        tasks:    make(map[string]*task.Task),
+        requests: make(map[string]*request.Request),
+    }
+}
+
+func (b *Builder) fail(format string, args ...interface{}) *Builder {
+    if b.err == nil {
+        b.err = fmt.Errorf(format, args...)
+    }
+    return b
+}
+
+// URL sets the base URL of the plan.
+func (b *Builder) URL(url string) *Builder {
+    b.plan.URL = url
+    return b
+}
+
+// Path sets the base path of the plan.
+func (b *Builder) Path(path string) *Builder {
+    b.plan.Path = path
+    return b
+}
+
+// Type sets the type of the plan.
+func (b *Builder) Type(planType string) *Builder {
+    b.plan.Type = planType
+    return b
+}
+
+// BasicAuth authenticates every request of the plan with user and pass.
+func (b *Builder) BasicAuth(user, pass string) *Builder {
+    b.plan.AuthType = "basic"
+    b.plan.AuthUser = user
+    b.plan.AuthPass = pass
+    return b
+}
+
+// Var sets a plan variable, data every petition starts with.
+func (b *Builder) Var(name string, value interface{}) *Builder {
+    if b.plan.Vars == nil {
+        b.plan.Vars = make(map[string]interface{})
+    }
+    b.plan.Vars[name] = value
+    return b
+}
+
+// Feeder hands each petition the next row of f, saved as the file name of
+// the plan folder. Saving the plan does not write the file.
+func (b *Builder) Feeder(file string, f *feeder.Feeder) *Builder {
+    b.plan.FeederFile = file
+    b.plan.Feeder = f
+    return b
+}
+
+// Threshold adds a bound of the task metrics the run must stay within to
+// pass.
+func (b *Builder) Threshold(th metrics.Threshold) *Builder {
+    if err := th.Validate(); err != nil {
+        return b.fail("%s", err.Error())
+    }
+    b.plan.Thresholds = append(b.plan.Thresholds, th)
+    return b
+}
+
+// Step adds a step run by users concurrent users doing petitions petitions
+// in total.
+func (b *Builder) Step(name string, users, petitions int) *Builder {
+    if users <= 0 {
+        return b.fail("step %s needs at least one user", name)
+    }
+    if petitions < 0 {
+        return b.fail("step %s cannot have negative petitions", name)
+    }
+    s := &step.Step{Name: name, ConcurrentUsers: users, NumPetitions: petitions}
+    b.plan.Steps = append(b.plan.Steps, s)
+    b.plan.StepsNames = append(b.plan.StepsNames, name)
+    b.step = s
+    b.task = nil
+    b.request = nil
+    return b
+}
+
+// DependsOn sets the steps the last step waits for, none starts it with the
+// plan.
+func (b *Builder) DependsOn(steps ...string) *Builder {
+    if b.step == nil {
+        return b.fail("DependsOn needs a step")
+    }
+    b.step.DependsOn = append([]string{}, steps...)
+    return b
+}
+
+// StartAfter delays the start of the last step once its dependencies
+// finished.
+func (b *Builder) StartAfter(d time.Duration) *Builder {
+    if b.step == nil {
+        return b.fail("StartAfter needs a step")
+    }
+    b.step.StartAfter = d
+    b.step.StartOffset = d.String()
+    return b
+}
+
+// Task adds a new task expecting status to the last step.
+func (b *Builder) Task(name string, expectedStatus int) *Builder {
+    t, ok := b.newTask(name, expectedStatus)
+    if !ok {
+        return b
+    }
+    return b.addTask(t, "Task")
+}
+
+// UseTask adds the task name, already defined, to the last step.
+func (b *Builder) UseTask(name string) *Builder {
+    t := b.tasks[name]
+    if t == nil {
+        return b.fail("task %s is not defined", name)
+    }
+    b.task = t
+    b.request = t.Request
+    return b.addTask(t, "UseTask")
+}
+
+func (b *Builder) addTask(t *task.Task, method string) *Builder {
+    if b.step == nil {
+        return b.fail("%s %s needs a step", method, t.Name)
+    }
+    b.step.Tasks = append(b.step.Tasks, t)
+    b.step.TasksNames = append(b.step.TasksNames, t.Name)
+    return b
+}
+
+// Setup adds a new setup task expecting status to the last step, or to the
+// plan when no step was added yet.
+func (b *Builder) Setup(name string, expectedStatus int) *Builder {
+    t, ok := b.newTask(name, expectedStatus)
+    if !ok {
+        return b
+    }
+    if b.step == nil {
+        b.plan.Setup = append(b.plan.Setup, t)
+        b.plan.SetupNames = append(b.plan.SetupNames, name)
+        return b
+    }
+    b.step.Setup = append(b.step.Setup, t)
+    b.step.SetupNames = append(b.step.SetupNames, name)
+    return b
+}
+
+// Teardown adds a new teardown task expecting status to the last step, or
+// to the plan when no step was added yet.
+func (b *Builder) Teardown(name string, expectedStatus int) *Builder {
+    t, ok := b.newTask(name, expectedStatus)
+    if !ok {
+        return b
+    }
+    if b.step == nil {
+        b.plan.Teardown = append(b.plan.Teardown, t)
+        b.plan.TeardownNames = append(b.plan.TeardownNames, name)
+        return b
+    }
+    b.step.Teardown = append(b.step.Teardown, t)
+    b.step.TeardownNames = append(b.step.TeardownNames, name)
+    return b
+}
+
+func (b *Builder) newTask(name string, expectedStatus int) (*task.Task, bool) {
+    if b.tasks[name] != nil {
+        b.fail("task %s is already defined, use UseTask", name)
+        return nil, false
+    }
+    t := &task.Task{Name: name, ExpectedStatus: expectedStatus}
+    b.tasks[name] = t
+    b.task = t
+    b.request = nil
+    return t, true
+}
+
+// Needs sets the data the last task needs from the previous ones.
+func (b *Builder) Needs(fields ...string) *Builder {
+    if b.task == nil {
+        return b.fail("Needs needs a task")
+    }
+    b.task.PreviousData = append(b.task.PreviousData, fields...)
+    return b
+}
+
+// Extract sets the response fields the last task hands to the next ones.
+func (b *Builder) Extract(fields ...string) *Builder {
+    if b.task == nil {
+        return b.fail("Extract needs a task")
+    }
+    b.task.NextData = append(b.task.NextData, fields...)
+    return b
+}
+
+// ThinkTime makes the users pause for d before the last task.
+func (b *Builder) ThinkTime(d time.Duration) *Builder {
+    if b.task == nil {
+        return b.fail("ThinkTime needs a task")
+    }
+    b.task.Think = d
+    b.task.ThinkTime = d.String()
+    return b
+}
+
+// Schema sets the JSON Schema the response body of the last task must
+// match.
+func (b *Builder) Schema(schema map[string]interface{}) *Builder {
+    if b.task == nil {
+        return b.fail("Schema needs a task")
+    }
+    compiled, err := jsonschema.New(schema)
+    if err != nil {
+        return b.fail("schema of task %s: %s", b.task.Name, err.Error())
+    }
+    b.task.Schema = schema
+    b.task.ResponseSchema = compiled
+    return b
+}
+
+var pathParam = regexp.MustCompile(`{{([^{}]+)}}`)
+
+// Request sets a new request as the request of the last task. The {{name}}
+// parameters of path are filled with the data of the petition.
+func (b *Builder) Request(name, method, path string) *Builder {
+    if b.task == nil {
+        return b.fail("Request %s needs a task", name)
+    }
+    if b.requests[name] != nil {
+        return b.fail("request %s is already defined, use UseRequest", name)
+    }
+    r := &request.Request{Name: name, Method: method, Path: path}
+    for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+        r.ParamsURL = append(r.ParamsURL, m[1])
+    }
+    b.requests[name] = r
+    return b.setRequest(r)
+}
+
+// UseRequest sets the request name, already defined, as the request of the
+// last task.
+func (b *Builder) UseRequest(name string) *Builder {
+    if b.task == nil {
+        return b.fail("UseRequest %s needs a task", name)
+    }
+    r := b.requests[name]
+    if r == nil {
+        return b.fail("request %s is not defined", name)
+    }
+    return b.setRequest(r)
+}
+
+func (b *Builder) setRequest(r *request.Request) *Builder {
+    b.task.Request = r
+    b.task.NameRequest = r.Name
+    b.request = r
+    return b
+}
+
+// RequestURL sends the last request to url instead of the plan URL.
+func (b *Builder) RequestURL(url string) *Builder {
+    if b.request == nil {
+        return b.fail("RequestURL needs a request")
+    }
+    b.request.URL = url
+    return b
+}
+
+// Body sets the JSON body of the last request. The params are filled with
+// the data of the petition.
+func (b *Builder) Body(body map[string]interface{}, params ...string) *Builder {
+    if b.request == nil {
+        return b.fail("Body needs a request")
+    }
+    b.request.Body = body
+    b.request.ParamsBody = append(b.request.ParamsBody, params...)
+    return b
+}
+
+// Header sets a header sent with the last request.
+func (b *Builder) Header(key, value string) *Builder {
+    if b.request == nil {
+        return b.fail("Header needs a request")
+    }
+    if b.request.Headers == nil {
+        b.request.Headers = make(map[string]string)
+    }
+    b.request.Headers[key] = value
+    return b
+}
+
+// RawBody sets the body of the last request, sent as is instead of a JSON
+// body.
+func (b *Builder) RawBody(body string) *Builder {
+    if b.request == nil {
+        return b.fail("RawBody needs a request")
+    }
+    b.request.RawBody = body
+    return b
+}
+
+// Build returns the plan, or the first error found while building it.
+func (b *Builder) Build() (*Plan, error) {
+    if b.err != nil {
+        return nil, b.err
+    }
+    for name, t := range b.tasks {
+        if t.Request == nil {
+            return nil, fmt.Errorf("task %s has no request", name)
+        }
+    }
+    if len(b.plan.Steps) == 0 {
+        return nil, errors.New("the plan has no steps")
+    }
+    if _, err := b.plan.dependencies(); err != nil {
+        return nil, fmt.Errorf("plan %s: %s", b.plan.Name, err.Error())
+    }
+    return b.plan, nil
+}
@@ -0,0 +1,102 @@
+package plan
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestBuilder(t *testing.T) {
+    p, err := New("shop").URL("http://localhost").Path("/api").Type("load").BasicAuth("user", "pass").
+        Setup("login", 200).Request("login", "POST", "/login").Body(map[string]interface{}{"user": "u"}, "password").Extract("token").
+        Teardown("logout", 204).Request("logout", "DELETE", "/sessions/{{token}}").
+        Step("browse", 2, 10).
+        Setup("fixture", 201).Request("fixture", "POST", "/fixtures").RequestURL("http://fixtures").
+        Task("list", 200).Request("list", "GET", "/items").Extract("id").
+        Task("get", 200).Needs("id").Request("get", "GET", "/users/{{user}}/items/{{id}}").
+        Step("again", 1, 1).DependsOn().StartAfter(time.Second).
+        UseTask("list").
+        Task("get twice", 200).UseRequest("get").
+        Build()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if p.URL != "http://localhost" || p.Path != "/api" || p.Type != "load" || p.AuthType != "basic" || p.AuthUser != "user" || p.AuthPass != "pass" {
+        t.Errorf("plan = %+v", p)
+    }
+    if !reflect.DeepEqual(p.SetupNames, []string{"login"}) || !reflect.DeepEqual(p.TeardownNames, []string{"logout"}) {
+        t.Errorf("plan setup, teardown = %q, %q", p.SetupNames, p.TeardownNames)
+    }
+    if !reflect.DeepEqual(p.StepsNames, []string{"browse", "again"}) || len(p.Steps) != 2 {
+        t.Fatalf("steps = %q", p.StepsNames)
+    }
+    
+    browse, again := p.Steps[0], p.Steps[1]
+    if browse.ConcurrentUsers != 2 || browse.NumPetitions != 10 || browse.DependsOn != nil {
+        t.Errorf("browse = %+v", browse)
+    }
+    if !reflect.DeepEqual(browse.SetupNames, []string{"fixture"}) || browse.Setup[0].Request.URL != "http://fixtures" {
+        t.Errorf("browse setup = %q", browse.SetupNames)
+    }
+    if !reflect.DeepEqual(browse.TasksNames, []string{"list", "get"}) {
+        t.Errorf("browse tasks = %q", browse.TasksNames)
+    }
+    get := browse.Tasks[1]
+    if !reflect.DeepEqual(get.PreviousData, []string{"id"}) || !reflect.DeepEqual(get.Request.ParamsURL, []string{"user", "id"}) {
+        t.Errorf("get = %+v, request %+v", get, get.Request)
+    }
+    login := p.Setup[0]
+    if !reflect.DeepEqual(login.NextData, []string{"token"}) || !reflect.DeepEqual(login.Request.ParamsBody, []string{"password"}) || login.Request.Body["user"] != "u" {
+        t.Errorf("login = %+v, request %+v", login, login.Request)
+    }
+    
+    if again.DependsOn == nil || len(again.DependsOn) != 0 || again.StartAfter != time.Second || again.StartOffset != "1s" {
+        t.Errorf("again = %+v", again)
+    }
+    if again.Tasks[0] != browse.Tasks[0] {
+        t.Error("UseTask did not share the task")
+    }
+    if again.Tasks[1].Request != get.Request || again.Tasks[1].NameRequest != "get" {
+        t.Error("UseRequest did not share the request")
+    }
+}
+
+func TestBuilderErrors(t *testing.T) {
+    tests := []struct {
+        name  string
+        build func() *Builder
+        err   string
+    }{
+        {"no steps", func() *Builder { return New("p") }, "the plan has no steps"},
+        {"no users", func() *Builder { return New("p").Step("s", 0, 1) }, "step s needs at least one user"},
+        {"task without step", func() *Builder { return New("p").Task("t", 200).Request("r", "GET", "/") }, "Task t needs a step"},
+        {"task twice", func() *Builder {
+            return New("p").Step("s", 1, 1).Task("t", 200).Request("r", "GET", "/").Task("t", 200)
+        }, "task t is already defined, use UseTask"},
+        {"unknown task", func() *Builder { return New("p").Step("s", 1, 1).UseTask("t") }, "task t is not defined"},
+        {"task without request", func() *Builder { return New("p").Step("s", 1, 1).Task("t", 200) }, "task t has no request"},
+        {"request without task", func() *Builder { return New("p").Step("s", 1, 1).Request("r", "GET", "/") }, "Request r needs a task"},
+        {"request twice", func() *Builder {
+            return New("p").Step("s", 1, 1).Task("a", 200).Request("r", "GET", "/").Task("b", 200).Request("r", "GET", "/")
+        }, "request r is already defined, use UseRequest"},
+        {"unknown request", func() *Builder { return New("p").Step("s", 1, 1).Task("t", 200).UseRequest("r") }, "request r is not defined"},
+        {"body without request", func() *Builder { return New("p").Step("s", 1, 1).Task("t", 200).Body(nil) }, "Body needs a request"},
+        {"dependsOn without step", func() *Builder { return New("p").DependsOn("a") }, "DependsOn needs a step"},
+        {"unknown dependency", func() *Builder {
+            return New("p").Step("s", 1, 1).DependsOn("x").Task("t", 200).Request("r", "GET", "/")
+        }, "step s depends on x which is not in the plan"},
+        {"cycle", func() *Builder {
+            return New("p").Step("a", 1, 1).DependsOn("b").Task("t", 200).Request("r", "GET", "/").Step("b", 1, 1).UseTask("t")
+        }, "steps dependency cycle"},
+        {"first error", func() *Builder { return New("p").Step("s", 0, 1).DependsOn("x").UseTask("t") }, "step s needs at least one user"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := tt.build().Build()
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Fatalf("error = %v, want %q", err, tt.err)
+            }
+        })
+    }
+}
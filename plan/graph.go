@@ -0,0 +1,69 @@
+package plan
+
+import (
+    "fmt"
+    "strings"
+    
+    "github.com/jarlex/gommander/step"
+)
+
+// dependencies returns the steps each step of the plan waits for. A step
+// without dependsOn waits for the previous one in the plan, so plans written
+// before dependsOn existed keep running sequentially. An empty dependsOn
+// starts the step with the plan.
+func (p *Plan) dependencies() (map[string][]*step.Step, error) {
+    byName := make(map[string]*step.Step, len(p.Steps))
+    for _, s := range p.Steps {
+        if byName[s.Name] != nil {
+            return nil, fmt.Errorf("step %s is used twice in the plan", s.Name)
+        }
+        byName[s.Name] = s
+    }
+    
+    deps := make(map[string][]*step.Step, len(p.Steps))
+    for i, s := range p.Steps {
+        if s.DependsOn == nil {
+            if i > 0 {
+                deps[s.Name] = []*step.Step{p.Steps[i-1]}
+            }
+            continue
+        }
+        for _, d := range s.DependsOn {
+            if byName[d] == nil {
+                return nil, fmt.Errorf("step %s depends on %s which is not in the plan", s.Name, d)
+            }
+            deps[s.Name] = append(deps[s.Name], byName[d])
+        }
+    }
+    
+    // Depth first search, a step found again while still being visited
+    // closes a cycle.
+    const (
+        visiting = 1
+        visited  = 2
+    )
+    state := make(map[string]int, len(p.Steps))
+    var visit func(s *step.Step, path []string) error
+    visit = func(s *step.Step, path []string) error {
+        switch state[s.Name] {
+        case visiting:
+            return fmt.Errorf("steps dependency cycle: %s", strings.Join(append(path, s.Name), " -> "))
+        case visited:
+            return nil
+        }
+        state[s.Name] = visiting
+        for _, d := range deps[s.Name] {
+            if err := visit(d, append(path, s.Name)); err != nil {
+                return err
+            }
+        }
+        state[s.Name] = visited
+        return nil
+    }
+    for _, s := range p.Steps {
+        if err := visit(s, nil); err != nil {
+            return nil, err
+        }
+    }
+    return deps, nil
+}
@@ -0,0 +1,59 @@
+package plan
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+    
+    "github.com/jarlex/gommander/step"
+)
+
+func TestDependencies(t *testing.T) {
+    s := func(name string, dependsOn ...string) *step.Step {
+        return &step.Step{Name: name, DependsOn: append([]string{}, dependsOn...)}
+    }
+    sequential := func(name string) *step.Step {
+        return &step.Step{Name: name}
+    }
+    tests := []struct {
+        name  string
+        steps []*step.Step
+        want  map[string][]string
+        err   string
+    }{
+        {"sequential", []*step.Step{sequential("a"), sequential("b"), sequential("c")}, map[string][]string{"b": {"a"}, "c": {"b"}}, ""},
+        {"start with the plan", []*step.Step{sequential("a"), s("b")}, map[string][]string{}, ""},
+        {"explicit", []*step.Step{sequential("a"), sequential("b"), s("c", "a")}, map[string][]string{"b": {"a"}, "c": {"a"}}, ""},
+        {"fan in", []*step.Step{sequential("a"), s("b"), s("c", "a", "b")}, map[string][]string{"c": {"a", "b"}}, ""},
+        {"later step", []*step.Step{s("a", "b"), s("b")}, map[string][]string{"a": {"b"}}, ""},
+        {"unknown step", []*step.Step{sequential("a"), s("b", "x")}, nil, "step b depends on x which is not in the plan"},
+        {"used twice", []*step.Step{sequential("a"), sequential("a")}, nil, "step a is used twice in the plan"},
+        {"self", []*step.Step{s("a", "a")}, nil, "steps dependency cycle: a -> a"},
+        {"cycle", []*step.Step{s("a", "c"), s("b", "a"), s("c", "b")}, nil, "steps dependency cycle: a -> c -> b -> a"},
+        {"implicit cycle", []*step.Step{s("a", "b"), sequential("b")}, nil, "steps dependency cycle: a -> b -> a"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            p := &Plan{Steps: tt.steps}
+            deps, err := p.dependencies()
+            if tt.err != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.err) {
+                    t.Fatalf("error = %v, want %q", err, tt.err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            got := make(map[string][]string)
+            for name, steps := range deps {
+                for _, d := range steps {
+                    got[name] = append(got[name], d.Name)
+                }
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("dependencies = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
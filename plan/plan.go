@@ -1,54 +1,100 @@
 package plan
 
 import (
-    "encoding/json"
+    "context"
     "fmt"
     "io/ioutil"
-    "log"
-    "os"
+    "sync"
     "time"
     
+    "github.com/jarlex/gommander/feeder"
+    "github.com/jarlex/gommander/logging"
+    "github.com/jarlex/gommander/metrics"
     "github.com/jarlex/gommander/step"
+    "github.com/jarlex/gommander/strict"
+    "github.com/jarlex/gommander/task"
     "github.com/jarlex/transporter"
 )
 
 type Plan struct {
-    Type         string   `json:"type"`
-    Name         string   `json:"name"`
-    AuthType     string   `json:"authType"`
-    AuthUser     string   `json:"authUser"`
-    AuthPass     string   `json:"authPass"`
-    AuthEndpoint string   `json:"authEndpoint"`
-    URL          string   `json:"url"`
-    Path         string   `json:"path"`
-    StepsNames   []string `json:"steps"`
-    Steps        []*step.Step
+    Type          string                 `json:"type"`
+    Name          string                 `json:"name"`
+    AuthType      string                 `json:"authType"`
+    AuthUser      string                 `json:"authUser"`
+    AuthPass      string                 `json:"authPass"`
+    AuthEndpoint  string                 `json:"authEndpoint"`
+    URL           string                 `json:"url"`
+    Path          string                 `json:"path"`
+    StepsNames    []string               `json:"steps"`
+    SetupNames    []string               `json:"setup,omitempty"`
+    TeardownNames []string               `json:"teardown,omitempty"`
+    Vars          map[string]interface{} `json:"vars,omitempty"`       // Data every petition starts with
+    FeederFile    string                 `json:"feeder,omitempty"`     // CSV or JSON rows handed to the petitions, see feeder.Parse
+    Thresholds    []metrics.Threshold    `json:"thresholds,omitempty"` // Bounds of the task metrics for the run to pass
+    Feeder        *feeder.Feeder         `json:"-"`
+    Steps         []*step.Step           `json:"-"`
+    Setup         []*task.Task           `json:"-"`
+    Teardown      []*task.Task           `json:"-"`
 }
 
-func Read(filePath string, steps map[string]*step.Step) (*Plan, error) {
-    logger := log.New(os.Stdout, "", 0)
+// timeline records when a step ran, relative to the plan start.
+type timeline struct {
+    done chan struct{}
+    metrics.StepReport
+}
+
+func Read(filePath string, steps map[string]*step.Step, tasks map[string]*task.Task) (*Plan, error) {
     raw, err := ioutil.ReadFile(filePath)
-    
     if err != nil {
-        logger.Fatalf("Error reading the file in %s: %s", filePath, err.Error())
-        return nil, err
+        return nil, fmt.Errorf("Error reading the file in %s: %s", filePath, err.Error())
     }
     
+    return Parse(raw, steps, tasks)
+}
+
+// Parse decodes a plan definition, rejecting the unknown keys, links its
+// steps and hooks and checks the steps dependencies and the thresholds.
+func Parse(raw []byte, steps map[string]*step.Step, tasks map[string]*task.Task) (*Plan, error) {
     var p Plan
-    json.Unmarshal(raw, &p)
+    if err := strict.Unmarshal(raw, &p, nil); err != nil {
+        return nil, err
+    }
     for _, step := range p.StepsNames {
         if steps[step] == nil {
-            logger.Fatalf("Error reading the step %s", step)
-            return nil, err
+            return nil, fmt.Errorf("step %s not found", step)
         }
         p.Steps = append(p.Steps, steps[step])
     }
+    for _, t := range p.SetupNames {
+        if tasks[t] == nil {
+            return nil, fmt.Errorf("setup task %s not found", t)
+        }
+        p.Setup = append(p.Setup, tasks[t])
+    }
+    for _, t := range p.TeardownNames {
+        if tasks[t] == nil {
+            return nil, fmt.Errorf("teardown task %s not found", t)
+        }
+        p.Teardown = append(p.Teardown, tasks[t])
+    }
+    if _, err := p.dependencies(); err != nil {
+        return nil, fmt.Errorf("plan %s: %s", p.Name, err.Error())
+    }
+    for _, th := range p.Thresholds {
+        if err := th.Validate(); err != nil {
+            return nil, fmt.Errorf("plan %s: %s", p.Name, err.Error())
+        }
+    }
     return &p, nil
 }
 
-func (p *Plan) Execute() {
-    logger := log.New(os.Stdout, "", 0)
-    t := transporter.New()
+// Execute runs the plan with a new transporter, see ExecuteWith.
+func (p *Plan) Execute(ctx context.Context) error {
+    return p.ExecuteWith(ctx, transporter.New())
+}
+
+// Configure sets the authentication and the base URL of the plan on t.
+func (p *Plan) Configure(t *transporter.Transporter) {
     switch p.AuthType {
     case "basic":
         t.SetBasicAuth(p.AuthUser, p.AuthPass)
@@ -57,10 +103,93 @@ func (p *Plan) Execute() {
     }
     t.Base(p.URL)
     t.Path(p.Path)
+}
+
+// ExecuteWith runs the plan setup tasks once, then the steps, then the plan
+// teardown tasks, sending the requests through t. The plan variables and the
+// ones extracted by the setup are shared with every step, and each petition
+// of the steps gets the next row of the feeder over them. Each step starts
+// when its dependencies finished and its startAfter offset elapsed, so
+// independent steps run concurrently. A failing setup aborts the run. A step
+// that cannot run, its setup failing or its users invalid, skips the steps
+// depending on it; failing requests do not, they are counted in the samples.
+// Teardown always runs, see step.TeardownContext. Steps not started yet are skipped once ctx is
+// draining or cancelled, and every step is still reported to the reporter of
+// ctx.
+func (p *Plan) ExecuteWith(ctx context.Context, t *transporter.Transporter) error {
+    logger := logging.FromContext(ctx).With("plan", p.Name)
+    ctx = logging.WithLogger(ctx, logger)
+    if p.Feeder != nil {
+        ctx = feeder.WithFeeder(ctx, p.Feeder)
+    }
+    p.Configure(t)
+    deps, err := p.dependencies()
+    if err != nil {
+        return fmt.Errorf("plan %s: %s", p.Name, err.Error())
+    }
+    
     now := time.Now()
+    shared, err := task.ExecuteAll(ctx, p.Setup, t, p.URL, p.Vars)
+    defer func() {
+        teardownCtx, cancel := step.TeardownContext(ctx)
+        defer cancel()
+        if _, err := task.ExecuteAll(teardownCtx, p.Teardown, t, p.URL, shared); err != nil {
+            logger.Error("teardown failed", "err", err)
+        }
+    }()
+    if err != nil {
+        return fmt.Errorf("setup of plan %s failed: %s", p.Name, err.Error())
+    }
+    
+    timelines := make(map[string]*timeline, len(p.Steps))
+    for _, s := range p.Steps {
+        timelines[s.Name] = &timeline{done: make(chan struct{}), StepReport: metrics.StepReport{Name: s.Name, Petitions: s.Petitions()}}
+    }
+    var wg sync.WaitGroup
+    wg.Add(len(p.Steps))
     for _, s := range p.Steps {
-        s.Execute(t, p.URL)
+        go func(s *step.Step) {
+            defer wg.Done()
+            tl := timelines[s.Name]
+            defer close(tl.done)
+            for _, d := range deps[s.Name] {
+                dep := timelines[d.Name]
+                <-dep.done
+                if dep.Status != "OK" {
+                    tl.Status = "SKIPPED"
+                    return
+                }
+            }
+            timer := time.NewTimer(s.StartAfter)
+            defer timer.Stop()
+            select {
+            case <-timer.C:
+            case <-step.Stopping(ctx):
+            case <-ctx.Done():
+            }
+            if step.Draining(ctx) || ctx.Err() != nil {
+                tl.Status = "STOPPED"
+                return
+            }
+            tl.Start = time.Since(now)
+            if err := s.Execute(ctx, t, p.URL, shared); err != nil {
+                logger.Error("step aborted", "step", s.Name, "err", err)
+                tl.Status = "FAIL"
+            } else {
+                tl.Status = "OK"
+            }
+            tl.End = time.Since(now)
+        }(s)
+    }
+    wg.Wait()
+    
+    for _, s := range p.Steps {
+        metrics.ReportStep(ctx, timelines[s.Name].StepReport)
     }
     elapsed := time.Since(now)
-    logger.Println(fmt.Sprintf("Full Plan: %d", elapsed))
+    if step.Draining(ctx) || ctx.Err() != nil {
+        logger.Warn("plan interrupted")
+    }
+    logger.Info("plan finished", "duration", elapsed)
+    return nil
 }
@@ -0,0 +1,351 @@
+package plan
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+    
+    "github.com/jarlex/gommander/feeder"
+    "github.com/jarlex/gommander/request"
+    "github.com/jarlex/gommander/step"
+    "github.com/jarlex/gommander/task"
+)
+
+// server counts the petitions it receives by method and path, when the
+// first and last arrived, and the ones answered. POST /login hands a token,
+// /broken always fails, the paths under /slow answer after 30ms and /hang
+// until the petition is cancelled.
+type server struct {
+    *httptest.Server
+    mu       sync.Mutex
+    hits     map[string]int
+    answered map[string]int
+    first    map[string]time.Time
+    last     map[string]time.Time
+}
+
+func newServer(t *testing.T) *server {
+    s := &server{hits: make(map[string]int), answered: make(map[string]int), first: make(map[string]time.Time), last: make(map[string]time.Time)}
+    s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        key := r.Method + " " + r.URL.Path
+        now := time.Now()
+        s.mu.Lock()
+        s.hits[key]++
+        if s.first[key].IsZero() {
+            s.first[key] = now
+        }
+        s.last[key] = now
+        s.mu.Unlock()
+        switch {
+        case strings.HasPrefix(r.URL.Path, "/slow"):
+            time.Sleep(30 * time.Millisecond)
+        case r.URL.Path == "/hang":
+            select {
+            case <-r.Context().Done():
+                return
+            case <-time.After(time.Second):
+            }
+        }
+        s.mu.Lock()
+        s.answered[key]++
+        s.mu.Unlock()
+        w.Header().Set("Content-Type", "application/json")
+        switch r.URL.Path {
+        case "/login":
+            w.Write([]byte(`{"token": "abc"}`))
+        case "/broken":
+            w.WriteHeader(http.StatusInternalServerError)
+            w.Write([]byte(`{}`))
+        default:
+            w.Write([]byte(`{}`))
+        }
+    }))
+    t.Cleanup(s.Close)
+    return s
+}
+
+func (s *server) count(key string) int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.hits[key]
+}
+
+// times returns when the first and the last petition of key arrived.
+func (s *server) times(key string) (first, last time.Time) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.first[key], s.last[key]
+}
+
+func newTask(name, method, path string, status int, params ...string) *task.Task {
+    return &task.Task{
+        Name:           name,
+        ExpectedStatus: status,
+        Request:        &request.Request{Name: name, Method: method, Path: path, ParamsURL: params},
+    }
+}
+
+func TestExecuteHooks(t *testing.T) {
+    login := newTask("login", "POST", "/login", 200)
+    login.NextData = []string{"token"}
+    items := newTask("items", "GET", "/items/{{token}}", 200, "token")
+    logout := newTask("logout", "DELETE", "/sessions/{{token}}", 200, "token")
+    broken := newTask("broken", "POST", "/broken", 200)
+    cleanup := newTask("cleanup", "DELETE", "/fixtures", 200)
+    
+    tests := []struct {
+        name  string
+        plan  func() *Plan
+        wants map[string]int
+    }{
+        {
+            name: "setup vars reach every user",
+            plan: func() *Plan {
+                return &Plan{
+                    Setup:    []*task.Task{login},
+                    Teardown: []*task.Task{logout},
+                    Steps:    []*step.Step{{Name: "browse", ConcurrentUsers: 3, NumPetitions: 6, Tasks: []*task.Task{items}}},
+                }
+            },
+            wants: map[string]int{"POST /login": 1, "GET /items/abc": 6, "DELETE /sessions/abc": 1},
+        },
+        {
+            name: "teardown after a failed setup",
+            plan: func() *Plan {
+                return &Plan{
+                    Setup:    []*task.Task{login, broken},
+                    Teardown: []*task.Task{logout},
+                    Steps:    []*step.Step{{Name: "browse", ConcurrentUsers: 1, NumPetitions: 2, Tasks: []*task.Task{items}}},
+                }
+            },
+            wants: map[string]int{"POST /login": 1, "POST /broken": 1, "GET /items/abc": 0, "DELETE /sessions/abc": 1},
+        },
+        {
+            name: "step hooks",
+            plan: func() *Plan {
+                return &Plan{Steps: []*step.Step{{
+                    Name:            "browse",
+                    ConcurrentUsers: 2,
+                    NumPetitions:    4,
+                    Setup:           []*task.Task{login},
+                    Tasks:           []*task.Task{items},
+                    Teardown:        []*task.Task{logout},
+                }}}
+            },
+            wants: map[string]int{"POST /login": 1, "GET /items/abc": 4, "DELETE /sessions/abc": 1},
+        },
+        {
+            name: "failed step setup aborts the plan",
+            plan: func() *Plan {
+                return &Plan{
+                    Teardown: []*task.Task{cleanup},
+                    Steps: []*step.Step{
+                        {Name: "first", ConcurrentUsers: 1, NumPetitions: 1, Setup: []*task.Task{broken}, Tasks: []*task.Task{login}, Teardown: []*task.Task{cleanup}},
+                        {Name: "second", ConcurrentUsers: 1, NumPetitions: 1, Tasks: []*task.Task{login}},
+                    },
+                }
+            },
+            wants: map[string]int{"POST /broken": 1, "POST /login": 0, "DELETE /fixtures": 2},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            srv := newServer(t)
+            p := tt.plan()
+            p.URL = srv.URL
+            p.Execute(context.Background())
+            for key, want := range tt.wants {
+                if got := srv.count(key); got != want {
+                    t.Errorf("%s sent %d times, want %d", key, got, want)
+                }
+            }
+        })
+    }
+}
+
+func TestExecuteFeeder(t *testing.T) {
+    srv := newServer(t)
+    rows := []map[string]interface{}{{"user": "ann"}, {"user": "bob"}, {"user": "eve"}}
+    p := &Plan{
+        URL:    srv.URL,
+        Vars:   map[string]interface{}{"user": "default", "region": "eu"},
+        Feeder: feeder.New(rows),
+        Steps:  []*step.Step{{Name: "browse", ConcurrentUsers: 2, NumPetitions: 6, Tasks: []*task.Task{newTask("get", "GET", "/users/{{user}}/{{region}}", 200, "user", "region")}}},
+    }
+    p.Execute(context.Background())
+    for _, key := range []string{"GET /users/ann/eu", "GET /users/bob/eu", "GET /users/eve/eu"} {
+        if n := srv.count(key); n != 2 {
+            t.Errorf("%s sent %d times, want 2", key, n)
+        }
+    }
+    if n := srv.count("GET /users/default/eu"); n != 0 {
+        t.Errorf("the plan var was sent %d times over the feeder rows", n)
+    }
+}
+
+func TestExecuteGraph(t *testing.T) {
+    a := newTask("a", "GET", "/slow/a", 200)
+    b := newTask("b", "GET", "/slow/b", 200)
+    c := newTask("c", "GET", "/slow/c", 200)
+    broken := newTask("broken", "POST", "/broken", 200)
+    stp := func(name string, tsk *task.Task, dependsOn []string, after time.Duration) *step.Step {
+        return &step.Step{Name: name, ConcurrentUsers: 1, NumPetitions: 3, Tasks: []*task.Task{tsk}, DependsOn: dependsOn, StartAfter: after}
+    }
+    
+    t.Run("sequential", func(t *testing.T) {
+        srv := newServer(t)
+        p := &Plan{URL: srv.URL, Steps: []*step.Step{stp("a", a, nil, 0), stp("b", b, nil, 0)}}
+        p.Execute(context.Background())
+        _, lastA := srv.times("GET /slow/a")
+        firstB, _ := srv.times("GET /slow/b")
+        if !firstB.After(lastA) {
+            t.Errorf("b started before a finished")
+        }
+    })
+    t.Run("concurrent", func(t *testing.T) {
+        srv := newServer(t)
+        p := &Plan{URL: srv.URL, Steps: []*step.Step{stp("a", a, nil, 0), stp("b", b, []string{}, 0)}}
+        p.Execute(context.Background())
+        _, lastA := srv.times("GET /slow/a")
+        firstB, _ := srv.times("GET /slow/b")
+        if !firstB.Before(lastA) {
+            t.Errorf("b waited for a")
+        }
+    })
+    t.Run("dependsOn", func(t *testing.T) {
+        srv := newServer(t)
+        p := &Plan{URL: srv.URL, Steps: []*step.Step{stp("a", a, nil, 0), stp("b", b, []string{}, 0), stp("c", c, []string{"b"}, 0)}}
+        p.Execute(context.Background())
+        _, lastB := srv.times("GET /slow/b")
+        firstC, _ := srv.times("GET /slow/c")
+        if !firstC.After(lastB) {
+            t.Errorf("c started before b finished")
+        }
+        if srv.count("GET /slow/c") != 3 {
+            t.Errorf("c sent %d petitions, want 3", srv.count("GET /slow/c"))
+        }
+    })
+    t.Run("startAfter", func(t *testing.T) {
+        srv := newServer(t)
+        p := &Plan{URL: srv.URL, Steps: []*step.Step{stp("a", a, nil, 0), stp("b", b, []string{}, 100*time.Millisecond)}}
+        start := time.Now()
+        p.Execute(context.Background())
+        firstA, _ := srv.times("GET /slow/a")
+        firstB, _ := srv.times("GET /slow/b")
+        if firstB.Sub(start) < 100*time.Millisecond {
+            t.Errorf("b started %s after the plan, want 100ms or more", firstB.Sub(start))
+        }
+        if firstA.Sub(start) >= 100*time.Millisecond {
+            t.Errorf("a was delayed by the offset of b")
+        }
+    })
+    t.Run("skipped", func(t *testing.T) {
+        srv := newServer(t)
+        failing := stp("a", a, nil, 0)
+        failing.Setup = []*task.Task{broken}
+        p := &Plan{URL: srv.URL, Steps: []*step.Step{failing, stp("b", b, nil, 0), stp("c", c, []string{}, 0)}}
+        p.Execute(context.Background())
+        if n := srv.count("GET /slow/b"); n != 0 {
+            t.Errorf("b depends on a failed step and sent %d petitions", n)
+        }
+        if n := srv.count("GET /slow/c"); n != 3 {
+            t.Errorf("c does not depend on a and sent %d petitions, want 3", n)
+        }
+    })
+    t.Run("failing requests", func(t *testing.T) {
+        // Failing petitions are results, not a step that could not run
+        srv := newServer(t)
+        p := &Plan{URL: srv.URL, Steps: []*step.Step{stp("a", broken, nil, 0), stp("b", b, nil, 0)}}
+        p.Execute(context.Background())
+        if n := srv.count("POST /broken"); n != 3 {
+            t.Errorf("a sent %d petitions, want 3", n)
+        }
+        if n := srv.count("GET /slow/b"); n != 3 {
+            t.Errorf("b depends on a step whose requests failed and sent %d petitions, want 3", n)
+        }
+    })
+}
+
+func TestExecuteCancelled(t *testing.T) {
+    srv := newServer(t)
+    hang := newTask("hang", "GET", "/hang", 200)
+    cleanup := newTask("cleanup", "DELETE", "/fixtures", 200)
+    p := &Plan{
+        URL: srv.URL,
+        Steps: []*step.Step{
+            {Name: "load", ConcurrentUsers: 2, NumPetitions: 20, Tasks: []*task.Task{hang}, Teardown: []*task.Task{cleanup}},
+        },
+        Teardown: []*task.Task{cleanup},
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    time.AfterFunc(50*time.Millisecond, cancel)
+    start := time.Now()
+    p.Execute(ctx)
+    if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+        t.Errorf("the cancelled plan took %s", elapsed)
+    }
+    if n := srv.count("DELETE /fixtures"); n != 2 {
+        t.Errorf("teardown sent %d petitions after the cancellation, want the step and plan ones", n)
+    }
+}
+
+func TestExecuteDrain(t *testing.T) {
+    slow := newTask("slow", "GET", "/slow/a", 200)
+    hang := newTask("hang", "GET", "/hang", 200)
+    next := newTask("next", "GET", "/slow/next", 200)
+    cleanup := newTask("cleanup", "DELETE", "/fixtures", 200)
+    tests := []struct {
+        name     string
+        task     *task.Task
+        grace    time.Duration
+        answered bool // Whether the petitions in flight get their response
+    }{
+        {"in flight finish within the grace", slow, time.Second, true},
+        {"in flight cancelled after the grace", hang, 50 * time.Millisecond, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            srv := newServer(t)
+            p := &Plan{
+                URL: srv.URL,
+                Steps: []*step.Step{
+                    {Name: "load", ConcurrentUsers: 2, NumPetitions: 200, Tasks: []*task.Task{tt.task}},
+                    {Name: "next", ConcurrentUsers: 1, NumPetitions: 1, Tasks: []*task.Task{next}},
+                },
+                Teardown: []*task.Task{cleanup},
+            }
+            stop := make(chan struct{})
+            time.AfterFunc(50*time.Millisecond, func() { close(stop) })
+            start := time.Now()
+            p.Execute(step.WithDrain(context.Background(), stop, tt.grace))
+            if elapsed := time.Since(start); elapsed > time.Second+500*time.Millisecond {
+                t.Errorf("the drained plan took %s", elapsed)
+            }
+            
+            key := tt.task.Request.Method + " " + tt.task.Request.Path
+            sent := srv.count(key)
+            if sent == 0 || sent >= 200 {
+                t.Fatalf("%d petitions sent, want some but not all", sent)
+            }
+            srv.mu.Lock()
+            answered := srv.answered[key]
+            srv.mu.Unlock()
+            if tt.answered && answered != sent {
+                t.Errorf("%d of %d petitions in flight answered, want all", answered, sent)
+            }
+            if !tt.answered && answered != 0 {
+                t.Errorf("%d petitions answered after the grace, want none", answered)
+            }
+            if n := srv.count("GET /slow/next"); n != 0 {
+                t.Errorf("a step started after the drain sent %d petitions", n)
+            }
+            if n := srv.count("DELETE /fixtures"); n != 1 {
+                t.Errorf("teardown sent %d petitions, want 1", n)
+            }
+        })
+    }
+}
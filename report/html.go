@@ -0,0 +1,238 @@
+package report
+
+import (
+    "fmt"
+    "html/template"
+    "io"
+    "sort"
+    "strings"
+    "time"
+    
+    "github.com/jarlex/gommander/metrics"
+)
+
+// palette colors the tasks of the charts.
+var palette = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+const (
+    chartWidth  = 640
+    chartHeight = 220
+    chartLeft   = 60 // Room for the y labels
+    chartBottom = 30 // Room for the x labels
+)
+
+// HTML writes res as a single HTML page: the plan metadata, then for each
+// step its latency and throughput charts, percentile table and errors.
+func HTML(w io.Writer, res *metrics.Result) error {
+    return page.Execute(w, res)
+}
+
+var page = template.Must(template.New("report").Funcs(template.FuncMap{
+    "dur":        fmtDuration,
+    "latency":    latencyChart,
+    "throughput": throughputChart,
+    "errors":     sortedErrors,
+    "join": func(names []string) string {
+        if len(names) == 0 {
+            return "-"
+        }
+        return strings.Join(names, ", ")
+    },
+    "date": func(t time.Time) string { return t.Format("2006-01-02 15:04:05 MST") },
+    "status": func(res *metrics.Result) string {
+        if res.Failed() {
+            return "FAILED"
+        }
+        return "PASSED"
+    },
+}).Parse(pageTemplate))
+
+// line is a series of a chart.
+type line struct {
+    name   string
+    color  string
+    points [][2]float64 // Seconds from the run start, value
+}
+
+func latencyChart(res *metrics.Result, s *metrics.StepResult) template.HTML {
+    var lines []line
+    for i, t := range s.Tasks {
+        mean := line{name: t.Name + " mean", color: palette[i%len(palette)]}
+        p95 := line{name: t.Name + " p95", color: palette[i%len(palette)] + "88"}
+        for _, p := range t.Series {
+            if p.Count == p.Failures {
+                continue
+            }
+            x := p.Time.Sub(res.Start).Seconds()
+            mean.points = append(mean.points, [2]float64{x, float64(p.Mean) / float64(time.Millisecond)})
+            p95.points = append(p95.points, [2]float64{x, float64(p.P95) / float64(time.Millisecond)})
+        }
+        lines = append(lines, mean, p95)
+    }
+    return chart("Latency over time", "ms", lines)
+}
+
+func throughputChart(res *metrics.Result, s *metrics.StepResult) template.HTML {
+    var lines []line
+    for i, t := range s.Tasks {
+        l := line{name: t.Name, color: palette[i%len(palette)]}
+        for _, p := range t.Series {
+            l.points = append(l.points, [2]float64{p.Time.Sub(res.Start).Seconds(), p.Rate})
+        }
+        lines = append(lines, l)
+    }
+    return chart("Throughput over time", "req/s", lines)
+}
+
+// chart draws lines as an SVG line chart, x in seconds.
+func chart(title, unit string, lines []line) template.HTML {
+    minX, maxX, maxY := -1.0, 0.0, 0.0
+    for _, l := range lines {
+        for _, p := range l.points {
+            if minX < 0 || p[0] < minX {
+                minX = p[0]
+            }
+            if p[0] > maxX {
+                maxX = p[0]
+            }
+            if p[1] > maxY {
+                maxY = p[1]
+            }
+        }
+    }
+    if minX < 0 {
+        return template.HTML(fmt.Sprintf(`<p class="empty">%s: no samples</p>`, template.HTMLEscapeString(title)))
+    }
+    if maxX == minX {
+        maxX = minX + 1
+    }
+    if maxY == 0 {
+        maxY = 1
+    }
+    maxY *= 1.1
+    plotW := float64(chartWidth - chartLeft - 10)
+    plotH := float64(chartHeight - chartBottom - 10)
+    x := func(v float64) float64 { return chartLeft + (v-minX)/(maxX-minX)*plotW }
+    y := func(v float64) float64 { return 10 + plotH - v/maxY*plotH }
+    
+    var b strings.Builder
+    fmt.Fprintf(&b, `<figure><figcaption>%s</figcaption><svg viewBox="0 0 %d %d" width="%d" height="%d">`,
+        template.HTMLEscapeString(title), chartWidth, chartHeight, chartWidth, chartHeight)
+    for i := 0; i <= 4; i++ {
+        v := maxY * float64(i) / 4
+        fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" class="grid"/>`, chartLeft, y(v), chartWidth-10, y(v))
+        fmt.Fprintf(&b, `<text x="%d" y="%.1f" class="ylabel">%.3g</text>`, chartLeft-6, y(v)+4, v)
+    }
+    fmt.Fprintf(&b, `<text x="%d" y="%d" class="xlabel">%.0fs</text>`, chartLeft, chartHeight-8, minX)
+    fmt.Fprintf(&b, `<text x="%d" y="%d" class="xlabel end">%.0fs</text>`, chartWidth-10, chartHeight-8, maxX)
+    fmt.Fprintf(&b, `<text x="%d" y="%d" class="unit">%s</text>`, 4, 14, template.HTMLEscapeString(unit))
+    for _, l := range lines {
+        if len(l.points) == 0 {
+            continue
+        }
+        var pts []string
+        for _, p := range l.points {
+            pts = append(pts, fmt.Sprintf("%.1f,%.1f", x(p[0]), y(p[1])))
+        }
+        fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"><title>%s</title></polyline>`,
+            l.color, strings.Join(pts, " "), template.HTMLEscapeString(l.name))
+    }
+    b.WriteString(`</svg><div class="legend">`)
+    for _, l := range lines {
+        fmt.Fprintf(&b, `<span><i style="background:%s"></i>%s</span>`, l.color, template.HTMLEscapeString(l.name))
+    }
+    b.WriteString(`</div></figure>`)
+    return template.HTML(b.String())
+}
+
+// fmtDuration rounds d for display.
+func fmtDuration(d time.Duration) string {
+    switch {
+    case d >= time.Second:
+        return d.Round(10 * time.Millisecond).String()
+    case d >= time.Millisecond:
+        return d.Round(100 * time.Microsecond).String()
+    }
+    return d.Round(time.Microsecond).String()
+}
+
+type errorCount struct {
+    Task    string
+    Message string
+    Count   int
+}
+
+// sortedErrors returns the errors of the step, most frequent first.
+func sortedErrors(s *metrics.StepResult) []errorCount {
+    var errs []errorCount
+    for _, t := range s.Tasks {
+        for msg, n := range t.Errors {
+            errs = append(errs, errorCount{Task: t.Name, Message: msg, Count: n})
+        }
+    }
+    sort.Slice(errs, func(i, j int) bool {
+        if errs[i].Count != errs[j].Count {
+            return errs[i].Count > errs[j].Count
+        }
+        return errs[i].Task+errs[i].Message < errs[j].Task+errs[j].Message
+    })
+    return errs
+}
+
+const pageTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>gommander report: {{.Plan}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 span { font-size: .6em; padding: .2em .5em; border-radius: 4px; color: #fff; vertical-align: middle; }
+.PASSED { background: #2ca02c; } .FAILED { background: #d62728; }
+td span { padding: .1em .4em; border-radius: 4px; color: #fff; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ddd; padding: .3em .7em; text-align: right; }
+th:first-child, td:first-child, td.text { text-align: left; }
+th { background: #f4f4f4; }
+figure { display: inline-block; margin: 0 1em 1em 0; }
+figcaption { font-weight: bold; margin-bottom: .3em; }
+svg .grid { stroke: #eee; } svg text { font-size: 11px; fill: #666; }
+svg .ylabel { text-anchor: end; } svg .end { text-anchor: end; }
+.legend span { margin-right: 1em; font-size: .85em; } .legend i { display: inline-block; width: 12px; height: 3px; margin-right: .3em; vertical-align: middle; }
+.empty, .note { color: #888; }
+</style>
+</head>
+<body>
+<h1>{{.Plan}} <span class="{{status .}}">{{status .}}</span></h1>
+<table>
+<tr><td>Type</td><td class="text">{{or .Type "-"}}</td></tr>
+<tr><td>URL</td><td class="text">{{or .URL "-"}}</td></tr>
+<tr><td>Start</td><td class="text">{{date .Start}}</td></tr>
+<tr><td>Duration</td><td class="text">{{dur .Duration}}</td></tr>
+<tr><td>Interrupted</td><td class="text">{{.Interrupted}}</td></tr>
+</table>
+<h2>Steps</h2>
+<table>
+<tr><th>Step</th><th>Status</th><th>Users</th><th>Depends on</th><th>Start</th><th>End</th><th>Petitions</th><th>Failed</th></tr>
+{{range .Steps}}<tr><td>{{.Name}}</td><td>{{.Status}}</td><td>{{.Users}}</td><td class="text">{{join .DependsOn}}</td><td>{{dur .Start}}</td><td>{{dur .End}}</td><td>{{.Petitions}}/{{.Planned}}</td><td>{{.Failed}}</td></tr>
+{{end}}</table>
+{{with .Thresholds}}<h2>Thresholds</h2>
+<table>
+<tr><th>Threshold</th><th>Step</th><th>Task</th><th>Value</th><th>Result</th></tr>
+{{range .}}<tr><td>{{.Threshold}}</td><td class="text">{{or .Step "-"}}</td><td class="text">{{or .Task "-"}}</td><td>{{or .Value "not run"}}</td><td class="text">{{if .Passed}}<span class="PASSED">PASSED</span>{{else}}<span class="FAILED">FAILED</span>{{end}}</td></tr>
+{{end}}</table>{{end}}
+{{$res := .}}{{range .Steps}}
+<h2>Step {{.Name}}</h2>
+{{latency $res .}}
+{{throughput $res .}}
+<table>
+<tr><th>Task</th><th>Count</th><th>Failures</th><th>Min</th><th>Mean</th><th>p50</th><th>p90</th><th>p95</th><th>p99</th><th>Max</th><th>Req/s</th></tr>
+{{range .Tasks}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{.Failures}}</td><td>{{dur .Min}}</td><td>{{dur .Mean}}</td><td>{{dur .P50}}</td><td>{{dur .P90}}</td><td>{{dur .P95}}</td><td>{{dur .P99}}</td><td>{{dur .Max}}</td><td>{{printf "%.1f" .Throughput}}</td></tr>
+{{end}}</table>
+{{with errors .}}<table>
+<tr><th>Task</th><th>Error</th><th>Count</th></tr>
+{{range .}}<tr><td>{{.Task}}</td><td class="text">{{.Message}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{else}}<p class="note">No errors.</p>{{end}}
+{{end}}
+</body>
+</html>
+`
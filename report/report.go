@@ -0,0 +1,76 @@
+// Package report writes the result of a run as a JSON document or as a
+// self-contained HTML page, charts included, readable offline.
+package report
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "strings"
+    
+    "github.com/jarlex/gommander/metrics"
+)
+
+// Formats of the reports.
+const (
+    FormatJSON = "json"
+    FormatHTML = "html"
+)
+
+// Output is a report to write, parsed from "format=path".
+type Output struct {
+    Format string
+    Path   string
+}
+
+// ParseOutput parses "format=path", json or html.
+func ParseOutput(s string) (Output, error) {
+    eq := strings.Index(s, "=")
+    if eq <= 0 || eq == len(s)-1 {
+        return Output{}, fmt.Errorf("--out %s must be format=path", s)
+    }
+    o := Output{Format: s[:eq], Path: s[eq+1:]}
+    if o.Format != FormatJSON && o.Format != FormatHTML {
+        return Output{}, fmt.Errorf("unknown report format %s, use %s or %s", o.Format, FormatJSON, FormatHTML)
+    }
+    return o, nil
+}
+
+// Write writes res to the file of o.
+func (o Output) Write(res *metrics.Result) error {
+    f, err := os.Create(o.Path)
+    if err != nil {
+        return err
+    }
+    if o.Format == FormatHTML {
+        err = HTML(f, res)
+    } else {
+        err = JSON(f, res)
+    }
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    return err
+}
+
+// Load reads a result written as JSON.
+func Load(path string) (*metrics.Result, error) {
+    raw, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    res := &metrics.Result{}
+    if err := json.Unmarshal(raw, res); err != nil {
+        return nil, fmt.Errorf("%s: %s", path, err.Error())
+    }
+    return res, nil
+}
+
+// JSON writes res as an indented JSON document.
+func JSON(w io.Writer, res *metrics.Result) error {
+    enc := json.NewEncoder(w)
+    enc.SetIndent("", "  ")
+    return enc.Encode(res)
+}
@@ -0,0 +1,184 @@
+package report
+
+import (
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+    
+    "github.com/jarlex/gommander/metrics"
+)
+
+func result() *metrics.Result {
+    start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+    c := metrics.NewCollector()
+    for i := 0; i < 3; i++ {
+        at := start.Add(time.Duration(i) * time.Second)
+        c.Report(metrics.Sample{Time: at, Step: "browse", Task: "list", Duration: 20 * time.Millisecond})
+        c.Report(metrics.Sample{Time: at, Step: "browse", Duration: 30 * time.Millisecond})
+    }
+    c.Report(metrics.Sample{Time: start, Step: "browse", Task: "<item>", Err: errors.New("Status not expected")})
+    c.ReportStep(metrics.StepReport{Name: "browse", Status: "OK", End: 3 * time.Second})
+    res := c.Result()
+    res.Plan = "shop"
+    res.Type = "load"
+    res.URL = "http://shop.test"
+    res.Steps[0].Users = 2
+    res.Steps[0].Planned = 4
+    res.Steps[0].DependsOn = []string{"login"}
+    res.Start = start
+    res.Duration = 3 * time.Second
+    return res
+}
+
+func TestParseOutput(t *testing.T) {
+    tests := []struct {
+        in   string
+        want Output
+        err  string
+    }{
+        {in: "json=out/result.json", want: Output{Format: FormatJSON, Path: "out/result.json"}},
+        {in: "html=report.html", want: Output{Format: FormatHTML, Path: "report.html"}},
+        {in: "report.html", err: "must be format=path"},
+        {in: "html=", err: "must be format=path"},
+        {in: "=report.html", err: "must be format=path"},
+        {in: "pdf=report.pdf", err: "unknown report format pdf"},
+    }
+    for _, tt := range tests {
+        got, err := ParseOutput(tt.in)
+        if tt.err != "" {
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Errorf("%s: error %v, want %q", tt.in, err, tt.err)
+            }
+            continue
+        }
+        if err != nil || got != tt.want {
+            t.Errorf("%s: %+v, %v, want %+v", tt.in, got, err, tt.want)
+        }
+    }
+}
+
+func TestJSON(t *testing.T) {
+    var b strings.Builder
+    if err := JSON(&b, result()); err != nil {
+        t.Fatal(err)
+    }
+    var back metrics.Result
+    if err := json.Unmarshal([]byte(b.String()), &back); err != nil {
+        t.Fatal(err)
+    }
+    list := back.Step("browse").Task("list")
+    if back.Plan != "shop" || list == nil || list.Count != 3 || len(list.Series) != 3 {
+        t.Errorf("decoded %+v, list %+v", back, list)
+    }
+}
+
+func TestHTML(t *testing.T) {
+    var b strings.Builder
+    if err := HTML(&b, result()); err != nil {
+        t.Fatal(err)
+    }
+    page := b.String()
+    for _, want := range []string{
+        "<title>gommander report: shop</title>",
+        `<span class="PASSED">PASSED</span>`,
+        "<td>Start</td><td class=\"text\">2020-01-01 00:00:00 UTC</td>",
+        "<tr><td>Type</td><td class=\"text\">load</td></tr>",
+        "<tr><td>URL</td><td class=\"text\">http://shop.test</td></tr>",
+        "<tr><td>browse</td><td>OK</td><td>2</td><td class=\"text\">login</td><td>0s</td><td>3s</td><td>3/4</td><td>0</td></tr>",
+        "<figcaption>Latency over time</figcaption>",
+        "<figcaption>Throughput over time</figcaption>",
+        `<polyline fill="none" stroke="#1f77b4"`,
+        "<title>list mean</title>",
+        "<tr><td>&lt;item&gt;</td><td class=\"text\">Status not expected</td><td>1</td></tr>",
+    } {
+        if !strings.Contains(page, want) {
+            t.Errorf("page misses %s", want)
+        }
+    }
+    if strings.Contains(page, "<item>") {
+        t.Error("task name not escaped")
+    }
+}
+
+func TestHTMLThresholds(t *testing.T) {
+    res := result()
+    thresholds := []metrics.Threshold{
+        {Metric: "p95", Task: "list", Max: "25ms"},
+        {Metric: "errorRate", Step: "checkout", Max: "1%"},
+    }
+    if err := res.CheckThresholds(thresholds); err != nil {
+        t.Fatal(err)
+    }
+    var b strings.Builder
+    if err := HTML(&b, res); err != nil {
+        t.Fatal(err)
+    }
+    page := b.String()
+    for _, want := range []string{
+        `<h1>shop <span class="FAILED">FAILED</span></h1>`,
+        "<h2>Thresholds</h2>",
+        `<tr><td>p95 &lt;= 25ms</td><td class="text">browse</td><td class="text">list</td><td>20ms</td><td class="text"><span class="PASSED">PASSED</span></td></tr>`,
+        `<tr><td>errorRate &lt;= 1%</td><td class="text">checkout</td><td class="text">-</td><td>not run</td><td class="text"><span class="FAILED">FAILED</span></td></tr>`,
+    } {
+        if !strings.Contains(page, want) {
+            t.Errorf("page misses %s", want)
+        }
+    }
+    
+    b.Reset()
+    if err := HTML(&b, result()); err != nil {
+        t.Fatal(err)
+    }
+    if strings.Contains(b.String(), "Thresholds") {
+        t.Error("thresholds section without thresholds")
+    }
+}
+
+func TestOutputWrite(t *testing.T) {
+    dir := t.TempDir()
+    for _, format := range []string{FormatJSON, FormatHTML} {
+        o := Output{Format: format, Path: filepath.Join(dir, "report."+format)}
+        if err := o.Write(result()); err != nil {
+            t.Fatal(err)
+        }
+        b, err := os.ReadFile(o.Path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if format == FormatHTML && !strings.HasPrefix(string(b), "<!DOCTYPE html>") ||
+            format == FormatJSON && !strings.HasPrefix(string(b), "{") {
+            t.Errorf("%s report starts with %.20q", format, b)
+        }
+    }
+    if err := (Output{Format: FormatJSON, Path: filepath.Join(dir, "missing", "r.json")}).Write(result()); err == nil {
+        t.Error("wrote into a missing directory")
+    }
+}
+
+func TestLoad(t *testing.T) {
+    dir := t.TempDir()
+    o := Output{Format: FormatJSON, Path: filepath.Join(dir, "result.json")}
+    if err := o.Write(result()); err != nil {
+        t.Fatal(err)
+    }
+    res, err := Load(o.Path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if list := res.Step("browse").Task("list"); res.Plan != "shop" || len(list.Latencies) != 3 {
+        t.Errorf("loaded %+v", res)
+    }
+    
+    bad := filepath.Join(dir, "bad.json")
+    os.WriteFile(bad, []byte("{"), 0644)
+    if _, err := Load(bad); err == nil || !strings.HasPrefix(err.Error(), bad+": ") {
+        t.Errorf("Load() = %v, want an error naming the file", err)
+    }
+    if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+        t.Error("loaded a missing file")
+    }
+}
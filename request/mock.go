@@ -0,0 +1,96 @@
+package request
+
+import (
+    "fmt"
+    "math/rand"
+    "time"
+)
+
+// Mock is the response gommander mock answers the request with. The strings
+// of Body, RawBody and the header values are text/template templates, see
+// the mock package.
+type Mock struct {
+    Status      int               `json:"status,omitempty"`      // 200 by default
+    Headers     map[string]string `json:"headers,omitempty"`     // Response headers
+    Body        interface{}       `json:"body,omitempty"`        // JSON body
+    RawBody     string            `json:"rawBody,omitempty"`     // Sent as is instead of body when set
+    Latency     *Latency          `json:"latency,omitempty"`     // Delay before answering
+    ErrorRate   float64           `json:"errorRate,omitempty"`   // Share of petitions failing, from 0 to 1
+    ErrorStatus int               `json:"errorStatus,omitempty"` // Status of the failing petitions, 500 by default
+}
+
+// Latency is a distribution of response times: fixed at mean, uniform
+// between min and max, normal around mean with stdDev or exponential with
+// mean. min and max bound every distribution.
+type Latency struct {
+    Distribution string `json:"distribution,omitempty"` // fixed by default, uniform, normal or exponential
+    Mean         string `json:"mean,omitempty"`
+    StdDev       string `json:"stdDev,omitempty"`
+    Min          string `json:"min,omitempty"`
+    Max          string `json:"max,omitempty"`
+    mean         time.Duration
+    stdDev       time.Duration
+    min          time.Duration
+    max          time.Duration
+}
+
+// parse checks the mock and parses its durations.
+func (m *Mock) parse() error {
+    if m.ErrorRate < 0 || m.ErrorRate > 1 {
+        return fmt.Errorf("errorRate %v is not between 0 and 1", m.ErrorRate)
+    }
+    if m.Latency == nil {
+        return nil
+    }
+    l := m.Latency
+    for _, d := range []struct {
+        name  string
+        value string
+        to    *time.Duration
+    }{
+        {"mean", l.Mean, &l.mean},
+        {"stdDev", l.StdDev, &l.stdDev},
+        {"min", l.Min, &l.min},
+        {"max", l.Max, &l.max},
+    } {
+        if d.value == "" {
+            continue
+        }
+        var err error
+        if *d.to, err = time.ParseDuration(d.value); err != nil {
+            return fmt.Errorf("latency %s: %s", d.name, err.Error())
+        }
+    }
+    switch l.Distribution {
+    case "", "fixed", "normal", "exponential":
+    case "uniform":
+        if l.max < l.min {
+            return fmt.Errorf("latency max %s is below min %s", l.Max, l.Min)
+        }
+    default:
+        return fmt.Errorf("unknown latency distribution %s, use fixed, uniform, normal or exponential", l.Distribution)
+    }
+    return nil
+}
+
+// Delay returns a response time drawn from the distribution.
+func (l *Latency) Delay(rnd *rand.Rand) time.Duration {
+    var d time.Duration
+    switch l.Distribution {
+    case "uniform":
+        d = l.min + time.Duration(rnd.Int63n(int64(l.max-l.min)+1))
+    case "normal":
+        d = l.mean + time.Duration(rnd.NormFloat64()*float64(l.stdDev))
+    case "exponential":
+        d = time.Duration(rnd.ExpFloat64() * float64(l.mean))
+    default:
+        d = l.mean
+    }
+    if d < l.min {
+        d = l.min
+    }
+    if l.max > 0 && d > l.max {
+        d = l.max
+    }
+    return d
+}
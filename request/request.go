@@ -1,15 +1,19 @@
 package request
 
 import (
+    "context"
     "encoding/json"
     "errors"
     "fmt"
     "io/ioutil"
-    "log"
-    "os"
+    "net/http"
+    "strconv"
     "strings"
     "time"
     
+    "github.com/jarlex/gommander/debug"
+    "github.com/jarlex/gommander/strict"
+    "github.com/jarlex/gommander/tracing"
     "github.com/jarlex/transporter"
 )
 
@@ -18,69 +22,178 @@ type Request struct {
     Method     string                 `json:"method"`
     URL        string                 `json:"url"`
     Path       string                 `json:"path"`
-    ParamsURL  []string               `json:"paramsURL"`
-    ParamsBody []string               `json:"ParamsBody"`
+    ParamsURL  []string               `json:"paramsURL,omitempty"`
+    ParamsBody []string               `json:"paramsBody,omitempty"`
     Body       map[string]interface{} `json:"body"`
+    Headers    map[string]string      `json:"headers,omitempty"` // Sent with every petition, over the plan ones
+    RawBody    string                 `json:"rawBody,omitempty"` // Sent as is instead of body when set
+    Mock       *Mock                  `json:"mock,omitempty"`    // Response of gommander mock
+}
+
+// LegacyKeys maps the keys still accepted for backward compatibility to
+// their current spelling.
+var LegacyKeys = map[string]string{"ParamsBody": "paramsBody"}
+
+// MarshalJSON leaves body out when nil, an empty object is kept as it is
+// sent as {} instead of null.
+func (r Request) MarshalJSON() ([]byte, error) {
+    type plain Request
+    out := struct {
+        plain
+        Body *map[string]interface{} `json:"body,omitempty"`
+    }{plain: plain(r)}
+    if r.Body != nil {
+        out.Body = &r.Body
+    }
+    return json.Marshal(out)
 }
 
 func Read(filePath string) (*Request, error) {
-    logger := log.New(os.Stdout, "", 0)
     raw, err := ioutil.ReadFile(filePath)
-    
     if err != nil {
-        logger.Fatalf("Error reading the file in %s: %s", filePath, err.Error())
-        return nil, err
+        return nil, fmt.Errorf("Error reading the file in %s: %s", filePath, err.Error())
     }
-    
+    return Parse(raw)
+}
+
+// Parse decodes a request definition, rejecting the unknown keys.
+func Parse(raw []byte) (*Request, error) {
     var r Request
-    json.Unmarshal(raw, &r)
+    if err := strict.Unmarshal(raw, &r, LegacyKeys); err != nil {
+        return nil, err
+    }
+    if r.Mock != nil {
+        if err := r.Mock.parse(); err != nil {
+            return nil, fmt.Errorf("mock: %s", err.Error())
+        }
+    }
     return &r, nil
 }
 
-func (r *Request) Execute(tg *transporter.Transporter, base string, callData map[string]interface{}) (map[string]interface{}, int, time.Duration, error) {
-    var respJSON map[string]interface{}
+// Response is what a petition got back.
+type Response struct {
+    Status   int
+    Header   http.Header
+    Body     []byte
+    Duration time.Duration
+}
+
+// JSON returns the body decoded as JSON, nil when it is not JSON.
+func (r *Response) JSON() interface{} {
+    var v interface{}
+    if json.Unmarshal(r.Body, &v) != nil {
+        return nil
+    }
+    return v
+}
+
+// Fields returns the fields of a JSON object body, nil for other bodies.
+func (r *Response) Fields() map[string]interface{} {
+    fields, _ := r.JSON().(map[string]interface{})
+    return fields
+}
+
+// Execute sends the request with callData filled in. Cancelling ctx aborts
+// the request in flight.
+func (r *Request) Execute(ctx context.Context, tg *transporter.Transporter, base string, callData map[string]interface{}) (*Response, error) {
+    directedTg, req, missing, err := r.render(tg, callData)
+    if err != nil {
+        return nil, err
+    }
+    if len(missing) != 0 {
+        return nil, errors.New("Necesary param not present")
+    }
+    ctx, span := tracing.Start(ctx, "HTTP "+req.Method, tracing.KindClient)
+    span.SetAttribute("http.method", req.Method)
+    span.SetAttribute("http.url", req.URL.String())
+    tracing.Inject(ctx, req.Header)
+    now := time.Now()
+    var body string
+    resp, err := directedTg.Do(req.WithContext(ctx), &body, &body)
+    elapsed := time.Since(now)
+    debug.Record(ctx, req, resp, []byte(body), elapsed)
+    if err != nil {
+        err = fmt.Errorf("Architecture Error: %s", err.Error())
+        span.End(err)
+        return nil, err
+    }
+    span.SetAttribute("http.status_code", resp.StatusCode)
+    span.SetAttribute("gommander.bytes", len(body))
+    span.End(nil)
     
+    return &Response{Status: resp.StatusCode, Header: resp.Header, Body: []byte(body), Duration: elapsed}, nil
+}
+
+// Render returns the petition Execute would send with callData, without
+// sending it. The params missing from callData are returned and left as
+// {{name}} in the path and the body.
+func (r *Request) Render(tg *transporter.Transporter, callData map[string]interface{}) (*http.Request, []string, error) {
+    _, req, missing, err := r.render(tg, callData)
+    return req, missing, err
+}
+
+func (r *Request) render(tg *transporter.Transporter, callData map[string]interface{}) (*transporter.Transporter, *http.Request, []string, error) {
     directedTg := tg.New()
     // If not Plan URL the task URL is the final path
     if r.URL != "" {
         directedTg = directedTg.Base(r.URL)
     }
     
+    var missing []string
     finalPath := r.Path
     // Complete request info
-    if len(r.ParamsURL) != 0 {
-        for _, param := range r.ParamsURL {
-            var callDataFinal string
-            switch callData[param].(type) {
-            case float64:
-                callDataFinal = fmt.Sprintf("%f", callData[param].(float64))
-            case string:
-                callDataFinal = callData[param].(string)
-            }
-            finalRemplace := fmt.Sprintf("{{%s}}", param)
-            if callDataFinal == "" {
-                return nil, -1, -1, errors.New("Necesary param not present")
-            }
-            finalPath = strings.Replace(r.Path, finalRemplace, callDataFinal, 1)
+    for _, param := range r.ParamsURL {
+        value := paramString(callData[param])
+        if value == "" {
+            missing = append(missing, param)
+            continue
         }
+        finalPath = strings.Replace(finalPath, fmt.Sprintf("{{%s}}", param), value, 1)
     }
     
+    // The body is shared by the users, fill in a copy
+    body := r.Body
     if len(r.ParamsBody) != 0 {
+        body = make(map[string]interface{}, len(r.Body)+len(r.ParamsBody))
+        for k, v := range r.Body {
+            body[k] = v
+        }
         for _, param := range r.ParamsBody {
-            if callData[param] == "" {
-                return nil, -1, -1, errors.New("Necesary param not present")
+            value, ok := callData[param]
+            if !ok || value == "" {
+                missing = append(missing, param)
+                value = fmt.Sprintf("{{%s}}", param)
             }
-            r.Body[param] = callData[param]
+            body[param] = value
         }
     }
     
-    errorJSON := transporter.JSONError{}
-    now := time.Now()
-    resp, err := directedTg.Path(finalPath).Method(r.Method).BodyJSON(r.Body).Receive(&respJSON, &errorJSON)
+    directedTg = directedTg.Path(finalPath).Method(r.Method)
+    if r.RawBody != "" {
+        directedTg = directedTg.Body(strings.NewReader(r.RawBody))
+    } else if body != nil {
+        // A nil map would be sent as null
+        directedTg = directedTg.BodyJSON(body)
+    }
+    for k, v := range r.Headers {
+        directedTg = directedTg.Set(k, v)
+    }
+    req, err := directedTg.Request()
     if err != nil {
-        return nil, -1, -1, fmt.Errorf("Architecture Error: %s", err.Error())
+        return nil, nil, nil, fmt.Errorf("Architecture Error: %s", err.Error())
     }
-    
-    elapsed := time.Since(now)
-    return respJSON, resp.StatusCode, elapsed, nil
+    return directedTg, req, missing, nil
+}
+
+// paramString formats a path param, empty when it has no usable value.
+func paramString(v interface{}) string {
+    switch val := v.(type) {
+    case string:
+        return val
+    case float64:
+        return strconv.FormatFloat(val, 'f', -1, 64)
+    case int:
+        return strconv.Itoa(val)
+    }
+    return ""
 }
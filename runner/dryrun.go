@@ -0,0 +1,153 @@
+package runner
+
+import (
+    "encoding/base64"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "regexp"
+    "sort"
+    "strings"
+    
+    "github.com/jarlex/gommander/plan"
+    "github.com/jarlex/gommander/secret"
+    "github.com/jarlex/gommander/task"
+    "github.com/jarlex/transporter"
+)
+
+// Formats of DryRun.
+const (
+    FormatCurl = "curl"
+    FormatHTTP = "http"
+)
+
+// unresolved matches the params left in a rendered petition.
+var unresolved = regexp.MustCompile(`{{[^{}]+}}`)
+
+// DryRun writes the petitions of p, in the order one virtual user sends
+// them, without sending them: the plan setup, then for each step its setup,
+// one pass of its tasks and its teardown, then the plan teardown. Each
+// petition is rendered with the plan vars, the first row of the feeder for
+// the tasks of the steps and the values the tasks extract stubbed as
+// $task.field, as a curl command or HTTP text. The params no
+// value was found for stay as {{name}}, in red when color is set, and are
+// listed after the petition.
+func DryRun(p *plan.Plan, w io.Writer, format string, color bool) error {
+    if format != FormatCurl && format != FormatHTTP {
+        return fmt.Errorf("unknown dry run format %s, use %s or %s", format, FormatCurl, FormatHTTP)
+    }
+    secret.Register(p.AuthPass)
+    if p.AuthType == "basic" {
+        secret.Register(base64.StdEncoding.EncodeToString([]byte(p.AuthUser + ":" + p.AuthPass)))
+    }
+    w = secret.Writer(w)
+    t := transporter.New()
+    p.Configure(t)
+    
+    d := &dryRun{w: w, t: t, format: format, color: color, vars: make(map[string]interface{})}
+    for k, v := range p.Vars {
+        d.vars[k] = v
+    }
+    d.tasks("plan setup", p.Setup)
+    for _, s := range p.Steps {
+        d.tasks("step "+s.Name+" setup", s.Setup)
+        for k, v := range p.Feeder.Row(0) {
+            d.vars[k] = v
+        }
+        d.tasks("step "+s.Name, s.Tasks)
+        d.tasks("step "+s.Name+" teardown", s.Teardown)
+    }
+    d.tasks("plan teardown", p.Teardown)
+    return d.err
+}
+
+type dryRun struct {
+    w      io.Writer
+    t      *transporter.Transporter
+    format string
+    color  bool
+    vars   map[string]interface{}
+    err    error
+}
+
+func (d *dryRun) tasks(stage string, tasks []*task.Task) {
+    for _, tsk := range tasks {
+        if d.err != nil {
+            return
+        }
+        req, missing, err := tsk.Request.Render(d.t, d.vars)
+        if err != nil {
+            d.err = fmt.Errorf("%s: task %s: %s", stage, tsk.Name, err.Error())
+            return
+        }
+        text, err := d.render(req)
+        if err != nil {
+            d.err = fmt.Errorf("%s: task %s: %s", stage, tsk.Name, err.Error())
+            return
+        }
+        var out strings.Builder
+        fmt.Fprintf(&out, "# %s: task %s, request %s\n", stage, tsk.Name, tsk.Request.Name)
+        out.WriteString(text)
+        if len(missing) != 0 {
+            fmt.Fprintf(&out, "# unresolved: %s\n", strings.Join(missing, ", "))
+        }
+        out.WriteString("\n")
+        if _, d.err = io.WriteString(d.w, out.String()); d.err != nil {
+            return
+        }
+        for _, field := range tsk.NextData {
+            d.vars[field] = "$" + tsk.Name + "." + field
+        }
+    }
+}
+
+// render formats req, its params left as {{name}}.
+func (d *dryRun) render(req *http.Request) (string, error) {
+    var body []byte
+    if req.Body != nil {
+        var err error
+        if body, err = ioutil.ReadAll(req.Body); err != nil {
+            return "", err
+        }
+    }
+    target := strings.NewReplacer("%7B%7B", "{{", "%7D%7D", "}}").Replace(req.URL.String())
+    keys := make([]string, 0, len(req.Header))
+    for k := range req.Header {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    
+    var out strings.Builder
+    if d.format == FormatCurl {
+        fmt.Fprintf(&out, "curl -X %s %s", req.Method, quote(target))
+        for _, k := range keys {
+            for _, v := range req.Header[k] {
+                fmt.Fprintf(&out, " \\\n  -H %s", quote(k+": "+v))
+            }
+        }
+        if len(body) != 0 {
+            fmt.Fprintf(&out, " \\\n  --data-raw %s", quote(strings.TrimSuffix(string(body), "\n")))
+        }
+        out.WriteString("\n")
+    } else {
+        fmt.Fprintf(&out, "%s %s HTTP/1.1\nHost: %s\n", req.Method, strings.TrimPrefix(target, req.URL.Scheme+"://"+req.URL.Host), req.URL.Host)
+        for _, k := range keys {
+            for _, v := range req.Header[k] {
+                fmt.Fprintf(&out, "%s: %s\n", k, v)
+            }
+        }
+        if len(body) != 0 {
+            fmt.Fprintf(&out, "\n%s\n", strings.TrimSuffix(string(body), "\n"))
+        }
+    }
+    if !d.color {
+        return out.String(), nil
+    }
+    return unresolved.ReplaceAllString(out.String(), "\x1b[31m$0\x1b[0m"), nil
+}
+
+// quote quotes s for a POSIX shell.
+func quote(s string) string {
+    return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
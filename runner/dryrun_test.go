@@ -0,0 +1,79 @@
+package runner
+
+import (
+    "strings"
+    "testing"
+    
+    "github.com/jarlex/gommander/feeder"
+    "github.com/jarlex/gommander/plan"
+)
+
+func dryRunPlan(t *testing.T) *plan.Plan {
+    rows := []map[string]interface{}{{"id": "o-7"}, {"id": "o-8"}}
+    p, err := plan.New("shop").URL("http://shop").BasicAuth("user", "p4ss-word").Var("tenant", "t1").
+        Feeder("data/orders.json", feeder.New(rows)).
+        Setup("login", 200).Request("login", "POST", "/login").Body(map[string]interface{}{"user": "u"}).Extract("token").
+        Step("browse", 1, 1).
+        Task("order", 201).Needs("token").Request("order", "PUT", "/tenants/{{tenant}}/orders/{{id}}").
+        Body(map[string]interface{}{"qty": 1}, "token", "coupon").Header("X-Note", "it's").
+        Task("list", 200).Request("list", "GET", "/orders").
+        Build()
+    if err != nil {
+        t.Fatal(err)
+    }
+    return p
+}
+
+func TestDryRun(t *testing.T) {
+    tests := []struct {
+        format string
+        color  bool
+        want   string
+    }{
+        {FormatCurl, false, `# plan setup: task login, request login
+curl -X POST 'http://shop/login' \
+  -H 'Authorization: Basic ****' \
+  -H 'Content-Type: application/json' \
+  --data-raw '{"user":"u"}'
+
+# step browse: task order, request order
+curl -X PUT 'http://shop/tenants/t1/orders/o-7' \
+  -H 'Authorization: Basic ****' \
+  -H 'Content-Type: application/json' \
+  -H 'X-Note: it'\''s' \
+  --data-raw '{"coupon":"{{coupon}}","qty":1,"token":"$login.token"}'
+# unresolved: coupon
+
+# step browse: task list, request list
+curl -X GET 'http://shop/orders' \
+  -H 'Authorization: Basic ****'
+
+`},
+        {FormatHTTP, true, "# plan setup: task login, request login\n" +
+            "POST /login HTTP/1.1\nHost: shop\nAuthorization: Basic ****\nContent-Type: application/json\n\n{\"user\":\"u\"}\n\n" +
+            "# step browse: task order, request order\n" +
+            "PUT /tenants/t1/orders/o-7 HTTP/1.1\nHost: shop\nAuthorization: Basic ****\nContent-Type: application/json\nX-Note: it's\n\n" +
+            "{\"coupon\":\"\x1b[31m{{coupon}}\x1b[0m\",\"qty\":1,\"token\":\"$login.token\"}\n" +
+            "# unresolved: coupon\n\n" +
+            "# step browse: task list, request list\n" +
+            "GET /orders HTTP/1.1\nHost: shop\nAuthorization: Basic ****\n\n"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.format, func(t *testing.T) {
+            var out strings.Builder
+            if err := DryRun(dryRunPlan(t), &out, tt.format, tt.color); err != nil {
+                t.Fatal(err)
+            }
+            if out.String() != tt.want {
+                t.Errorf("dry run =\n%s\nwant\n%s", out.String(), tt.want)
+            }
+        })
+    }
+}
+
+func TestDryRunFormat(t *testing.T) {
+    err := DryRun(dryRunPlan(t), &strings.Builder{}, "json", false)
+    if err == nil || err.Error() != "unknown dry run format json, use curl or http" {
+        t.Errorf("error = %v", err)
+    }
+}
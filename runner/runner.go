@@ -0,0 +1,195 @@
+// Package runner is the library API of gommander: it loads plans and runs
+// them, returning their metrics instead of printing them, so plans can be
+// driven from Go code and go test.
+//
+//	p, err := runner.LoadDir("testdata/plan")
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	res, err := runner.Run(ctx, p)
+//	if err != nil || res.Failed() {
+//		t.Fatalf("plan failed: %v", err)
+//	}
+package runner
+
+import (
+    "context"
+    "errors"
+    "io"
+    "io/fs"
+    "log"
+    "net/http"
+    "sort"
+    "time"
+    
+    "github.com/jarlex/gommander/config"
+    "github.com/jarlex/gommander/debug"
+    "github.com/jarlex/gommander/logging"
+    "github.com/jarlex/gommander/metrics"
+    "github.com/jarlex/gommander/plan"
+    "github.com/jarlex/gommander/secret"
+    "github.com/jarlex/gommander/step"
+    "github.com/jarlex/gommander/tracing"
+    "github.com/jarlex/transporter"
+)
+
+// Result gathers the metrics of a run.
+type Result = metrics.Result
+
+// Load loads the plan of a plan folder.
+func Load(fsys fs.FS, planFilename ...string) (*plan.Plan, error) {
+    conf, err := config.Load(fsys, planFilename...)
+    if err != nil {
+        return nil, err
+    }
+    return conf.Plan, nil
+}
+
+// LoadDir loads the plan of the plan folder dir.
+func LoadDir(dir string, planFilename ...string) (*plan.Plan, error) {
+    conf, err := config.Read(dir, planFilename...)
+    if err != nil {
+        return nil, err
+    }
+    return conf.Plan, nil
+}
+
+type options struct {
+    reporters []metrics.Reporter
+    client    *http.Client
+    logger    *logging.Logger
+    stop      <-chan struct{}
+    grace     time.Duration
+    dumper    *debug.Dumper
+    tracer    *tracing.Tracer
+}
+
+// Option configures a run.
+type Option func(*options)
+
+// WithReporter adds a reporter receiving every sample while the plan runs.
+func WithReporter(r metrics.Reporter) Option {
+    return func(o *options) {
+        o.reporters = append(o.reporters, r)
+    }
+}
+
+// WithClient sends the requests through c instead of the default client.
+func WithClient(c *http.Client) Option {
+    return func(o *options) {
+        o.client = c
+    }
+}
+
+// WithLogging sends the execution messages to l. They are discarded by
+// default.
+func WithLogging(l *logging.Logger) Option {
+    return func(o *options) {
+        o.logger = l
+    }
+}
+
+// WithLogger sends the execution messages at or above info to l as text,
+// with the secret values masked.
+func WithLogger(l *log.Logger) Option {
+    return WithLogging(logging.FromStd(l))
+}
+
+// WithLogOutput sends the execution messages at or above info to w as text.
+func WithLogOutput(w io.Writer) Option {
+    return WithLogger(log.New(w, "", 0))
+}
+
+// WithStop stops starting new petitions once stop is closed, waiting up to
+// grace for the ones in flight. Cancelling the context of Run aborts them
+// right away.
+func WithStop(stop <-chan struct{}, grace time.Duration) Option {
+    return func(o *options) {
+        o.stop = stop
+        o.grace = grace
+    }
+}
+
+// WithDebug dumps the petitions selected by opts to w, see the debug
+// package.
+func WithDebug(w io.Writer, opts debug.Options) Option {
+    return func(o *options) {
+        o.dumper = debug.New(w, opts)
+    }
+}
+
+// WithTracer traces each petition, its tasks and requests with t, see the
+// tracing package. The caller closes t once Run returns.
+func WithTracer(t *tracing.Tracer) Option {
+    return func(o *options) {
+        o.tracer = t
+    }
+}
+
+// Run runs p and returns its metrics. The error tells the plan could not
+// run, a setup failure for instance, the Result is still returned with what
+// ran. Failing samples and thresholds do not make Run fail, see
+// Result.Failed and Result.Thresholds.
+func Run(ctx context.Context, p *plan.Plan, opts ...Option) (*Result, error) {
+    o := &options{logger: logging.Discard()}
+    for _, opt := range opts {
+        opt(o)
+    }
+    
+    secret.Register(p.AuthPass)
+    
+    collector := metrics.NewCollector()
+    ctx = metrics.WithReporter(ctx, metrics.Multi(append([]metrics.Reporter{collector}, o.reporters...)...))
+    ctx = logging.WithLogger(ctx, o.logger)
+    if o.dumper != nil {
+        ctx = debug.WithDumper(ctx, o.dumper)
+    }
+    if o.tracer != nil {
+        ctx = tracing.WithTracer(ctx, o.tracer)
+    }
+    if o.stop != nil {
+        ctx = step.WithDrain(ctx, o.stop, o.grace)
+    }
+    
+    t := transporter.New()
+    if o.client != nil {
+        t.Client(o.client)
+    }
+    
+    start := time.Now()
+    err := p.ExecuteWith(ctx, t)
+    res := collector.Result()
+    index := make(map[string]int, len(p.Steps))
+    for i, s := range p.Steps {
+        index[s.Name] = i
+    }
+    sort.SliceStable(res.Steps, func(i, j int) bool {
+        return index[res.Steps[i].Name] < index[res.Steps[j].Name]
+    })
+    res.Plan = p.Name
+    res.Type = p.Type
+    res.URL = secret.Mask(p.URL)
+    for _, sr := range res.Steps {
+        i, ok := index[sr.Name]
+        if !ok {
+            continue
+        }
+        s := p.Steps[i]
+        sr.Users = s.ConcurrentUsers
+        sr.Planned = s.Petitions()
+        sr.DependsOn = s.DependsOn
+        if s.DependsOn == nil && i > 0 {
+            sr.DependsOn = []string{p.Steps[i-1].Name}
+        }
+    }
+    res.Start = start
+    res.Duration = time.Since(start)
+    res.Interrupted = step.Draining(ctx) || ctx.Err() != nil
+    if terr := res.CheckThresholds(p.Thresholds); terr != nil && err == nil {
+        err = terr
+    }
+    if err != nil {
+        return res, errors.New(secret.Mask(err.Error()))
+    }
+    return res, nil
+}
@@ -0,0 +1,576 @@
+package runner
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "reflect"
+    "sort"
+    "strings"
+    "sync"
+    "testing"
+    "testing/fstest"
+    "time"
+    
+    "github.com/jarlex/gommander/debug"
+    "github.com/jarlex/gommander/logging"
+    "github.com/jarlex/gommander/metrics"
+    "github.com/jarlex/gommander/plan"
+    "github.com/jarlex/gommander/request"
+    "github.com/jarlex/gommander/step"
+    "github.com/jarlex/gommander/task"
+    "github.com/jarlex/gommander/tracing"
+)
+
+// server counts the petitions it receives by method and path. POST /login
+// hands a token, /broken always fails, /slow answers after 30ms and /hang
+// when the petition is cancelled. GET petitions with a body are refused.
+type server struct {
+    *httptest.Server
+    mu   sync.Mutex
+    hits map[string]int
+}
+
+func newServer(t *testing.T) *server {
+    s := &server{hits: make(map[string]int)}
+    s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        s.mu.Lock()
+        s.hits[r.Method+" "+r.URL.Path]++
+        s.mu.Unlock()
+        w.Header().Set("Content-Type", "application/json")
+        if r.Method == http.MethodGet && (r.ContentLength != 0 || r.Header.Get("Content-Type") != "") {
+            w.WriteHeader(http.StatusBadRequest)
+            w.Write([]byte(`{"error": "GET with a body"}`))
+            return
+        }
+        switch r.URL.Path {
+        case "/login":
+            w.Write([]byte(`{"token": "abc"}`))
+        case "/broken":
+            w.WriteHeader(http.StatusInternalServerError)
+            w.Write([]byte(`{}`))
+        case "/slow":
+            time.Sleep(30 * time.Millisecond)
+            w.Write([]byte(`{}`))
+        case "/hang":
+            select {
+            case <-r.Context().Done():
+            case <-time.After(time.Second):
+            }
+        default:
+            w.Write([]byte(`{"items": [{"id": 1}]}`))
+        }
+    }))
+    t.Cleanup(s.Close)
+    return s
+}
+
+func (s *server) count(key string) int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.hits[key]
+}
+
+func newTask(name, method, path string, status int, params ...string) *task.Task {
+    return &task.Task{
+        Name:           name,
+        ExpectedStatus: status,
+        Request:        &request.Request{Name: name, Method: method, Path: path, ParamsURL: params},
+    }
+}
+
+func newStep(name string, users, petitions int, tasks ...*task.Task) *step.Step {
+    return &step.Step{Name: name, ConcurrentUsers: users, NumPetitions: petitions, Tasks: tasks}
+}
+
+func TestRun(t *testing.T) {
+    list := newTask("list", "GET", "/items", 200)
+    broken := newTask("list", "GET", "/broken", 200)
+    slow := newTask("slow", "GET", "/slow", 200)
+    type want struct {
+        status            string
+        petitions, failed int
+        count, failures   int
+    }
+    tests := []struct {
+        name   string
+        steps  func() []*step.Step
+        failed bool
+        want   map[string]want
+    }{
+        {
+            name:  "ok",
+            steps: func() []*step.Step { return []*step.Step{newStep("browse", 2, 4, list)} },
+            want:  map[string]want{"browse": {"OK", 4, 0, 4, 0}},
+        },
+        {
+            name:   "unexpected status",
+            steps:  func() []*step.Step { return []*step.Step{newStep("browse", 2, 4, broken)} },
+            failed: true,
+            want:   map[string]want{"browse": {"OK", 4, 4, 4, 4}},
+        },
+        {
+            name: "failed step setup skips its dependents",
+            steps: func() []*step.Step {
+                first := newStep("first", 1, 1, list)
+                first.Setup = []*task.Task{broken}
+                independent := newStep("independent", 1, 1, list)
+                independent.DependsOn = []string{}
+                return []*step.Step{first, newStep("second", 1, 1, list), independent}
+            },
+            failed: true,
+            want: map[string]want{
+                "first":       {"FAIL", 0, 0, 0, 0},
+                "second":      {"SKIPPED", 0, 0, 0, 0},
+                "independent": {"OK", 1, 0, 1, 0},
+            },
+        },
+        {
+            name: "sequential and concurrent steps",
+            steps: func() []*step.Step {
+                b := newStep("b", 1, 2, slow)
+                b.DependsOn = []string{}
+                c := newStep("c", 1, 2, slow)
+                c.DependsOn = []string{"a", "b"}
+                return []*step.Step{newStep("a", 1, 2, slow), b, c}
+            },
+            want: map[string]want{"a": {"OK", 2, 0, 2, 0}, "b": {"OK", 2, 0, 2, 0}, "c": {"OK", 2, 0, 2, 0}},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            srv := newServer(t)
+            p := &plan.Plan{Name: "shop", URL: srv.URL, Steps: tt.steps()}
+            res, err := Run(context.Background(), p)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if res.Plan != "shop" || res.Interrupted {
+                t.Errorf("plan, interrupted = %s, %v", res.Plan, res.Interrupted)
+            }
+            if res.Failed() != tt.failed {
+                t.Errorf("Failed() = %v, want %v", res.Failed(), tt.failed)
+            }
+            for name, w := range tt.want {
+                sr := res.Step(name)
+                if sr == nil {
+                    t.Fatalf("step %s not reported", name)
+                }
+                if sr.Status != w.status || sr.Petitions != w.petitions || sr.Failed != w.failed {
+                    t.Errorf("%s: status, petitions, failed = %s, %d, %d, want %s, %d, %d", name, sr.Status, sr.Petitions, sr.Failed, w.status, w.petitions, w.failed)
+                }
+                var count, failures int
+                for _, tr := range sr.Tasks {
+                    count += tr.Count
+                    failures += tr.Failures
+                }
+                if count != w.count || failures != w.failures {
+                    t.Errorf("%s: count, failures = %d, %d, want %d, %d", name, count, failures, w.count, w.failures)
+                }
+            }
+        })
+    }
+}
+
+func TestRunTimelines(t *testing.T) {
+    srv := newServer(t)
+    slow := newTask("slow", "GET", "/slow", 200)
+    b := newStep("b", 1, 2, slow)
+    b.DependsOn = []string{}
+    c := newStep("c", 1, 1, slow)
+    c.DependsOn = []string{"a"}
+    c.StartAfter = 50 * time.Millisecond
+    p := &plan.Plan{URL: srv.URL, Steps: []*step.Step{newStep("a", 1, 2, slow), b, c}}
+    res, err := Run(context.Background(), p)
+    if err != nil {
+        t.Fatal(err)
+    }
+    a, b2, c2 := res.Step("a"), res.Step("b"), res.Step("c")
+    if a.Start > 20*time.Millisecond || b2.Start > 20*time.Millisecond {
+        t.Errorf("a and b should start with the plan, at %s and %s", a.Start, b2.Start)
+    }
+    if b2.Start >= a.End {
+        t.Errorf("b started at %s, after a ended at %s", b2.Start, a.End)
+    }
+    if c2.Start < a.End+50*time.Millisecond {
+        t.Errorf("c started at %s, want 50ms after a ended at %s", c2.Start, a.End)
+    }
+    for _, s := range res.Steps {
+        if s.End < s.Start+30*time.Millisecond {
+            t.Errorf("%s ran from %s to %s", s.Name, s.Start, s.End)
+        }
+    }
+}
+
+func TestRunHooks(t *testing.T) {
+    login := newTask("login", "POST", "/login", 200)
+    login.NextData = []string{"token"}
+    items := newTask("items", "GET", "/items/{{token}}", 200, "token")
+    logout := newTask("logout", "DELETE", "/sessions/{{token}}", 200, "token")
+    broken := newTask("broken", "POST", "/broken", 200)
+    
+    t.Run("setup vars reach every step", func(t *testing.T) {
+        srv := newServer(t)
+        p := &plan.Plan{URL: srv.URL, Setup: []*task.Task{login}, Teardown: []*task.Task{logout},
+            Steps: []*step.Step{newStep("a", 2, 4, items), newStep("b", 1, 3, items)}}
+        res, err := Run(context.Background(), p)
+        if err != nil || res.Failed() {
+            t.Fatalf("run failed: %v", err)
+        }
+        if n := srv.count("GET /items/abc"); n != 7 {
+            t.Errorf("%d petitions with the setup token, want 7", n)
+        }
+        if n := srv.count("DELETE /sessions/abc"); n != 1 {
+            t.Errorf("teardown sent %d petitions, want 1", n)
+        }
+    })
+    t.Run("teardown after a failed setup", func(t *testing.T) {
+        srv := newServer(t)
+        p := &plan.Plan{URL: srv.URL, Setup: []*task.Task{login, broken}, Teardown: []*task.Task{logout},
+            Steps: []*step.Step{newStep("a", 1, 1, items)}}
+        _, err := Run(context.Background(), p)
+        if err == nil || !strings.Contains(err.Error(), "setup") {
+            t.Errorf("error = %v, want a setup failure", err)
+        }
+        if n := srv.count("GET /items/abc"); n != 0 {
+            t.Errorf("steps ran after a failed setup")
+        }
+        if n := srv.count("DELETE /sessions/abc"); n != 1 {
+            t.Errorf("teardown sent %d petitions, want 1", n)
+        }
+    })
+}
+
+func TestRunStop(t *testing.T) {
+    slow := newTask("slow", "GET", "/slow", 200)
+    hang := newTask("hang", "GET", "/hang", 200)
+    cleanup := newTask("cleanup", "DELETE", "/fixtures", 200)
+    tests := []struct {
+        name     string
+        task     *task.Task
+        grace    time.Duration
+        failures bool // Whether the petitions in flight are cancelled
+    }{
+        {"in flight finish within the grace", slow, time.Second, false},
+        {"in flight cancelled after the grace", hang, 50 * time.Millisecond, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            srv := newServer(t)
+            p := &plan.Plan{
+                URL:      srv.URL,
+                Steps:    []*step.Step{newStep("load", 2, 200, tt.task), newStep("next", 1, 1, slow)},
+                Teardown: []*task.Task{cleanup},
+            }
+            stop := make(chan struct{})
+            time.AfterFunc(50*time.Millisecond, func() { close(stop) })
+            res, err := Run(context.Background(), p, WithStop(stop, tt.grace))
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !res.Interrupted {
+                t.Error("a stopped run is not reported as interrupted")
+            }
+            load := res.Step("load")
+            if load.Status != "OK" || load.Petitions == 0 || load.Petitions >= 200 {
+                t.Errorf("load: status %s, %d petitions", load.Status, load.Petitions)
+            }
+            if (load.Failed > 0) != tt.failures {
+                t.Errorf("load: %d of %d petitions failed", load.Failed, load.Petitions)
+            }
+            if tt.failures && !strings.Contains(strings.Join(errorsOf(load.Tasks[0].Errors), ","), "context canceled") {
+                t.Errorf("errors = %v, want the petitions cancelled", load.Tasks[0].Errors)
+            }
+            if next := res.Step("next"); next.Status != "STOPPED" || next.Petitions != 0 {
+                t.Errorf("next = %+v, want STOPPED", next)
+            }
+            if n := srv.count("DELETE /fixtures"); n != 1 {
+                t.Errorf("teardown sent %d petitions, want 1", n)
+            }
+        })
+    }
+}
+
+func errorsOf(errs map[string]int) []string {
+    var out []string
+    for e := range errs {
+        out = append(out, e)
+    }
+    return out
+}
+
+func TestRunCancelled(t *testing.T) {
+    srv := newServer(t)
+    p := &plan.Plan{URL: srv.URL, Steps: []*step.Step{newStep("browse", 1, 10, newTask("list", "GET", "/items", 200))}}
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    res, _ := Run(ctx, p)
+    if !res.Interrupted {
+        t.Error("a cancelled run is not reported as interrupted")
+    }
+    if n := srv.count("GET /items"); n != 0 {
+        t.Errorf("a cancelled run sent %d petitions", n)
+    }
+}
+
+func TestRunDebug(t *testing.T) {
+    tests := []struct {
+        name  string
+        opts  debug.Options
+        dumps []string
+        lines []string
+    }{
+        {
+            name:  "failing",
+            opts:  debug.Options{Failing: true, User: -1},
+            dumps: []string{"browse|U0|P0|order FAIL: Status not expected", "browse|U1|P0|order FAIL: Status not expected"},
+            lines: []string{"> GET URL/broken", "> Authorization: ****", "< 500 Internal Server Error", "< {}", `vars before: {"tenant":"t1"}`},
+        },
+        {
+            name:  "trace user",
+            opts:  debug.Options{User: 1, Limit: 10},
+            dumps: []string{"browse|U1|P0|list OK", "browse|U1|P0|order FAIL: Status not expected"},
+            lines: []string{"> GET URL/items", "< 200 OK", `< {"items": `, "< ... 12 more bytes"},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            srv := newServer(t)
+            p := &plan.Plan{
+                URL: srv.URL, AuthType: "basic", AuthUser: "user", AuthPass: "pass",
+                Vars:  map[string]interface{}{"tenant": "t1"},
+                Steps: []*step.Step{newStep("browse", 2, 2, newTask("list", "GET", "/items", 200), newTask("order", "GET", "/broken", 200))},
+            }
+            var out strings.Builder
+            if _, err := Run(context.Background(), p, WithDebug(&out, tt.opts)); err != nil {
+                t.Fatal(err)
+            }
+            var dumps []string
+            for _, line := range strings.Split(out.String(), "\n") {
+                if strings.HasPrefix(line, "--- ") {
+                    dumps = append(dumps, strings.TrimPrefix(line, "--- "))
+                }
+            }
+            sort.Strings(dumps)
+            if !reflect.DeepEqual(dumps, tt.dumps) {
+                t.Errorf("dumps = %q, want %q", dumps, tt.dumps)
+            }
+            for _, line := range tt.lines {
+                line = strings.Replace(line, "URL", srv.URL, 1)
+                if !strings.Contains(out.String(), "\n"+line) {
+                    t.Errorf("no line %q in\n%s", line, out.String())
+                }
+            }
+        })
+    }
+}
+
+func TestRunLogging(t *testing.T) {
+    srv := newServer(t)
+    p := &plan.Plan{Name: "shop", URL: srv.URL, Steps: []*step.Step{newStep("browse", 1, 1, newTask("list", "GET", "/items", 200), newTask("order", "GET", "/broken", 200))}}
+    tests := []struct {
+        level logging.Level
+        msgs  []string
+    }{
+        {logging.LevelDebug, []string{"step started", "task done", "task failed", "step finished", "plan finished"}},
+        {logging.LevelInfo, []string{"step finished", "plan finished"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.level.String(), func(t *testing.T) {
+            var out strings.Builder
+            l, err := logging.New(&out, tt.level, logging.FormatJSON)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if _, err := Run(context.Background(), p, WithLogging(l)); err != nil {
+                t.Fatal(err)
+            }
+            var msgs []string
+            for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+                var fields map[string]interface{}
+                if err := json.Unmarshal([]byte(line), &fields); err != nil {
+                    t.Fatalf("%s: %s", line, err)
+                }
+                if fields["plan"] != "shop" {
+                    t.Errorf("no plan field in %s", line)
+                }
+                msg := fields["msg"].(string)
+                msgs = append(msgs, msg)
+                if strings.HasPrefix(msg, "task") && (fields["step"] != "browse" || fields["user"] != 0.0 || fields["iteration"] != 0.0 || fields["task"] == nil) {
+                    t.Errorf("task fields missing in %s", line)
+                }
+                if msg == "task failed" && fields["err"] != "Status not expected" {
+                    t.Errorf("err = %v", fields["err"])
+                }
+            }
+            if !reflect.DeepEqual(msgs, tt.msgs) {
+                t.Errorf("messages = %q, want %q", msgs, tt.msgs)
+            }
+        })
+    }
+}
+
+func TestRunResultPlan(t *testing.T) {
+    srv := newServer(t)
+    list := newTask("list", "GET", "/items", 200)
+    p := &plan.Plan{Name: "shop", Type: "load", URL: srv.URL, Steps: []*step.Step{
+        newStep("browse", 2, 3, list),
+        newStep("buy", 1, 1, list),
+        newStep("audit", 1, 1, list),
+    }}
+    p.Steps[2].DependsOn = []string{}
+    res, err := Run(context.Background(), p)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if res.Type != "load" || res.URL != srv.URL {
+        t.Errorf("result of %s plan at %s", res.Type, res.URL)
+    }
+    tests := []struct {
+        step      string
+        users     int
+        planned   int
+        dependsOn []string
+    }{
+        {"browse", 2, 2, nil}, // 3 petitions rounded down to the users
+        {"buy", 1, 1, []string{"browse"}},
+        {"audit", 1, 1, nil},
+    }
+    for _, tt := range tests {
+        sr := res.Step(tt.step)
+        if sr.Users != tt.users || sr.Planned != tt.planned || strings.Join(sr.DependsOn, ",") != strings.Join(tt.dependsOn, ",") {
+            t.Errorf("%s: %d users, %d planned, depends on %q", tt.step, sr.Users, sr.Planned, sr.DependsOn)
+        }
+    }
+}
+
+func TestRunTracing(t *testing.T) {
+    var mu sync.Mutex
+    var parents []string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        parents = append(parents, r.Header.Get("traceparent"))
+        mu.Unlock()
+        w.Write([]byte(`{}`))
+    }))
+    defer srv.Close()
+    path := filepath.Join(t.TempDir(), "trace.jsonl")
+    tr, err := tracing.New(tracing.Options{Exporter: tracing.ExportFile, Target: path})
+    if err != nil {
+        t.Fatal(err)
+    }
+    p := &plan.Plan{Name: "shop", URL: srv.URL, Steps: []*step.Step{newStep("browse", 1, 1, newTask("list", "GET", "/items", 200), newTask("item", "GET", "/items/1", 200))}}
+    if _, err := Run(context.Background(), p, WithTracer(tr)); err != nil {
+        t.Fatal(err)
+    }
+    if err := tr.Close(); err != nil {
+        t.Fatal(err)
+    }
+    
+    raw, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var req struct {
+        ResourceSpans []struct {
+            ScopeSpans []struct {
+                Spans []struct {
+                    TraceID      string `json:"traceId"`
+                    SpanID       string `json:"spanId"`
+                    ParentSpanID string `json:"parentSpanId"`
+                    Name         string `json:"name"`
+                } `json:"spans"`
+            } `json:"scopeSpans"`
+        } `json:"resourceSpans"`
+    }
+    if err := json.Unmarshal(raw, &req); err != nil {
+        t.Fatal(err)
+    }
+    spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+    byName := make(map[string]string) // Span ID by name
+    var want []string                 // traceparent of the requests
+    for _, s := range spans {
+        if s.TraceID != spans[0].TraceID {
+            t.Errorf("span %s is in trace %s, not %s", s.Name, s.TraceID, spans[0].TraceID)
+        }
+        byName[s.Name] = s.SpanID
+    }
+    for _, s := range spans {
+        switch s.Name {
+        case "browse":
+            if s.ParentSpanID != "" {
+                t.Errorf("petition span has parent %s", s.ParentSpanID)
+            }
+        case "list", "item":
+            if s.ParentSpanID != byName["browse"] {
+                t.Errorf("task span %s is not a child of the petition", s.Name)
+            }
+        case "HTTP GET":
+            if s.ParentSpanID != byName["list"] && s.ParentSpanID != byName["item"] {
+                t.Errorf("request span is not a child of a task")
+            }
+            want = append(want, "00-"+s.TraceID+"-"+s.SpanID+"-01")
+        }
+    }
+    if len(spans) != 5 || len(want) != 2 {
+        t.Fatalf("%d spans, %d requests, want 5 and 2", len(spans), len(want))
+    }
+    if !reflect.DeepEqual(parents, want) {
+        t.Errorf("traceparent headers %q, want %q", parents, want)
+    }
+}
+
+func TestLoad(t *testing.T) {
+    srv := newServer(t)
+    fsys := fstest.MapFS{
+        "requests/list.json": {Data: []byte(`{"name": "list", "method": "GET", "path": "/items"}`)},
+        "tasks/list.json":    {Data: []byte(`{"name": "list", "request": "list", "expectedStatus": 200}`)},
+        "steps/browse.json":  {Data: []byte(`{"name": "browse", "numPetitions": 6, "concurrentUsers": 3, "tasks": ["list"]}`)},
+        "plan.json":          {Data: []byte(`{"name": "shop", "url": "` + srv.URL + `", "steps": ["browse"]}`)},
+    }
+    p, err := Load(fsys)
+    if err != nil {
+        t.Fatal(err)
+    }
+    res, err := Run(context.Background(), p)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if res.Failed() {
+        t.Fatalf("plan failed: %+v", res.Steps)
+    }
+    if sr := res.Step("browse"); sr == nil || sr.Petitions != 6 || sr.Task("list").Count != 6 {
+        t.Fatalf("browse = %+v", sr)
+    }
+    if n := srv.count("GET /items"); n != 6 {
+        t.Errorf("%d petitions sent, want 6", n)
+    }
+}
+
+func TestRunThresholds(t *testing.T) {
+    srv := newServer(t)
+    p, err := plan.New("shop").URL(srv.URL).
+        Threshold(metrics.Threshold{Metric: "p95", Task: "list", Max: "1s"}).
+        Threshold(metrics.Threshold{Metric: "errorRate", Task: "order", Max: "1%"}).
+        Step("browse", 1, 2).
+        Task("list", 200).Request("list", "GET", "/items").
+        Task("order", 200).Request("order", "GET", "/broken").
+        Build()
+    if err != nil {
+        t.Fatal(err)
+    }
+    res, err := Run(context.Background(), p)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(res.Thresholds) != 2 || !res.Thresholds[0].Passed || res.Thresholds[1].Passed || res.Thresholds[1].Value != "100.00%" {
+        t.Errorf("thresholds = %+v", res.Thresholds)
+    }
+    
+    if _, err := plan.New("shop").Threshold(metrics.Threshold{Metric: "p95", Max: "fast"}).Step("s", 1, 1).Build(); err == nil {
+        t.Error("Build accepted a threshold that is not a duration")
+    }
+}
@@ -0,0 +1,76 @@
+// Package secret tracks the secret values of the plans, passwords and
+// tokens, so they can be masked from every log, report and error message.
+package secret
+
+import (
+    "io"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// Masked replaces the secret values.
+const Masked = "****"
+
+// minLength is the length under which values are not tracked, masking every
+// "a" of the output would not hide anything.
+const minLength = 3
+
+var (
+    mu       sync.RWMutex
+    values   = make(map[string]bool)
+    replacer = strings.NewReplacer()
+)
+
+// Register tracks values as secrets.
+func Register(secrets ...string) {
+    mu.Lock()
+    defer mu.Unlock()
+    added := false
+    for _, s := range secrets {
+        if len(s) >= minLength && !values[s] {
+            values[s] = true
+            added = true
+        }
+    }
+    if !added {
+        return
+    }
+    
+    // Longest first, so a secret containing another one is fully masked.
+    var sorted []string
+    for s := range values {
+        sorted = append(sorted, s)
+    }
+    sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+    var pairs []string
+    for _, s := range sorted {
+        pairs = append(pairs, s, Masked)
+    }
+    replacer = strings.NewReplacer(pairs...)
+}
+
+// Mask returns s with every secret value replaced by Masked.
+func Mask(s string) string {
+    mu.RLock()
+    r := replacer
+    mu.RUnlock()
+    return r.Replace(s)
+}
+
+// Writer returns a writer masking the secret values before writing to w.
+// Each Write is masked on its own, which suits loggers writing whole lines.
+func Writer(w io.Writer) io.Writer {
+    return maskWriter{w}
+}
+
+type maskWriter struct {
+    w io.Writer
+}
+
+func (m maskWriter) Write(p []byte) (int, error) {
+    if _, err := io.WriteString(m.w, Mask(string(p))); err != nil {
+        return 0, err
+    }
+    return len(p), nil
+}
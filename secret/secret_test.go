@@ -0,0 +1,36 @@
+package secret
+
+import (
+    "bytes"
+    "testing"
+)
+
+func TestMask(t *testing.T) {
+    Register("ab", "token-123", "token-123-long", "")
+    tests := []struct {
+        in, want string
+    }{
+        {"no secret", "no secret"},
+        {"short ab values are kept", "short ab values are kept"},
+        {"Bearer token-123", "Bearer ****"},
+        {"token-123-long and token-123", "**** and ****"},
+    }
+    for _, tt := range tests {
+        if got := Mask(tt.in); got != tt.want {
+            t.Errorf("Mask(%q) = %q, want %q", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestWriter(t *testing.T) {
+    Register("p4ssw0rd")
+    var buf bytes.Buffer
+    line := "login with p4ssw0rd\n"
+    n, err := Writer(&buf).Write([]byte(line))
+    if err != nil || n != len(line) {
+        t.Fatalf("Write = %d, %v", n, err)
+    }
+    if got := buf.String(); got != "login with ****\n" {
+        t.Errorf("written %q", got)
+    }
+}
@@ -0,0 +1,103 @@
+package step
+
+import (
+    "context"
+    "time"
+)
+
+// TeardownTimeout bounds the teardown tasks run after the context of the run
+// was cancelled.
+var TeardownTimeout = 30 * time.Second
+
+type drainKey struct{}
+
+type drain struct {
+    stop  <-chan struct{}
+    grace time.Duration
+}
+
+// WithDrain returns a copy of ctx asking the steps to stop starting new
+// petitions once stop is closed. Petitions in flight are cancelled if they
+// did not finish within grace. Cancelling ctx itself aborts everything.
+func WithDrain(ctx context.Context, stop <-chan struct{}, grace time.Duration) context.Context {
+    return context.WithValue(ctx, drainKey{}, drain{stop: stop, grace: grace})
+}
+
+// Draining reports whether the run was asked to stop starting new work.
+func Draining(ctx context.Context) bool {
+    select {
+    case <-Stopping(ctx):
+        return true
+    default:
+        return false
+    }
+}
+
+// Stopping returns the channel closed when the run is asked to stop starting
+// new work, nil when ctx carries no drain.
+func Stopping(ctx context.Context) <-chan struct{} {
+    d, _ := ctx.Value(drainKey{}).(drain)
+    return d.stop
+}
+
+// inFlight returns a context for the petitions of a step, cancelled when the
+// grace period of a drain runs out. The returned func releases it.
+func inFlight(ctx context.Context) (context.Context, context.CancelFunc) {
+    flightCtx, cancel := context.WithCancel(ctx)
+    d, _ := ctx.Value(drainKey{}).(drain)
+    if d.stop == nil {
+        return flightCtx, cancel
+    }
+    go func() {
+        select {
+        case <-d.stop:
+        case <-flightCtx.Done():
+            return
+        }
+        timer := time.NewTimer(d.grace)
+        defer timer.Stop()
+        select {
+        case <-timer.C:
+            cancel()
+        case <-flightCtx.Done():
+        }
+    }()
+    return flightCtx, cancel
+}
+
+// detached keeps the values of a context, the logger and the reporter, but
+// not its cancellation.
+type detached struct {
+    context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detached) Done() <-chan struct{} { return nil }
+
+func (detached) Err() error { return nil }
+
+// TeardownContext returns the context of the teardown tasks: ctx, or when ctx
+// is already cancelled a context with its values cancelled after
+// TeardownTimeout instead, so the teardown still cleans up after an abort.
+// The returned func releases it.
+func TeardownContext(ctx context.Context) (context.Context, context.CancelFunc) {
+    if ctx.Err() == nil {
+        return ctx, func() {}
+    }
+    return context.WithTimeout(detached{ctx}, TeardownTimeout)
+}
+
+// think waits for d, returning early once ctx is draining or cancelled.
+func think(ctx context.Context, d time.Duration) {
+    if d <= 0 {
+        return
+    }
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+    case <-Stopping(ctx):
+    case <-ctx.Done():
+    }
+}
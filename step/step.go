@@ -1,68 +1,220 @@
 package step
 
 import (
+    "context"
     "encoding/json"
     "fmt"
     "io/ioutil"
-    "log"
-    "os"
     "sync"
+    "sync/atomic"
     "time"
     
+    "github.com/jarlex/gommander/debug"
+    "github.com/jarlex/gommander/feeder"
+    "github.com/jarlex/gommander/logging"
+    "github.com/jarlex/gommander/metrics"
+    "github.com/jarlex/gommander/strict"
     "github.com/jarlex/gommander/task"
+    "github.com/jarlex/gommander/tracing"
     "github.com/jarlex/transporter"
 )
 
 type Step struct {
-    Name            string       `json:"name"`            // Step Name
-    NumPetitions    int          `json:"numPetitions"`    // Number of petitions
-    ConcurrentUsers int          `json:"concurrentUsers"` // Concurrent users
-    TasksNames      []string     `json:"tasks"`           // Concurrent users
-    Tasks           []*task.Task // Orderer tasks
+    Name            string        `json:"name"`                 // Step Name
+    NumPetitions    int           `json:"numPetitions"`         // Number of petitions
+    ConcurrentUsers int           `json:"concurrentUsers"`      // Concurrent users
+    TasksNames      []string      `json:"tasks"`                // Concurrent users
+    SetupNames      []string      `json:"setup,omitempty"`      // Tasks run once before the users start
+    TeardownNames   []string      `json:"teardown,omitempty"`   // Tasks run once after the users finish
+    DependsOn       []string      `json:"dependsOn"`            // Steps that must finish first, the previous one when absent
+    StartOffset     string        `json:"startAfter,omitempty"` // Delay once the dependencies finished
+    StartAfter      time.Duration `json:"-"`
+    Tasks           []*task.Task  `json:"-"` // Orderer tasks
+    Setup           []*task.Task  `json:"-"` // Ordered setup tasks
+    Teardown        []*task.Task  `json:"-"` // Ordered teardown tasks
+}
+
+// MarshalJSON leaves dependsOn out when nil, an empty list is kept as it
+// means the step starts with the plan.
+func (s Step) MarshalJSON() ([]byte, error) {
+    type plain Step
+    out := struct {
+        plain
+        DependsOn *[]string `json:"dependsOn,omitempty"`
+    }{plain: plain(s)}
+    if s.DependsOn != nil {
+        out.DependsOn = &s.DependsOn
+    }
+    return json.Marshal(out)
 }
 
 func Read(filePath string, tasks map[string]*task.Task) (*Step, error) {
-    logger := log.New(os.Stdout, "", 0)
     raw, err := ioutil.ReadFile(filePath)
-    
     if err != nil {
-        logger.Fatalf("Error reading the file in %s: %s", filePath, err.Error())
-        return nil, err
+        return nil, fmt.Errorf("Error reading the file in %s: %s", filePath, err.Error())
     }
     
+    return Parse(raw, tasks)
+}
+
+// Parse decodes a step definition, rejecting the unknown keys, and links its
+// tasks.
+func Parse(raw []byte, tasks map[string]*task.Task) (*Step, error) {
     var s Step
-    json.Unmarshal(raw, &s)
+    if err := strict.Unmarshal(raw, &s, nil); err != nil {
+        return nil, err
+    }
+    if s.StartOffset != "" {
+        var err error
+        s.StartAfter, err = time.ParseDuration(s.StartOffset)
+        if err != nil {
+            return nil, fmt.Errorf("startAfter of step %s: %s", s.Name, err.Error())
+        }
+    }
+    if err := s.Validate(); err != nil {
+        return nil, err
+    }
     for _, t := range s.TasksNames {
+        if tasks[t] == nil {
+            return nil, fmt.Errorf("task %s of step %s not found", t, s.Name)
+        }
         s.Tasks = append(s.Tasks, tasks[t])
     }
+    for _, t := range s.SetupNames {
+        if tasks[t] == nil {
+            return nil, fmt.Errorf("setup task %s of step %s not found", t, s.Name)
+        }
+        s.Setup = append(s.Setup, tasks[t])
+    }
+    for _, t := range s.TeardownNames {
+        if tasks[t] == nil {
+            return nil, fmt.Errorf("teardown task %s of step %s not found", t, s.Name)
+        }
+        s.Teardown = append(s.Teardown, tasks[t])
+    }
     return &s, nil
 }
 
-func (s *Step) Execute(t *transporter.Transporter, base string) {
+// Validate checks the users and petitions of the step can run.
+func (s *Step) Validate() error {
+    if s.ConcurrentUsers <= 0 {
+        return fmt.Errorf("step %s needs at least one concurrent user, got %d", s.Name, s.ConcurrentUsers)
+    }
+    if s.NumPetitions < 0 {
+        return fmt.Errorf("numPetitions of step %s cannot be negative, got %d", s.Name, s.NumPetitions)
+    }
+    return nil
+}
+
+// Petitions returns the number of petitions the users run, NumPetitions
+// rounded down to a multiple of ConcurrentUsers.
+func (s *Step) Petitions() int {
+    if s.ConcurrentUsers <= 0 {
+        return 0
+    }
+    return s.NumPetitions / s.ConcurrentUsers * s.ConcurrentUsers
+}
+
+// Execute runs the setup tasks once, then the users, then the teardown tasks.
+// The variables in shared and the ones extracted by the setup tasks are
+// handed to every user as the starting data of each petition, along with the
+// next row of the feeder of ctx, if any. Teardown runs even when the setup
+// fails or the run is interrupted, see TeardownContext. Once ctx is draining
+// (see WithDrain) users stop starting new petitions and skip the think times
+// of the tasks left. Samples go to the reporter of ctx, and each petition is
+// a trace of the tracer of ctx, if any.
+func (s *Step) Execute(ctx context.Context, t *transporter.Transporter, base string, shared map[string]interface{}) error {
+    if err := s.Validate(); err != nil {
+        return err
+    }
+    logger := logging.FromContext(ctx).With("step", s.Name)
+    vars, err := task.ExecuteAll(ctx, s.Setup, t, base, shared)
+    defer func() {
+        teardownCtx, cancel := TeardownContext(ctx)
+        defer cancel()
+        if _, err := task.ExecuteAll(teardownCtx, s.Teardown, t, base, vars); err != nil {
+            logger.Error("teardown failed", "err", err)
+        }
+    }()
+    if err != nil {
+        return fmt.Errorf("setup of step %s failed: %s", s.Name, err.Error())
+    }
+    
+    flightCtx, cancel := inFlight(ctx)
+    defer cancel()
+    dumper := debug.FromContext(ctx)
+    rows := feeder.FromContext(ctx)
+    
+    var done, failed int64
     reqEachUser := s.NumPetitions / s.ConcurrentUsers
+    logger.Debug("step started", "users", s.ConcurrentUsers, "planned", s.Petitions())
+    metrics.StepStarted(ctx, s.Name, s.ConcurrentUsers, s.Petitions())
     var wg sync.WaitGroup
     wg.Add(s.ConcurrentUsers)
     for user := 0; user < s.ConcurrentUsers; user++ {
         go func(user int) {
             defer wg.Done()
+            defer metrics.UserDone(ctx, s.Name)
             for petition := 0; petition < reqEachUser; petition++ {
-                var previousData map[string]interface{}
-                var totalTime int64
-                totalTime = 0
+                if Draining(ctx) || ctx.Err() != nil {
+                    return
+                }
+                previousData := make(map[string]interface{}, len(vars))
+                for k, v := range vars {
+                    previousData[k] = v
+                }
+                for k, v := range rows.Next() {
+                    previousData[k] = v
+                }
+                total := metrics.Sample{Time: time.Now(), Step: s.Name, User: user, Petition: petition}
+                petitionCtx, span := tracing.Start(flightCtx, s.Name, tracing.KindInternal)
+                span.SetAttribute("gommander.step", s.Name)
+                span.SetAttribute("gommander.user", user)
+                span.SetAttribute("gommander.iteration", petition)
                 for _, tsk := range s.Tasks {
-                    var duration time.Duration
-                    var err error
-                    previousData, duration, err = tsk.Execute(t, base, previousData)
-                    if err != nil {
-                        fmt.Println(fmt.Sprintf("%s|U%d|FAIL|%s|%d|%s", s.Name, user, tsk.Name, petition, err.Error()))
+                    think(ctx, tsk.Think)
+                    sample := metrics.Sample{Time: time.Now(), Step: s.Name, User: user, Petition: petition, Task: tsk.Name}
+                    taskCtx, exchange := dumper.Watch(petitionCtx, user)
+                    var before map[string]interface{}
+                    if exchange != nil {
+                        before = make(map[string]interface{}, len(previousData))
+                        for k, v := range previousData {
+                            before[k] = v
+                        }
+                    }
+                    var nextData map[string]interface{}
+                    nextData, sample.Duration, sample.Err = tsk.Execute(taskCtx, t, base, previousData)
+                    if sample.Err != nil {
+                        sample.Duration = time.Since(sample.Time)
+                        if logger.Enabled(logging.LevelDebug) {
+                            logger.Debug("task failed", "user", user, "iteration", petition, "task", tsk.Name, "err", sample.Err)
+                        }
+                        dumper.Dump(sample, before, previousData, exchange)
+                        metrics.Report(ctx, sample)
+                        total.Err = sample.Err
                         break
                     }
-                    fmt.Println(fmt.Sprintf("%s|U%d|%d ns|T%s|%d", s.Name, user, duration.Nanoseconds(), tsk.Name, petition))
-                    totalTime = totalTime + duration.Nanoseconds()
+                    for k, v := range nextData {
+                        previousData[k] = v
+                    }
+                    if logger.Enabled(logging.LevelDebug) {
+                        logger.Debug("task done", "user", user, "iteration", petition, "task", tsk.Name, "duration", sample.Duration)
+                    }
+                    dumper.Dump(sample, before, previousData, exchange)
+                    metrics.Report(ctx, sample)
+                    total.Duration += sample.Duration
+                }
+                span.End(total.Err)
+                metrics.Report(ctx, total)
+                atomic.AddInt64(&done, 1)
+                if total.Err != nil {
+                    atomic.AddInt64(&failed, 1)
                 }
-                fmt.Println(fmt.Sprintf("T|%s|U%d|%d ns|%d", s.Name, user, totalTime, petition))
             }
         }(user)
     }
     wg.Wait()
+    
+    logger.Info("step finished", "petitions", done, "planned", s.Petitions(), "failed", failed)
+    return nil
 }
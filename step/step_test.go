@@ -0,0 +1,81 @@
+package step
+
+import (
+    "context"
+    "strings"
+    "testing"
+    "time"
+    
+    "github.com/jarlex/gommander/task"
+)
+
+func TestParseValidatesUsersAndPetitions(t *testing.T) {
+    tasks := map[string]*task.Task{"list": {Name: "list"}}
+    tests := []struct {
+        name string
+        raw  string
+        err  string
+    }{
+        {"valid", `{"name": "s", "numPetitions": 4, "concurrentUsers": 2, "tasks": ["list"]}`, ""},
+        {"no petitions", `{"name": "s", "numPetitions": 0, "concurrentUsers": 1, "tasks": ["list"]}`, ""},
+        {"zero users", `{"name": "s", "numPetitions": 4, "concurrentUsers": 0, "tasks": ["list"]}`, "at least one concurrent user"},
+        {"missing users", `{"name": "s", "numPetitions": 4, "tasks": ["list"]}`, "at least one concurrent user"},
+        {"negative users", `{"name": "s", "numPetitions": 4, "concurrentUsers": -2, "tasks": ["list"]}`, "at least one concurrent user"},
+        {"negative petitions", `{"name": "s", "numPetitions": -1, "concurrentUsers": 1, "tasks": ["list"]}`, "cannot be negative"},
+        {"unknown task", `{"name": "s", "numPetitions": 1, "concurrentUsers": 1, "tasks": ["nope"]}`, "task nope of step s not found"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := Parse([]byte(tt.raw), tasks)
+            if tt.err == "" {
+                if err != nil {
+                    t.Fatalf("unexpected error: %s", err)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Fatalf("error = %v, want it to contain %q", err, tt.err)
+            }
+        })
+    }
+}
+
+func TestTeardownContext(t *testing.T) {
+    type key struct{}
+    ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "logger"))
+    if got, release := TeardownContext(ctx); got != ctx {
+        t.Errorf("a live context was replaced")
+        release()
+    }
+    
+    cancel()
+    teardownCtx, release := TeardownContext(ctx)
+    defer release()
+    if teardownCtx.Err() != nil || teardownCtx.Value(key{}) != "logger" {
+        t.Errorf("teardown context err = %v, value = %v", teardownCtx.Err(), teardownCtx.Value(key{}))
+    }
+    if deadline, ok := teardownCtx.Deadline(); !ok || time.Until(deadline) > TeardownTimeout {
+        t.Errorf("teardown context deadline = %s, %v", deadline, ok)
+    }
+    release()
+    if teardownCtx.Err() == nil {
+        t.Errorf("release did not cancel the teardown context")
+    }
+}
+
+func TestPetitions(t *testing.T) {
+    tests := []struct {
+        users, petitions, want int
+    }{
+        {2, 4, 4},
+        {3, 10, 9},
+        {4, 3, 0},
+        {0, 10, 0},
+    }
+    for _, tt := range tests {
+        s := &Step{ConcurrentUsers: tt.users, NumPetitions: tt.petitions}
+        if got := s.Petitions(); got != tt.want {
+            t.Errorf("Petitions() with %d users and %d petitions = %d, want %d", tt.users, tt.petitions, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,125 @@
+// Package strict decodes the plan definitions rejecting the unknown keys, so
+// a typo fails the load instead of being silently ignored.
+package strict
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "regexp"
+    "strings"
+)
+
+// SchemaKey is the key editors use to find the JSON Schema of a document, it
+// is accepted and ignored everywhere.
+const SchemaKey = "$schema"
+
+var unknownField = regexp.MustCompile(`^json: unknown field "(.*)"$`)
+
+// Unmarshal decodes the JSON object raw into v, rejecting the keys that are
+// not fields of v. aliases maps the legacy keys still accepted to the
+// current ones.
+func Unmarshal(raw []byte, v interface{}, aliases map[string]string) error {
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(raw, &fields); err != nil {
+        return err
+    }
+    delete(fields, SchemaKey)
+    for legacy, current := range aliases {
+        value, ok := fields[legacy]
+        if !ok {
+            continue
+        }
+        if _, dup := fields[current]; dup {
+            return fmt.Errorf("%s and its legacy spelling %s are both set", current, legacy)
+        }
+        delete(fields, legacy)
+        fields[current] = value
+    }
+    raw, err := json.Marshal(fields)
+    if err != nil {
+        return err
+    }
+    
+    dec := json.NewDecoder(bytes.NewReader(raw))
+    dec.DisallowUnknownFields()
+    err = dec.Decode(v)
+    if m := unknownField.FindStringSubmatch(fmt.Sprint(err)); m != nil {
+        msg := fmt.Sprintf("unknown key %q", m[1])
+        if s := suggest(m[1], Keys(reflect.TypeOf(v))); s != "" {
+            msg += fmt.Sprintf(", did you mean %q?", s)
+        }
+        return fmt.Errorf("%s", msg)
+    }
+    return err
+}
+
+// Keys returns the JSON keys of the struct t, or of the struct t points to.
+func Keys(t reflect.Type) []string {
+    for t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+    var keys []string
+    if t.Kind() != reflect.Struct {
+        return keys
+    }
+    for i := 0; i < t.NumField(); i++ {
+        f := t.Field(i)
+        if f.PkgPath != "" {
+            continue
+        }
+        name := strings.Split(f.Tag.Get("json"), ",")[0]
+        switch name {
+        case "-":
+            continue
+        case "":
+            name = f.Name
+        }
+        keys = append(keys, name)
+    }
+    return keys
+}
+
+// suggest returns the key of keys closest to key, empty when none is close
+// enough to be a typo.
+func suggest(key string, keys []string) string {
+    best, bestDist := "", len(key)/3+2
+    for _, k := range keys {
+        if d := distance(strings.ToLower(key), strings.ToLower(k)); d < bestDist {
+            best, bestDist = k, d
+        }
+    }
+    return best
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+    prev := make([]int, len(b)+1)
+    cur := make([]int, len(b)+1)
+    for j := range prev {
+        prev[j] = j
+    }
+    for i := 1; i <= len(a); i++ {
+        cur[0] = i
+        for j := 1; j <= len(b); j++ {
+            cost := 1
+            if a[i-1] == b[j-1] {
+                cost = 0
+            }
+            cur[j] = minimum(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+        }
+        prev, cur = cur, prev
+    }
+    return prev[len(b)]
+}
+
+func minimum(values ...int) int {
+    m := values[0]
+    for _, v := range values[1:] {
+        if v < m {
+            m = v
+        }
+    }
+    return m
+}
@@ -0,0 +1,90 @@
+package strict
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+type doc struct {
+    Name         string   `json:"name"`
+    PreviousData []string `json:"previousData"`
+    Status       int      `json:"expectedStatus,omitempty"`
+    Internal     string   `json:"-"`
+    Plain        bool
+    hidden       bool
+}
+
+func TestUnmarshal(t *testing.T) {
+    aliases := map[string]string{"previusData": "previousData"}
+    tests := []struct {
+        name string
+        raw  string
+        want doc
+        err  string
+    }{
+        {"known keys", `{"name": "a", "previousData": ["id"], "expectedStatus": 200}`, doc{Name: "a", PreviousData: []string{"id"}, Status: 200}, ""},
+        {"schema key", `{"$schema": "../schemas/task.json", "name": "a"}`, doc{Name: "a"}, ""},
+        {"legacy key", `{"name": "a", "previusData": ["id"]}`, doc{Name: "a", PreviousData: []string{"id"}}, ""},
+        {"both spellings", `{"previusData": ["id"], "previousData": ["id"]}`, doc{}, "previousData and its legacy spelling previusData are both set"},
+        {"field name", `{"Plain": true}`, doc{Plain: true}, ""},
+        {"typo", `{"nmae": "a"}`, doc{}, `unknown key "nmae", did you mean "name"?`},
+        {"case insensitive", `{"expectedstatus": 200}`, doc{Status: 200}, ""},
+        {"missing letter", `{"expectedStatu": 200}`, doc{}, `did you mean "expectedStatus"?`},
+        {"unrelated key", `{"timeout": 5}`, doc{}, `unknown key "timeout"`},
+        {"ignored field", `{"Internal": "x"}`, doc{}, `unknown key "Internal"`},
+        {"wrong type", `{"name": 1}`, doc{}, "cannot unmarshal number"},
+        {"not an object", `[1]`, doc{}, "cannot unmarshal array"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var got doc
+            err := Unmarshal([]byte(tt.raw), &got, aliases)
+            if tt.err == "" {
+                if err != nil {
+                    t.Fatalf("unexpected error: %s", err)
+                }
+                if !reflect.DeepEqual(got, tt.want) {
+                    t.Errorf("decoded %+v, want %+v", got, tt.want)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Fatalf("error = %v, want it to contain %q", err, tt.err)
+            }
+            if tt.name == "unrelated key" && strings.Contains(err.Error(), "did you mean") {
+                t.Errorf("suggested a key for an unrelated one: %s", err)
+            }
+        })
+    }
+}
+
+func TestKeys(t *testing.T) {
+    want := []string{"name", "previousData", "expectedStatus", "Plain"}
+    if got := Keys(reflect.TypeOf(&doc{})); !reflect.DeepEqual(got, want) {
+        t.Errorf("Keys() = %q, want %q", got, want)
+    }
+    if got := Keys(reflect.TypeOf(map[string]int{})); len(got) != 0 {
+        t.Errorf("Keys() of a map = %q", got)
+    }
+}
+
+func TestSuggest(t *testing.T) {
+    keys := []string{"numPetitions", "concurrentUsers", "tasks", "dependsOn"}
+    tests := []struct {
+        key, want string
+    }{
+        {"numPetition", "numPetitions"},
+        {"concurentUsers", "concurrentUsers"},
+        {"task", "tasks"},
+        {"dependson", "dependsOn"},
+        {"depends", "dependsOn"},
+        {"users", ""},
+        {"x", ""},
+    }
+    for _, tt := range tests {
+        if got := suggest(tt.key, keys); got != tt.want {
+            t.Errorf("suggest(%q) = %q, want %q", tt.key, got, tt.want)
+        }
+    }
+}
@@ -1,43 +1,93 @@
 package task
 
 import (
-    "encoding/json"
+    "context"
     "errors"
     "fmt"
     "io/ioutil"
-    "log"
-    "os"
+    "strings"
     "time"
     
+    "github.com/jarlex/gommander/jsonschema"
     "github.com/jarlex/gommander/request"
+    "github.com/jarlex/gommander/strict"
+    "github.com/jarlex/gommander/tracing"
     "github.com/jarlex/transporter"
 )
 
 type Task struct {
-    Name           string   `json:"name"`
-    PreviousData   []string `json:"previusData"`
-    NextData       []string `json:"nextData"`
-    ExpectedStatus int      `json:"expectedStatus"`
-    NameRequest    string   `json:"request"`
-    Request        *request.Request
+    Name           string                 `json:"name"`
+    PreviousData   []string               `json:"previousData,omitempty"`
+    NextData       []string               `json:"nextData,omitempty"`
+    ExpectedStatus int                    `json:"expectedStatus"`
+    Schema         map[string]interface{} `json:"schema,omitempty"`     // JSON Schema the response body must match
+    SchemaFile     string                 `json:"schemaFile,omitempty"` // Same in a file of the plan folder
+    ThinkTime      string                 `json:"thinkTime,omitempty"`  // Pause of the user before the task
+    NameRequest    string                 `json:"request"`
+    Think          time.Duration          `json:"-"`
+    Request        *request.Request       `json:"-"`
+    ResponseSchema *jsonschema.Schema     `json:"-"`
 }
 
+// maxSchemaErrors is the number of schema errors a failing task reports.
+const maxSchemaErrors = 3
+
+// LegacyKeys maps the keys still accepted for backward compatibility to
+// their current spelling.
+var LegacyKeys = map[string]string{"previusData": "previousData"}
+
 func Read(filePath string, requests map[string]*request.Request) (*Task, error) {
-    logger := log.New(os.Stdout, "", 0)
     raw, err := ioutil.ReadFile(filePath)
-    
     if err != nil {
-        logger.Fatalf("Error reading the file in %s: %s", filePath, err.Error())
-        return nil, err
+        return nil, fmt.Errorf("Error reading the file in %s: %s", filePath, err.Error())
     }
-    
+    return Parse(raw, requests)
+}
+
+// Parse decodes a task definition, rejecting the unknown keys, and links its
+// request.
+func Parse(raw []byte, requests map[string]*request.Request) (*Task, error) {
     var t Task
-    json.Unmarshal(raw, &t)
+    if err := strict.Unmarshal(raw, &t, LegacyKeys); err != nil {
+        return nil, err
+    }
+    if t.ThinkTime != "" {
+        var err error
+        t.Think, err = time.ParseDuration(t.ThinkTime)
+        if err != nil {
+            return nil, fmt.Errorf("thinkTime of task %s: %s", t.Name, err.Error())
+        }
+    }
     t.Request = requests[t.NameRequest]
+    if t.Request == nil {
+        return nil, fmt.Errorf("request %s of task %s not found", t.NameRequest, t.Name)
+    }
+    if t.Schema != nil && t.SchemaFile != "" {
+        return nil, fmt.Errorf("task %s has both a schema and a schemaFile", t.Name)
+    }
+    if t.Schema != nil {
+        var err error
+        if t.ResponseSchema, err = jsonschema.New(t.Schema); err != nil {
+            return nil, fmt.Errorf("schema of task %s: %s", t.Name, err.Error())
+        }
+    }
     return &t, nil
 }
 
-func (t *Task) Execute(tg *transporter.Transporter, base string, previousData map[string]interface{}) (map[string]interface{}, time.Duration, error) {
+// Execute sends the request of the task with previousData and returns the
+// nextData fields of the response and the time it took. The task is traced
+// as a span of the current petition of ctx.
+func (t *Task) Execute(ctx context.Context, tg *transporter.Transporter, base string, previousData map[string]interface{}) (nextData map[string]interface{}, d time.Duration, err error) {
+    ctx, span := tracing.Start(ctx, t.Name, tracing.KindInternal)
+    span.SetAttribute("gommander.task", t.Name)
+    defer func() {
+        if err != nil {
+            span.SetAttribute("gommander.status", "fail")
+        } else {
+            span.SetAttribute("gommander.status", "ok")
+        }
+        span.End(err)
+    }()
     
     if t.PreviousData != nil {
         for _, field := range t.PreviousData {
@@ -47,25 +97,74 @@ func (t *Task) Execute(tg *transporter.Transporter, base string, previousData ma
         }
     }
     
-    resp, status, duration, err := t.Request.Execute(tg, base, previousData)
+    resp, err := t.Request.Execute(ctx, tg, base, previousData)
     if err != nil {
         return nil, -1, err
     }
+    span.SetAttribute("gommander.bytes", len(resp.Body))
     
-    if status != t.ExpectedStatus {
+    if resp.Status != t.ExpectedStatus {
         return nil, -1, errors.New("Status not expected")
     }
+    if t.ResponseSchema != nil {
+        if err := t.checkSchema(resp); err != nil {
+            return nil, -1, err
+        }
+    }
     
-    nextData := make(map[string]interface{})
+    nextData = make(map[string]interface{})
     
     if t.NextData != nil {
+        fields := resp.Fields()
         for _, field := range t.NextData {
-            if resp[field] == "" {
+            if fields[field] == "" {
                 return nil, -1, fmt.Errorf("%s mandatory and not present in nextData", field)
             }
-            nextData[field] = resp[field]
+            nextData[field] = fields[field]
+        }
+    }
+    
+    return nextData, resp.Duration, nil
+}
+
+// checkSchema validates the response body against the schema of the task.
+func (t *Task) checkSchema(resp *request.Response) error {
+    body := resp.JSON()
+    if body == nil && string(resp.Body) != "null" {
+        return errors.New("response body is not JSON")
+    }
+    errs := t.ResponseSchema.Validate(body)
+    if len(errs) == 0 {
+        return nil
+    }
+    msgs := make([]string, 0, maxSchemaErrors)
+    for i, e := range errs {
+        if i == maxSchemaErrors {
+            msgs = append(msgs, fmt.Sprintf("%d more", len(errs)-maxSchemaErrors))
+            break
         }
+        msgs = append(msgs, e.Error())
+    }
+    return fmt.Errorf("response does not match the schema: %s", strings.Join(msgs, "; "))
+}
+
+// ExecuteAll runs the tasks once, in order, merging the data extracted by each
+// one into a copy of data. On failure it returns the data gathered so far with
+// the error, so callers can still hand it to their teardown tasks.
+func ExecuteAll(ctx context.Context, tasks []*Task, tg *transporter.Transporter, base string, data map[string]interface{}) (map[string]interface{}, error) {
+    vars := make(map[string]interface{}, len(data))
+    for k, v := range data {
+        vars[k] = v
     }
     
-    return nextData, duration, nil
-}
\ No newline at end of file
+    for _, t := range tasks {
+        nextData, _, err := t.Execute(ctx, tg, base, vars)
+        if err != nil {
+            return vars, fmt.Errorf("%s: %s", t.Name, err.Error())
+        }
+        for k, v := range nextData {
+            vars[k] = v
+        }
+    }
+    return vars, nil
+}
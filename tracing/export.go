@@ -0,0 +1,274 @@
+package tracing
+
+import (
+    "bytes"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "time"
+    
+    "github.com/jarlex/gommander/secret"
+)
+
+// Exporters of a Tracer.
+const (
+    ExportOTLP = "otlp"
+    ExportFile = "file"
+)
+
+const (
+    // batchSize is the number of spans exported at once.
+    batchSize = 512
+    // batchDelay is the longest a span waits to be exported.
+    batchDelay = 5 * time.Second
+)
+
+// Options configures a Tracer.
+type Options struct {
+    Exporter string            // otlp (OTLP/HTTP JSON) or file (the same JSON, a request by line)
+    Target   string            // Collector URL, /v1/traces by default, or file path
+    Resource map[string]string // Attributes of the process, service.name is gommander by default
+    Buffer   int               // Spans waiting to be exported, 10000 when 0
+}
+
+// ParseOptions parses "exporter=target", otlp=url or file=path.
+func ParseOptions(s string) (Options, error) {
+    eq := strings.Index(s, "=")
+    if eq <= 0 || eq == len(s)-1 {
+        return Options{}, fmt.Errorf("--trace %s must be exporter=target", s)
+    }
+    opts := Options{Exporter: s[:eq], Target: s[eq+1:]}
+    if opts.Exporter != ExportOTLP && opts.Exporter != ExportFile {
+        return Options{}, fmt.Errorf("unknown trace exporter %s, use %s or %s", opts.Exporter, ExportOTLP, ExportFile)
+    }
+    return opts, nil
+}
+
+// Tracer exports the spans ended in batches. Like the metric streams, the
+// spans are dropped and counted when the exporter cannot keep up, instead of
+// blocking the users.
+type Tracer struct {
+    opts     Options
+    resource []byte // OTLP JSON of the resource
+    send     func([]byte) error
+    closer   io.Closer
+    spans    chan *Span
+    dropped  uint64
+    done     chan error
+}
+
+// New returns a tracer exporting as opts tells. Close flushes it.
+func New(opts Options) (*Tracer, error) {
+    if opts.Buffer <= 0 {
+        opts.Buffer = 10000
+    }
+    attrs := map[string]string{"service.name": "gommander"}
+    for k, v := range opts.Resource {
+        attrs[k] = v
+    }
+    var resource []attribute
+    for k, v := range attrs {
+        resource = append(resource, attribute{key: k, value: v})
+    }
+    sort.Slice(resource, func(i, j int) bool { return resource[i].key < resource[j].key })
+    raw, err := json.Marshal(otlpAttributes(resource))
+    if err != nil {
+        return nil, err
+    }
+    t := &Tracer{
+        opts:     opts,
+        resource: raw,
+        spans:    make(chan *Span, opts.Buffer),
+        done:     make(chan error, 1),
+    }
+    switch opts.Exporter {
+    case ExportOTLP:
+        endpoint := strings.TrimSuffix(opts.Target, "/")
+        if !strings.HasSuffix(endpoint, "/v1/traces") {
+            endpoint += "/v1/traces"
+        }
+        client := &http.Client{Timeout: 10 * time.Second}
+        t.send = func(batch []byte) error {
+            resp, err := client.Post(endpoint, "application/json", bytes.NewReader(batch))
+            if err != nil {
+                return err
+            }
+            defer resp.Body.Close()
+            io.Copy(ioutil.Discard, resp.Body)
+            if resp.StatusCode >= 300 {
+                return fmt.Errorf("%s answered %s", endpoint, resp.Status)
+            }
+            return nil
+        }
+    case ExportFile:
+        f, err := os.Create(opts.Target)
+        if err != nil {
+            return nil, err
+        }
+        t.closer = f
+        t.send = func(batch []byte) error {
+            _, err := f.Write(append(batch, '\n'))
+            return err
+        }
+    default:
+        return nil, fmt.Errorf("unknown trace exporter %s, use %s or %s", opts.Exporter, ExportOTLP, ExportFile)
+    }
+    go t.loop()
+    return t, nil
+}
+
+// record queues s, dropping it when the buffer is full.
+func (t *Tracer) record(s *Span) {
+    select {
+    case t.spans <- s:
+    default:
+        atomic.AddUint64(&t.dropped, 1)
+    }
+}
+
+// Dropped returns the number of spans dropped so far.
+func (t *Tracer) Dropped() uint64 {
+    return atomic.LoadUint64(&t.dropped)
+}
+
+// Close exports the spans left and returns the first error met while
+// exporting. No span may end after Close.
+func (t *Tracer) Close() error {
+    close(t.spans)
+    err := <-t.done
+    if t.closer != nil {
+        if cerr := t.closer.Close(); err == nil {
+            err = cerr
+        }
+    }
+    return err
+}
+
+func (t *Tracer) loop() {
+    var firstErr error
+    batch := make([]*Span, 0, batchSize)
+    ticker := time.NewTicker(batchDelay)
+    defer ticker.Stop()
+    flush := func() {
+        if len(batch) == 0 {
+            return
+        }
+        if err := t.export(batch); err != nil && firstErr == nil {
+            firstErr = err
+        }
+        batch = batch[:0]
+    }
+    for {
+        select {
+        case s, ok := <-t.spans:
+            if !ok {
+                flush()
+                t.done <- firstErr
+                return
+            }
+            batch = append(batch, s)
+            if len(batch) == batchSize {
+                flush()
+            }
+        case <-ticker.C:
+            flush()
+        }
+    }
+}
+
+// OTLP JSON encoding of an export request, see
+// opentelemetry/proto/collector/trace/v1.
+type (
+    otlpRequest struct {
+        ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+    }
+    otlpResourceSpans struct {
+        Resource   otlpResource     `json:"resource"`
+        ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+    }
+    otlpResource struct {
+        Attributes json.RawMessage `json:"attributes"`
+    }
+    otlpScopeSpans struct {
+        Scope otlpScope  `json:"scope"`
+        Spans []otlpSpan `json:"spans"`
+    }
+    otlpScope struct {
+        Name string `json:"name"`
+    }
+    otlpSpan struct {
+        TraceID           string          `json:"traceId"`
+        SpanID            string          `json:"spanId"`
+        ParentSpanID      string          `json:"parentSpanId,omitempty"`
+        Name              string          `json:"name"`
+        Kind              int             `json:"kind"`
+        StartTimeUnixNano string          `json:"startTimeUnixNano"`
+        EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+        Attributes        []otlpAttribute `json:"attributes,omitempty"`
+        Status            otlpStatus      `json:"status"`
+    }
+    otlpAttribute struct {
+        Key   string                 `json:"key"`
+        Value map[string]interface{} `json:"value"`
+    }
+    otlpStatus struct {
+        Code    int    `json:"code,omitempty"`
+        Message string `json:"message,omitempty"`
+    }
+)
+
+// export sends spans as one OTLP request.
+func (t *Tracer) export(spans []*Span) error {
+    out := make([]otlpSpan, len(spans))
+    var noParent [8]byte
+    for i, s := range spans {
+        out[i] = otlpSpan{
+            TraceID:           hex.EncodeToString(s.traceID[:]),
+            SpanID:            hex.EncodeToString(s.spanID[:]),
+            Name:              s.name,
+            Kind:              s.kind,
+            StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+            EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+            Attributes:        otlpAttributes(s.attributes),
+            Status:            otlpStatus{Code: s.status, Message: s.message},
+        }
+        if s.parentID != noParent {
+            out[i].ParentSpanID = hex.EncodeToString(s.parentID[:])
+        }
+    }
+    raw, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+        Resource:   otlpResource{Attributes: t.resource},
+        ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "gommander"}, Spans: out}},
+    }}})
+    if err != nil {
+        return err
+    }
+    return t.send(raw)
+}
+
+// otlpAttributes encodes attrs, the strings masked.
+func otlpAttributes(attrs []attribute) []otlpAttribute {
+    out := make([]otlpAttribute, 0, len(attrs))
+    for _, a := range attrs {
+        var value map[string]interface{}
+        switch v := a.value.(type) {
+        case int:
+            // int64 values are strings in OTLP JSON
+            value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+        case bool:
+            value = map[string]interface{}{"boolValue": v}
+        default:
+            value = map[string]interface{}{"stringValue": secret.Mask(fmt.Sprint(v))}
+        }
+        out = append(out, otlpAttribute{Key: a.key, Value: value})
+    }
+    return out
+}
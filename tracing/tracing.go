@@ -0,0 +1,119 @@
+// Package tracing records a trace for each petition of a virtual user, with
+// a span by task and by request, and propagates it to the servers through
+// the W3C traceparent header so their traces join the ones of the load. The
+// spans are exported as OTLP/HTTP JSON to a collector or to a file.
+package tracing
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+    "net/http"
+    "time"
+    
+    "github.com/jarlex/gommander/secret"
+)
+
+// Kinds of span.
+const (
+    KindInternal = 1
+    KindClient   = 3
+)
+
+// Status codes of span.
+const (
+    StatusUnset = 0
+    StatusError = 2
+)
+
+// Span is an operation of a trace. The methods of a nil span do nothing, so
+// the callers need not check whether tracing is on.
+type Span struct {
+    tracer     *Tracer
+    traceID    [16]byte
+    spanID     [8]byte
+    parentID   [8]byte
+    name       string
+    kind       int
+    start      time.Time
+    end        time.Time
+    attributes []attribute
+    status     int
+    message    string
+}
+
+type attribute struct {
+    key   string
+    value interface{} // string, int or bool
+}
+
+type tracerKey struct{}
+
+type spanKey struct{}
+
+// WithTracer returns a copy of ctx whose spans are recorded by t.
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+    return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// SpanFromContext returns the current span of ctx, nil when none.
+func SpanFromContext(ctx context.Context) *Span {
+    s, _ := ctx.Value(spanKey{}).(*Span)
+    return s
+}
+
+// Start starts a span named name, child of the current span of ctx or the
+// root of a new trace, and returns a copy of ctx where it is the current one.
+// It returns ctx and nil when ctx has no tracer.
+func Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+    t, _ := ctx.Value(tracerKey{}).(*Tracer)
+    if t == nil {
+        return ctx, nil
+    }
+    s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+    if parent := SpanFromContext(ctx); parent != nil {
+        s.traceID = parent.traceID
+        s.parentID = parent.spanID
+    } else {
+        rand.Read(s.traceID[:])
+    }
+    rand.Read(s.spanID[:])
+    return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttribute sets the attribute key of s, value is a string, an int or a
+// bool.
+func (s *Span) SetAttribute(key string, value interface{}) {
+    if s == nil {
+        return
+    }
+    for i := range s.attributes {
+        if s.attributes[i].key == key {
+            s.attributes[i].value = value
+            return
+        }
+    }
+    s.attributes = append(s.attributes, attribute{key: key, value: value})
+}
+
+// End ends s, failed with err when not nil, and hands it to the exporter.
+func (s *Span) End(err error) {
+    if s == nil {
+        return
+    }
+    s.end = time.Now()
+    if err != nil {
+        s.status = StatusError
+        s.message = secret.Mask(err.Error())
+    }
+    s.tracer.record(s)
+}
+
+// Inject sets the traceparent header of h to the current span of ctx, when
+// there is one.
+func Inject(ctx context.Context, h http.Header) {
+    if s := SpanFromContext(ctx); s != nil {
+        h.Set("traceparent", fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:])))
+    }
+}
@@ -0,0 +1,251 @@
+package tracing
+
+import (
+    "context"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "testing"
+)
+
+// request is the part of an OTLP export request the tests read.
+type request struct {
+    ResourceSpans []struct {
+        Resource struct {
+            Attributes []attr `json:"attributes"`
+        } `json:"resource"`
+        ScopeSpans []struct {
+            Scope struct {
+                Name string `json:"name"`
+            } `json:"scope"`
+            Spans []struct {
+                TraceID           string `json:"traceId"`
+                SpanID            string `json:"spanId"`
+                ParentSpanID      string `json:"parentSpanId"`
+                Name              string `json:"name"`
+                Kind              int    `json:"kind"`
+                StartTimeUnixNano string `json:"startTimeUnixNano"`
+                EndTimeUnixNano   string `json:"endTimeUnixNano"`
+                Attributes        []attr `json:"attributes"`
+                Status            struct {
+                    Code    int    `json:"code"`
+                    Message string `json:"message"`
+                } `json:"status"`
+            } `json:"spans"`
+        } `json:"scopeSpans"`
+    } `json:"resourceSpans"`
+}
+
+type attr struct {
+    Key   string                 `json:"key"`
+    Value map[string]interface{} `json:"value"`
+}
+
+// tracer returns a tracer exporting to a file and the path of the file.
+func tracer(t *testing.T) (*Tracer, string) {
+    path := filepath.Join(t.TempDir(), "trace.jsonl")
+    tr, err := New(Options{Exporter: ExportFile, Target: path, Resource: map[string]string{"gommander.plan": "shop"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    return tr, path
+}
+
+func TestInject(t *testing.T) {
+    tr, _ := tracer(t)
+    defer tr.Close()
+    h := http.Header{}
+    Inject(context.Background(), h)
+    if h.Get("traceparent") != "" {
+        t.Errorf("traceparent without a span: %s", h.Get("traceparent"))
+    }
+    
+    ctx, span := Start(WithTracer(context.Background(), tr), "petition", KindInternal)
+    Inject(ctx, h)
+    tp := h.Get("traceparent")
+    if !regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`).MatchString(tp) {
+        t.Fatalf("traceparent %q is not version 00, sampled", tp)
+    }
+    parts := strings.Split(tp, "-")
+    if parts[1] != hex.EncodeToString(span.traceID[:]) || parts[2] != hex.EncodeToString(span.spanID[:]) {
+        t.Errorf("traceparent %s is not the current span", tp)
+    }
+    if parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+        t.Errorf("traceparent %s has an invalid all zero id", tp)
+    }
+}
+
+func TestStart(t *testing.T) {
+    ctx, span := Start(context.Background(), "petition", KindInternal)
+    if span != nil || SpanFromContext(ctx) != nil {
+        t.Fatal("span started without a tracer")
+    }
+    // The methods of a nil span do nothing
+    span.SetAttribute("k", "v")
+    span.End(nil)
+    
+    tr, _ := tracer(t)
+    defer tr.Close()
+    ctx = WithTracer(context.Background(), tr)
+    pctx, parent := Start(ctx, "petition", KindInternal)
+    cctx, child := Start(pctx, "GET /items", KindClient)
+    _, other := Start(ctx, "petition", KindInternal)
+    if SpanFromContext(cctx) != child || SpanFromContext(pctx) != parent {
+        t.Error("the context does not hold the span started")
+    }
+    if child.traceID != parent.traceID || child.parentID != parent.spanID || child.spanID == parent.spanID {
+        t.Errorf("child %x/%x of %x, parent %x/%x", child.traceID, child.spanID, child.parentID, parent.traceID, parent.spanID)
+    }
+    if parent.parentID != [8]byte{} {
+        t.Errorf("root span has parent %x", parent.parentID)
+    }
+    if other.traceID == parent.traceID {
+        t.Error("two roots share their trace")
+    }
+    
+    child.SetAttribute("http.status_code", 200)
+    child.SetAttribute("http.status_code", 404)
+    if len(child.attributes) != 1 || child.attributes[0].value != 404 {
+        t.Errorf("attributes = %+v", child.attributes)
+    }
+}
+
+func TestFileExport(t *testing.T) {
+    tr, path := tracer(t)
+    ctx := WithTracer(context.Background(), tr)
+    pctx, parent := Start(ctx, "petition", KindInternal)
+    _, child := Start(pctx, "GET /items", KindClient)
+    child.SetAttribute("http.method", "GET")
+    child.SetAttribute("http.status_code", 500)
+    child.SetAttribute("gommander.extracted", true)
+    child.End(errors.New("Status not expected"))
+    parent.End(nil)
+    if err := tr.Close(); err != nil {
+        t.Fatal(err)
+    }
+    
+    raw, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    lines := strings.Split(strings.TrimSuffix(string(raw), "\n"), "\n")
+    if len(lines) != 1 {
+        t.Fatalf("%d export requests, want 1:\n%s", len(lines), raw)
+    }
+    var req request
+    dec := json.NewDecoder(strings.NewReader(lines[0]))
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(&req); err != nil {
+        t.Fatalf("not an OTLP export request: %v\n%s", err, lines[0])
+    }
+    if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+        t.Fatalf("request = %+v", req)
+    }
+    rs := req.ResourceSpans[0]
+    resource := map[string]interface{}{}
+    for _, a := range rs.Resource.Attributes {
+        resource[a.Key] = a.Value["stringValue"]
+    }
+    if resource["service.name"] != "gommander" || resource["gommander.plan"] != "shop" {
+        t.Errorf("resource = %v", resource)
+    }
+    ss := rs.ScopeSpans[0]
+    if ss.Scope.Name != "gommander" || len(ss.Spans) != 2 {
+        t.Fatalf("scope spans = %+v", ss)
+    }
+    
+    c, p := ss.Spans[0], ss.Spans[1]
+    if c.Name != "GET /items" || c.Kind != KindClient || p.Name != "petition" || p.Kind != KindInternal {
+        t.Errorf("spans %s (%d), %s (%d)", c.Name, c.Kind, p.Name, p.Kind)
+    }
+    if c.TraceID != p.TraceID || c.ParentSpanID != p.SpanID || p.ParentSpanID != "" {
+        t.Errorf("child %s/%s of %s, parent %s/%s", c.TraceID, c.SpanID, c.ParentSpanID, p.TraceID, p.SpanID)
+    }
+    if len(c.TraceID) != 32 || len(c.SpanID) != 16 {
+        t.Errorf("ids %s %s are not hex of 16 and 8 bytes", c.TraceID, c.SpanID)
+    }
+    if c.StartTimeUnixNano == "" || c.EndTimeUnixNano < c.StartTimeUnixNano {
+        t.Errorf("times %s to %s", c.StartTimeUnixNano, c.EndTimeUnixNano)
+    }
+    if c.Status.Code != StatusError || c.Status.Message != "Status not expected" || p.Status.Code != StatusUnset {
+        t.Errorf("status %+v, %+v", c.Status, p.Status)
+    }
+    want := []attr{
+        {"http.method", map[string]interface{}{"stringValue": "GET"}},
+        {"http.status_code", map[string]interface{}{"intValue": "500"}},
+        {"gommander.extracted", map[string]interface{}{"boolValue": true}},
+    }
+    got, _ := json.Marshal(c.Attributes)
+    if exp, _ := json.Marshal(want); string(got) != string(exp) {
+        t.Errorf("attributes = %s\nwant %s", got, exp)
+    }
+}
+
+func TestOTLPExport(t *testing.T) {
+    var path, contentType string
+    var body []byte
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+        body, _ = io.ReadAll(r.Body)
+    }))
+    defer srv.Close()
+    tr, err := New(Options{Exporter: ExportOTLP, Target: srv.URL + "/"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    _, span := Start(WithTracer(context.Background(), tr), "petition", KindInternal)
+    span.End(nil)
+    if err := tr.Close(); err != nil {
+        t.Fatal(err)
+    }
+    var req request
+    if path != "/v1/traces" || contentType != "application/json" || json.Unmarshal(body, &req) != nil {
+        t.Errorf("POST %s (%s): %s", path, contentType, body)
+    }
+    
+    failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusServiceUnavailable)
+    }))
+    defer failing.Close()
+    tr, err = New(Options{Exporter: ExportOTLP, Target: failing.URL + "/v1/traces"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    _, span = Start(WithTracer(context.Background(), tr), "petition", KindInternal)
+    span.End(nil)
+    if err := tr.Close(); err == nil || !strings.Contains(err.Error(), "503") {
+        t.Errorf("Close() = %v, want the 503 of the collector", err)
+    }
+}
+
+func TestParseOptions(t *testing.T) {
+    tests := []struct {
+        in       string
+        exporter string
+        target   string
+        err      bool
+    }{
+        {"otlp=http://localhost:4318", ExportOTLP, "http://localhost:4318", false},
+        {"file=trace.jsonl", ExportFile, "trace.jsonl", false},
+        {"jaeger=http://localhost:14268", "", "", true},
+        {"otlp=", "", "", true},
+        {"trace.jsonl", "", "", true},
+    }
+    for _, tt := range tests {
+        opts, err := ParseOptions(tt.in)
+        if (err != nil) != tt.err {
+            t.Errorf("ParseOptions(%q) error = %v", tt.in, err)
+            continue
+        }
+        if opts.Exporter != tt.exporter || opts.Target != tt.target {
+            t.Errorf("ParseOptions(%q) = %s, %s, want %s, %s", tt.in, opts.Exporter, opts.Target, tt.exporter, tt.target)
+        }
+    }
+}